@@ -0,0 +1,332 @@
+package cassette
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spf13/afero"
+)
+
+// MatchOn is a set of request attributes used to match a request against
+// recorded interactions.
+type MatchOn int
+
+const (
+	// MatchMethod matches requests by HTTP method.
+	MatchMethod MatchOn = 1 << iota
+
+	// MatchPath matches requests by URL path.
+	MatchPath
+
+	// MatchQuery matches requests by URL query parameters, ignoring order.
+	MatchQuery
+
+	// MatchBody matches requests by body content.
+	MatchBody
+
+	// DefaultMatch matches requests by method, path, and query.
+	DefaultMatch = MatchMethod | MatchPath | MatchQuery
+)
+
+const (
+	// Redacted is the value that replaces redacted content.
+	Redacted = "REDACTED"
+
+	base64Encoding = "base64"
+)
+
+// AppFS is the filesystem used by the cassette package.
+var AppFS = afero.NewOsFs()
+
+// A Cassette is a recorded set of HTTP interactions.
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+
+	used map[*Interaction]bool
+}
+
+// An Interaction is a single recorded HTTP request and its response.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// A Request is a recorded HTTP request.
+type Request struct {
+	Method       string      `json:"method"`
+	URL          string      `json:"url"`
+	Headers      http.Header `json:"headers,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty"`
+}
+
+// A Response is a recorded HTTP response.
+type Response struct {
+	Status       int         `json:"status"`
+	Headers      http.Header `json:"headers,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty"`
+}
+
+// A Redactor removes sensitive content from an interaction before it is
+// written to a cassette or matched against recorded interactions.
+type Redactor func(*Interaction)
+
+// DefaultRedactors are the redactors applied to every cassette, which remove
+// common credential headers.
+var DefaultRedactors = []Redactor{
+	RedactHeaders("Authorization", "Cookie", "Proxy-Authorization", "Set-Cookie"),
+}
+
+// New creates an empty cassette.
+func New() *Cassette {
+	return &Cassette{
+		Interactions: []*Interaction{},
+	}
+}
+
+// Exists determines whether a cassette file exists at the given path.
+func Exists(path string) (bool, error) {
+	return afero.Exists(AppFS, path)
+}
+
+// Load loads a cassette from the given path.
+func Load(path string) (*Cassette, error) {
+	b, err := afero.ReadFile(AppFS, path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("cassette %q: not found", path)
+		}
+		return nil, newCassetteError(path, err)
+	}
+
+	c := New()
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, newCassetteError(path, err)
+	}
+
+	return c, nil
+}
+
+// Save saves the cassette to the given path, creating any missing parent
+// directories.
+func (c *Cassette) Save(path string) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return newCassetteError(path, err)
+	}
+
+	if err := AppFS.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return newCassetteError(path, err)
+	}
+
+	if err := afero.WriteFile(AppFS, path, b, 0644); err != nil {
+		return newCassetteError(path, err)
+	}
+
+	return nil
+}
+
+// Add adds an interaction to the cassette.
+func (c *Cassette) Add(interaction *Interaction) {
+	c.Interactions = append(c.Interactions, interaction)
+}
+
+// Match finds the first recorded interaction matching a request that has not
+// already been matched, and marks it as matched. This allows a sequence of
+// identical requests to be answered by a sequence of different responses.
+func (c *Cassette) Match(req Request, on MatchOn) (*Interaction, error) {
+	if c.used == nil {
+		c.used = map[*Interaction]bool{}
+	}
+
+	for _, interaction := range c.Interactions {
+		if c.used[interaction] {
+			continue
+		}
+
+		if matches(interaction.Request, req, on) {
+			c.used[interaction] = true
+			return interaction, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no recorded interaction matches %s %s", req.Method, req.URL)
+}
+
+// NewRequest creates a recorded request.
+func NewRequest(method, url string, headers http.Header, body []byte) Request {
+	r := Request{
+		Method:  method,
+		URL:     url,
+		Headers: headers.Clone(),
+	}
+
+	r.Body, r.BodyEncoding = encodeBody(body)
+	return r
+}
+
+// BodyBytes returns the decoded request body.
+func (r Request) BodyBytes() ([]byte, error) {
+	return decodeBody(r.Body, r.BodyEncoding)
+}
+
+// NewResponse creates a recorded response.
+func NewResponse(status int, headers http.Header, body []byte) Response {
+	r := Response{
+		Status:  status,
+		Headers: headers.Clone(),
+	}
+
+	r.Body, r.BodyEncoding = encodeBody(body)
+	return r
+}
+
+// BodyBytes returns the decoded response body.
+func (r Response) BodyBytes() ([]byte, error) {
+	return decodeBody(r.Body, r.BodyEncoding)
+}
+
+// RedactHeaders creates a redactor that replaces the values of the given
+// request and response headers.
+func RedactHeaders(names ...string) Redactor {
+	return func(i *Interaction) {
+		for _, name := range names {
+			redactHeader(i.Request.Headers, name)
+			redactHeader(i.Response.Headers, name)
+		}
+	}
+}
+
+// RedactQueryParams creates a redactor that replaces the values of the given
+// request query parameters.
+func RedactQueryParams(names ...string) Redactor {
+	return func(i *Interaction) {
+		u, err := url.Parse(i.Request.URL)
+		if err != nil {
+			return
+		}
+
+		query := u.Query()
+		for _, name := range names {
+			if values, ok := query[name]; ok {
+				for j := range values {
+					values[j] = Redacted
+				}
+			}
+		}
+
+		u.RawQuery = query.Encode()
+		i.Request.URL = u.String()
+	}
+}
+
+// RedactBody creates a redactor that replaces content matching a regular
+// expression in request and response bodies. If the expression contains a
+// capturing group, only the content matching the first group is replaced.
+func RedactBody(pattern *regexp.Regexp) Redactor {
+	return func(i *Interaction) {
+		if i.Request.BodyEncoding == "" {
+			i.Request.Body = redactString(pattern, i.Request.Body)
+		}
+		if i.Response.BodyEncoding == "" {
+			i.Response.Body = redactString(pattern, i.Response.Body)
+		}
+	}
+}
+
+func matches(recorded, req Request, on MatchOn) bool {
+	if on&MatchMethod != 0 && recorded.Method != req.Method {
+		return false
+	}
+
+	if on&MatchBody != 0 && (recorded.Body != req.Body || recorded.BodyEncoding != req.BodyEncoding) {
+		return false
+	}
+
+	if on&(MatchPath|MatchQuery) == 0 {
+		return true
+	}
+
+	ru, err := url.Parse(recorded.URL)
+	if err != nil {
+		return false
+	}
+
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return false
+	}
+
+	if on&MatchPath != 0 && ru.Path != u.Path {
+		return false
+	}
+
+	if on&MatchQuery != 0 && ru.Query().Encode() != u.Query().Encode() {
+		return false
+	}
+
+	return true
+}
+
+func redactHeader(headers http.Header, name string) {
+	if values := headers.Values(name); len(values) > 0 {
+		redacted := make([]string, len(values))
+		for i := range redacted {
+			redacted[i] = Redacted
+		}
+		headers[http.CanonicalHeaderKey(name)] = redacted
+	}
+}
+
+func redactString(pattern *regexp.Regexp, s string) string {
+	if pattern.NumSubexp() == 0 {
+		return pattern.ReplaceAllString(s, Redacted)
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, loc := range pattern.FindAllStringSubmatchIndex(s, -1) {
+		if loc[2] < 0 {
+			continue
+		}
+
+		sb.WriteString(s[last:loc[2]])
+		sb.WriteString(Redacted)
+		last = loc[3]
+	}
+
+	sb.WriteString(s[last:])
+	return sb.String()
+}
+
+func encodeBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+
+	return base64.StdEncoding.EncodeToString(body), base64Encoding
+}
+
+func decodeBody(body, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(body), nil
+	case base64Encoding:
+		return base64.StdEncoding.DecodeString(body)
+	default:
+		return nil, fmt.Errorf("unknown body encoding %q", encoding)
+	}
+}
+
+func newCassetteError(path string, err error) error {
+	return fmt.Errorf("cassette %q: %w", path, err)
+}
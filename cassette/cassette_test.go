@@ -0,0 +1,191 @@
+package cassette_test
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/jefflinse/melatonin/cassette"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		c         *cassette.Cassette
+		wantError string
+	}{
+		{
+			name: "success, empty cassette",
+			c:    cassette.New(),
+		},
+		{
+			name: "success, text bodies",
+			c: &cassette.Cassette{
+				Interactions: []*cassette.Interaction{
+					{
+						Request:  cassette.NewRequest("POST", "http://example.com/foo", http.Header{"Accept": {"text/plain"}}, []byte("hello")),
+						Response: cassette.NewResponse(201, http.Header{"Content-Type": {"text/plain"}}, []byte("world")),
+					},
+				},
+			},
+		},
+		{
+			name: "success, binary bodies",
+			c: &cassette.Cassette{
+				Interactions: []*cassette.Interaction{
+					{
+						Request:  cassette.NewRequest("PUT", "http://example.com/foo", nil, []byte{0xff, 0xfe}),
+						Response: cassette.NewResponse(200, nil, []byte{0x00, 0xff}),
+					},
+				},
+			},
+		},
+		{
+			name:      "failure, can't write file",
+			c:         cassette.New(),
+			wantError: "operation not permitted",
+		},
+	} {
+		path := "/cassettes/test.json"
+		t.Run(test.name, func(t *testing.T) {
+			cassette.AppFS = afero.NewMemMapFs()
+
+			// special case
+			if test.name == "failure, can't write file" {
+				cassette.AppFS = afero.NewReadOnlyFs(cassette.AppFS)
+			}
+
+			err := test.c.Save(path)
+			if test.wantError != "" {
+				assert.EqualError(t, err, fmt.Sprintf("cassette %q: %s", path, test.wantError))
+			} else {
+				assert.NoError(t, err)
+				c, err := cassette.Load(path)
+				assert.NoError(t, err)
+				assert.Equal(t, test.c.Interactions, c.Interactions)
+			}
+		})
+	}
+}
+
+func TestLoadNotFound(t *testing.T) {
+	cassette.AppFS = afero.NewMemMapFs()
+	_, err := cassette.Load("/missing.json")
+	assert.EqualError(t, err, `cassette "/missing.json": not found`)
+}
+
+func TestBodyBytes(t *testing.T) {
+	for _, body := range [][]byte{nil, []byte("text"), {0xff, 0x00, 0xfe}} {
+		req := cassette.NewRequest("GET", "/", nil, body)
+		b, err := req.BodyBytes()
+		assert.NoError(t, err)
+		assert.Equal(t, string(body), string(b))
+
+		resp := cassette.NewResponse(200, nil, body)
+		b, err = resp.BodyBytes()
+		assert.NoError(t, err)
+		assert.Equal(t, string(body), string(b))
+	}
+}
+
+func TestMatch(t *testing.T) {
+	recorded := []*cassette.Interaction{
+		{Request: cassette.NewRequest("GET", "http://example.com/foo?a=1&b=2", nil, nil), Response: cassette.NewResponse(200, nil, []byte("first"))},
+		{Request: cassette.NewRequest("GET", "http://example.com/foo?a=1&b=2", nil, nil), Response: cassette.NewResponse(200, nil, []byte("second"))},
+		{Request: cassette.NewRequest("POST", "http://example.com/foo", nil, []byte("x")), Response: cassette.NewResponse(201, nil, []byte("created x"))},
+		{Request: cassette.NewRequest("POST", "http://example.com/foo", nil, []byte("y")), Response: cassette.NewResponse(201, nil, []byte("created y"))},
+	}
+
+	for _, test := range []struct {
+		name      string
+		requests  []cassette.Request
+		on        cassette.MatchOn
+		want      []string
+		wantError string
+	}{
+		{
+			name:     "success, repeated requests match in order",
+			requests: []cassette.Request{cassette.NewRequest("GET", "http://localhost/foo?b=2&a=1", nil, nil), cassette.NewRequest("GET", "http://localhost/foo?a=1&b=2", nil, nil)},
+			on:       cassette.DefaultMatch,
+			want:     []string{"first", "second"},
+		},
+		{
+			name:     "success, match on body",
+			requests: []cassette.Request{cassette.NewRequest("POST", "http://example.com/foo", nil, []byte("y"))},
+			on:       cassette.DefaultMatch | cassette.MatchBody,
+			want:     []string{"created y"},
+		},
+		{
+			name:     "success, ignore query",
+			requests: []cassette.Request{cassette.NewRequest("GET", "http://example.com/foo?c=3", nil, nil)},
+			on:       cassette.MatchMethod | cassette.MatchPath,
+			want:     []string{"first"},
+		},
+		{
+			name:      "failure, no more matching interactions",
+			requests:  []cassette.Request{cassette.NewRequest("GET", "http://example.com/foo?a=1&b=2", nil, nil), cassette.NewRequest("GET", "http://example.com/foo?a=1&b=2", nil, nil), cassette.NewRequest("GET", "http://example.com/foo?a=1&b=2", nil, nil)},
+			on:        cassette.DefaultMatch,
+			want:      []string{"first", "second"},
+			wantError: "no recorded interaction matches GET http://example.com/foo?a=1&b=2",
+		},
+		{
+			name:      "failure, query mismatch",
+			requests:  []cassette.Request{cassette.NewRequest("GET", "http://example.com/foo?a=2", nil, nil)},
+			on:        cassette.DefaultMatch,
+			wantError: "no recorded interaction matches GET http://example.com/foo?a=2",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			c := &cassette.Cassette{Interactions: recorded}
+			var got []string
+			var err error
+			for _, req := range test.requests {
+				var interaction *cassette.Interaction
+				interaction, err = c.Match(req, test.on)
+				if err != nil {
+					break
+				}
+				got = append(got, interaction.Response.Body)
+			}
+
+			if test.wantError != "" {
+				assert.EqualError(t, err, test.wantError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestRedactors(t *testing.T) {
+	interaction := &cassette.Interaction{
+		Request: cassette.NewRequest(
+			"POST",
+			"http://example.com/token?client_id=abc&api_key=secret",
+			http.Header{"Authorization": {"Bearer secret"}, "Accept": {"application/json"}},
+			[]byte(`{"password":"hunter2","user":"bob"}`),
+		),
+		Response: cassette.NewResponse(
+			200,
+			http.Header{"Set-Cookie": {"a=1", "b=2"}},
+			[]byte(`{"access_token":"abc123"}`),
+		),
+	}
+
+	for _, redactor := range cassette.DefaultRedactors {
+		redactor(interaction)
+	}
+	cassette.RedactQueryParams("api_key")(interaction)
+	cassette.RedactBody(regexp.MustCompile(`"password":"([^"]*)"`))(interaction)
+	cassette.RedactBody(regexp.MustCompile(`abc\d+`))(interaction)
+
+	assert.Equal(t, "http://example.com/token?api_key=REDACTED&client_id=abc", interaction.Request.URL)
+	assert.Equal(t, http.Header{"Authorization": {"REDACTED"}, "Accept": {"application/json"}}, interaction.Request.Headers)
+	assert.Equal(t, `{"password":"REDACTED","user":"bob"}`, interaction.Request.Body)
+	assert.Equal(t, http.Header{"Set-Cookie": {"REDACTED", "REDACTED"}}, interaction.Response.Headers)
+	assert.Equal(t, `{"access_token":"REDACTED"}`, interaction.Response.Body)
+}
@@ -0,0 +1,134 @@
+package expect
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Not creates a predicate requiring a value not to match an expected value,
+// which may be anything accepted by CompareValues.
+func Not(expected any) Predicate {
+	return func(actual any) error {
+		if len(CompareValues(expected, actual, false)) > 0 {
+			return nil
+		}
+
+		return fmt.Errorf("expected not to match %s, got %s", describeExpected(expected), describeExpected(actual))
+	}
+}
+
+// AllOf creates a predicate requiring a value to match every expected value.
+// Expected values may be anything accepted by CompareValues, so literals,
+// objects and predicates can be mixed.
+//
+// Unlike Predicate.And, every expected value is compared, and the failures
+// of each are reported together.
+func AllOf(expected ...any) Predicate {
+	return func(actual any) error {
+		branches := compareBranches(expected, actual)
+		var failed []branch
+		for _, b := range branches {
+			if len(b.failures) > 0 {
+				failed = append(failed, b)
+			}
+		}
+
+		if len(failed) == 0 {
+			return nil
+		}
+
+		return &branchesError{
+			summary:  fmt.Sprintf("expected to match all of %d, failed %d", len(expected), len(failed)),
+			branches: failed,
+		}
+	}
+}
+
+// AnyOf creates a predicate requiring a value to match at least one expected
+// value. Expected values may be anything accepted by CompareValues.
+//
+// Unlike Predicate.Or, the failures of every expected value are reported
+// when none match.
+func AnyOf(expected ...any) Predicate {
+	return func(actual any) error {
+		branches := compareBranches(expected, actual)
+		for _, b := range branches {
+			if len(b.failures) == 0 {
+				return nil
+			}
+		}
+
+		return &branchesError{
+			summary:  fmt.Sprintf("expected to match at least one of %d, matched none", len(expected)),
+			branches: branches,
+		}
+	}
+}
+
+// OneOf creates a predicate requiring a value to match exactly one expected
+// value. Expected values may be anything accepted by CompareValues.
+func OneOf(expected ...any) Predicate {
+	return func(actual any) error {
+		branches := compareBranches(expected, actual)
+		var matched []string
+		for _, b := range branches {
+			if len(b.failures) == 0 {
+				matched = append(matched, fmt.Sprintf("[%d]", b.index))
+			}
+		}
+
+		switch len(matched) {
+		case 1:
+			return nil
+		case 0:
+			return &branchesError{
+				summary:  fmt.Sprintf("expected to match exactly one of %d, matched none", len(expected)),
+				branches: branches,
+			}
+		default:
+			return fmt.Errorf("expected to match exactly one of %d, matched %d: %s", len(expected), len(matched), strings.Join(matched, ", "))
+		}
+	}
+}
+
+// A branch is the result of comparing a value against one of the expected
+// values of a combinator.
+type branch struct {
+	index    int
+	failures []*FailedPredicateError
+}
+
+func compareBranches(expected []any, actual any) []branch {
+	branches := make([]branch, len(expected))
+	for i, e := range expected {
+		branches[i] = branch{index: i, failures: CompareValues(e, actual, false)}
+	}
+
+	return branches
+}
+
+// A branchesError reports the failed branches of a combinator as a tree, with
+// one line for each failure labeled with the index of its expected value.
+type branchesError struct {
+	summary  string
+	branches []branch
+}
+
+func (e *branchesError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(e.summary)
+	sb.WriteString(":")
+	for _, b := range e.branches {
+		for _, failure := range b.failures {
+			msg := failure.Cause.Error()
+			if len(failure.FieldStack) > 0 {
+				fields := strings.ReplaceAll("."+strings.Join(failure.FieldStack, "."), ".[", "[")
+				msg = fields + ": " + msg
+			}
+
+			fmt.Fprintf(&sb, "\n  [%d] %s", b.index, strings.ReplaceAll(msg, "\n", "\n    "))
+		}
+	}
+
+	return sb.String()
+}
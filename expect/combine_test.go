@@ -0,0 +1,124 @@
+package expect_test
+
+import (
+	"testing"
+
+	"github.com/jefflinse/melatonin/expect"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCombinators(t *testing.T) {
+	user := map[string]any{"id": 1.0, "name": "Ada", "admin": false}
+
+	for _, test := range []struct {
+		name      string
+		predicate expect.Predicate
+		actual    any
+		wantError string
+	}{
+		{
+			name:      "not",
+			predicate: expect.Not("Ada"),
+			actual:    "Grace",
+		},
+		{
+			name:      "not, matched",
+			predicate: expect.Not("Ada"),
+			actual:    "Ada",
+			wantError: `expected not to match "Ada", got "Ada"`,
+		},
+		{
+			name:      "not, matched predicate",
+			predicate: expect.Not(map[string]any{"name": expect.String()}),
+			actual:    user,
+			wantError: `expected not to match {"name":"<predicate>"}, got {"admin":false,"id":1,"name":"Ada"}`,
+		},
+		{
+			name:      "all of",
+			predicate: expect.AllOf(expect.String(), expect.Pattern("^A"), "Ada"),
+			actual:    "Ada",
+		},
+		{
+			name:      "all of, failed",
+			predicate: expect.AllOf(map[string]any{"id": 1}, map[string]any{"name": "Grace"}, map[string]any{"admin": expect.Bool(true)}),
+			actual:    user,
+			wantError: "expected to match all of 3, failed 2:\n" +
+				"  [1] .name: expected Grace, got Ada\n" +
+				"  [2] .admin: expected one of [true], got false",
+		},
+		{
+			name:      "all of, failed nested predicates",
+			predicate: expect.AllOf(expect.ElementsMatch("a"), expect.ContainsElement("c")),
+			actual:    []any{"a", "b"},
+			wantError: "expected to match all of 2, failed 2:\n" +
+				"  [0] [1]: unexpected element \"b\"\n" +
+				"  [1] expected an element matching \"c\", got none",
+		},
+		{
+			name:      "any of",
+			predicate: expect.AnyOf(1, "1"),
+			actual:    "1",
+		},
+		{
+			name:      "any of, matched none",
+			predicate: expect.AnyOf(1, expect.Pattern("^[a-z]+$")),
+			actual:    "A1",
+			wantError: "expected to match at least one of 2, matched none:\n" +
+				"  [0] expected type int, got string: A1\n" +
+				"  [1] expected to match pattern \"^[a-z]+$\", got \"A1\"",
+		},
+		{
+			name:      "any of, multiline failure",
+			predicate: expect.AnyOf([]any{1, 2}),
+			actual:    []any{1.0},
+			wantError: "expected to match at least one of 1, matched none:\n" +
+				"  [0] expected at least 2 elements, got 1: [\n" +
+				"      1\n" +
+				"    ]",
+		},
+		{
+			name:      "one of",
+			predicate: expect.OneOf(expect.String(), 1),
+			actual:    "a",
+		},
+		{
+			name:      "one of, matched none",
+			predicate: expect.OneOf(1, 2),
+			actual:    3.0,
+			wantError: "expected to match exactly one of 2, matched none:\n" +
+				"  [0] expected 1, got 3\n" +
+				"  [1] expected 2, got 3",
+		},
+		{
+			name:      "one of, matched several",
+			predicate: expect.OneOf(expect.String(), "a", 1),
+			actual:    "a",
+			wantError: "expected to match exactly one of 3, matched 2: [0], [1]",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.predicate(test.actual)
+			if test.wantError != "" {
+				if assert.Error(t, err) {
+					assert.Equal(t, test.wantError, err.Error())
+				}
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestCombinatorFailureLocation(t *testing.T) {
+	errs := expect.CompareValues(
+		map[string]any{"user": map[string]any{"name": expect.AnyOf("Grace", "Alan")}},
+		map[string]any{"user": map[string]any{"name": "Ada"}},
+		false)
+
+	assert.Equal(t, []string{
+		"user.name: expected to match at least one of 2, matched none:\n" +
+			"  [0] expected Grace, got Ada\n" +
+			"  [1] expected Alan, got Ada",
+	}, messages(errs))
+}
@@ -1,10 +1,15 @@
 package expect
 
 import (
+	"encoding"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"reflect"
 	"regexp"
 	"sort"
+	"time"
 
 	mtjson "github.com/jefflinse/melatonin/json"
 )
@@ -212,15 +217,23 @@ func String(expected ...string) Predicate {
 }
 
 // CompareValues compares an expected value to an actual value.
+//
+// Numbers of any Go numeric kind are compared numerically. Maps and slices of
+// any type are compared element by element, and structs and other values that
+// marshal themselves to JSON are compared using their JSON representation.
+// A time.Time matches an equal time, or a string containing one in RFC 3339
+// format. A nil expectation requires the actual value to be nil.
 func CompareValues(expected, actual any, exactJSON bool) []*FailedPredicateError {
 	errs := []*FailedPredicateError{}
 
-	if expected == nil && actual != nil {
-		errs = append(errs, failedPredicate(fmt.Errorf("expected nil, got %T: %+v", actual, actual)))
-	}
-
 	switch expectedValue := expected.(type) {
 
+	case nil:
+		if actual != nil {
+			errs = append(errs, failedPredicate(fmt.Errorf("expected nil, got %T: %+v", actual, actual)))
+			return errs
+		}
+
 	case bool:
 		if err := compareBoolValues(expectedValue, actual); err != nil {
 			errs = append(errs, err)
@@ -233,38 +246,26 @@ func CompareValues(expected, actual any, exactJSON bool) []*FailedPredicateError
 			return errs
 		}
 
-	case float64:
-		if err := compareFloat64Values(expectedValue, actual); err != nil {
-			errs = append(errs, err)
-			return errs
-		}
-
-	case *float64:
-		if err := compareFloat64Values(*expectedValue, actual); err != nil {
-			errs = append(errs, err)
-			return errs
-		}
-
-	case int64:
-		if err := compareInt64Values(expectedValue, actual); err != nil {
+	case string:
+		if err := compareStringValues(expectedValue, actual); err != nil {
 			errs = append(errs, err)
 			return errs
 		}
 
-	case *int64:
-		if err := compareInt64Values(*expectedValue, actual); err != nil {
+	case *string:
+		if err := compareStringValues(*expectedValue, actual); err != nil {
 			errs = append(errs, err)
 			return errs
 		}
 
-	case string:
-		if err := compareStringValues(expectedValue, actual); err != nil {
+	case []byte:
+		if err := compareStringValues(string(expectedValue), actual); err != nil {
 			errs = append(errs, err)
 			return errs
 		}
 
-	case *string:
-		if err := compareStringValues(*expectedValue, actual); err != nil {
+	case time.Time:
+		if err := compareTimeValues(expectedValue, actual); err != nil {
 			errs = append(errs, err)
 			return errs
 		}
@@ -289,17 +290,98 @@ func CompareValues(expected, actual any, exactJSON bool) []*FailedPredicateError
 			f = Predicate(expectedValue.(func(any) error))
 		}
 		if err := f(actual); err != nil {
+			var failures FailedPredicateErrors
+			if errors.As(err, &failures) {
+				return append(errs, failures...)
+			}
+
 			errs = append(errs, failedPredicate(err))
 			return errs
 		}
 
+	case json.Marshaler:
+		return compareJSONValues(expectedValue, actual, exactJSON)
+
+	default:
+		return compareReflectedValues(expected, actual, exactJSON)
+	}
+
+	return nil
+}
+
+var predicateType = reflect.TypeOf(Predicate(nil))
+
+// compareReflectedValues compares an expected value of any other type to an
+// actual value, based on the expected value's kind.
+func compareReflectedValues(expected, actual any, exact bool) []*FailedPredicateError {
+	rv := reflect.ValueOf(expected)
+	switch rv.Kind() {
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return CompareValues(nil, actual, exact)
+		}
+		return CompareValues(rv.Elem().Interface(), actual, exact)
+
+	case reflect.Bool:
+		if err := compareBoolValues(rv.Bool(), actual); err != nil {
+			return []*FailedPredicateError{err}
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		if err := compareNumericValues(expected, actual); err != nil {
+			return []*FailedPredicateError{err}
+		}
+
+	case reflect.String:
+		if err := compareStringValues(rv.String(), actual); err != nil {
+			return []*FailedPredicateError{err}
+		}
+
+	case reflect.Map:
+		m, ok := toMap(expected)
+		if !ok {
+			return []*FailedPredicateError{failedPredicate(fmt.Errorf("unsupported map key type in %T", expected))}
+		}
+		return compareMapValues(m, actual, exact)
+
+	case reflect.Slice, reflect.Array:
+		s, _ := toSlice(expected)
+		return compareSliceValues(s, actual, exact)
+
+	case reflect.Struct:
+		return compareJSONValues(expected, actual, exact)
+
+	case reflect.Func:
+		if !rv.IsNil() && rv.Type().ConvertibleTo(predicateType) {
+			return CompareValues(rv.Convert(predicateType).Interface(), actual, exact)
+		}
+		fallthrough
+
 	default:
-		errs = append(errs, failedPredicate(fmt.Errorf("unexpected value type: %T", actual)))
+		return []*FailedPredicateError{failedPredicate(fmt.Errorf("unexpected value type: %T", expected))}
 	}
 
 	return nil
 }
 
+// compareJSONValues compares an expected value to an actual value using the
+// JSON representation of the expected value.
+func compareJSONValues(expected, actual any, exact bool) []*FailedPredicateError {
+	b, err := json.Marshal(expected)
+	if err != nil {
+		return []*FailedPredicateError{failedPredicate(fmt.Errorf("failed to marshal expected %T: %w", expected, err))}
+	}
+
+	var ev any
+	if err := json.Unmarshal(b, &ev); err != nil {
+		return []*FailedPredicateError{failedPredicate(fmt.Errorf("failed to unmarshal expected %T: %w", expected, err))}
+	}
+
+	return CompareValues(ev, actual, exact)
+}
+
 // compareBoolValues compares an expected bool to an actual bool.
 func compareBoolValues(expected bool, actual any) *FailedPredicateError {
 	b, ok := actual.(bool)
@@ -314,37 +396,58 @@ func compareBoolValues(expected bool, actual any) *FailedPredicateError {
 	return nil
 }
 
-// compareFloat64Values compares an expected float64 to an actual float64.
-func compareFloat64Values(expected float64, actual any) *FailedPredicateError {
-	n, ok := actual.(float64)
-	if !ok {
+// compareNumericValues compares an expected number of any kind to an actual
+// number of any kind. Integers are compared exactly, and other numbers are
+// compared as float64 values.
+func compareNumericValues(expected, actual any) *FailedPredicateError {
+	if actual == nil {
 		return wrongTypeError(expected, actual)
 	}
 
-	if n != expected {
+	e, a := reflect.ValueOf(expected), reflect.ValueOf(actual)
+	if !isNumber(a) {
+		return wrongTypeError(expected, actual)
+	}
+
+	var equal bool
+	switch {
+	case isInt(e) && isInt(a):
+		equal = e.Int() == a.Int()
+	case isUint(e) && isUint(a):
+		equal = e.Uint() == a.Uint()
+	case isInt(e) && isUint(a):
+		equal = e.Int() >= 0 && uint64(e.Int()) == a.Uint()
+	case isUint(e) && isInt(a):
+		equal = a.Int() >= 0 && e.Uint() == uint64(a.Int())
+	default:
+		equal = toFloat64(e) == toFloat64(a)
+	}
+
+	if !equal {
 		return wrongValueError([]any{expected}, actual)
 	}
 
 	return nil
 }
 
-// compareInt64Values compares an expected int64 to an actual int64.
-func compareInt64Values(expected int64, actual any) *FailedPredicateError {
-	n, ok := actual.(int64)
-	if !ok {
-		f, ok := actual.(float64)
-		if !ok {
-			return wrongTypeError(expected, actual)
-		}
-
-		n, ok = floatToInt(f)
-		if !ok {
-			return wrongTypeError(expected, actual)
+// compareTimeValues compares an expected time to an actual time, or a string
+// containing an RFC 3339 time.
+func compareTimeValues(expected time.Time, actual any) *FailedPredicateError {
+	var t time.Time
+	switch value := actual.(type) {
+	case time.Time:
+		t = value
+	case string:
+		var err error
+		if t, err = time.Parse(time.RFC3339Nano, value); err != nil {
+			return failedPredicate(fmt.Errorf("expected RFC 3339 time %s, got %q", expected.Format(time.RFC3339Nano), value))
 		}
+	default:
+		return wrongTypeError(expected, actual)
 	}
 
-	if n != expected {
-		return wrongValueError([]any{expected}, actual)
+	if !t.Equal(expected) {
+		return wrongValueError([]any{expected.Format(time.RFC3339Nano)}, actual)
 	}
 
 	return nil
@@ -354,7 +457,7 @@ func compareInt64Values(expected int64, actual any) *FailedPredicateError {
 func compareMapValues(expected map[string]any, actual any, exact bool) []*FailedPredicateError {
 	errs := []*FailedPredicateError{}
 
-	m, ok := actual.(map[string]any)
+	m, ok := toMap(actual)
 	if !ok {
 		errs = append(errs, wrongTypeError(expected, actual))
 		return errs
@@ -405,7 +508,7 @@ func compareMapValues(expected map[string]any, actual any, exact bool) []*Failed
 func compareSliceValues(expected []any, actual any, exact bool) []*FailedPredicateError {
 	errs := []*FailedPredicateError{}
 
-	a, ok := actual.([]any)
+	a, ok := toSlice(actual)
 	if !ok {
 		errs = append(errs, wrongTypeError(expected, actual))
 		return errs
@@ -472,6 +575,9 @@ func toInt(v any) (int64, bool) {
 		return int64(v), true
 	case int32:
 		return int64(v), true
+	case uint, uint8, uint16, uint32, uint64:
+		u := reflect.ValueOf(v).Uint()
+		return int64(u), u <= math.MaxInt64
 	default:
 		return 0, false
 	}
@@ -491,3 +597,92 @@ func toFloat(v any) (float64, bool) {
 
 	return 0, false
 }
+
+func isInt(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	default:
+		return false
+	}
+}
+
+func isUint(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	default:
+		return false
+	}
+}
+
+func isNumber(v reflect.Value) bool {
+	return isInt(v) || isUint(v) || v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64
+}
+
+func toFloat64(v reflect.Value) float64 {
+	switch {
+	case isInt(v):
+		return float64(v.Int())
+	case isUint(v):
+		return float64(v.Uint())
+	default:
+		return v.Float()
+	}
+}
+
+// toMap converts a map with keys of any string, integer, or text-marshalable
+// type to a map[string]any.
+func toMap(v any) (map[string]any, bool) {
+	if m, ok := v.(map[string]any); ok {
+		return m, true
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map {
+		return nil, false
+	}
+
+	m := make(map[string]any, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		var key string
+		switch k := iter.Key().Interface().(type) {
+		case encoding.TextMarshaler:
+			b, err := k.MarshalText()
+			if err != nil {
+				return nil, false
+			}
+			key = string(b)
+		default:
+			kv := iter.Key()
+			if kv.Kind() != reflect.String && !isInt(kv) && !isUint(kv) {
+				return nil, false
+			}
+			key = fmt.Sprint(k)
+		}
+		m[key] = iter.Value().Interface()
+	}
+
+	return m, true
+}
+
+// toSlice converts a slice or array of any type, other than a byte slice, to
+// a []any.
+func toSlice(v any) ([]any, bool) {
+	if s, ok := v.([]any); ok {
+		return s, true
+	}
+
+	rv := reflect.ValueOf(v)
+	if (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false
+	}
+
+	s := make([]any, rv.Len())
+	for i := range s {
+		s[i] = rv.Index(i).Interface()
+	}
+
+	return s, true
+}
@@ -0,0 +1,280 @@
+package expect_test
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jefflinse/melatonin/expect"
+	mtjson "github.com/jefflinse/melatonin/json"
+	"github.com/stretchr/testify/assert"
+)
+
+// messages returns the sorted messages of a list of failures, or nil if there
+// are none.
+func messages(errs []*expect.FailedPredicateError) []string {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	sort.Strings(msgs)
+
+	return msgs
+}
+
+type level int
+
+type key string
+
+type user struct {
+	Name  string   `json:"name"`
+	Admin bool     `json:"admin,omitempty"`
+	Tags  []string `json:"tags"`
+}
+
+type version struct {
+	major, minor int
+}
+
+func (v version) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]int{"major": v.major, "minor": v.minor})
+}
+
+func TestCompareValues(t *testing.T) {
+	name := "Ada"
+	admin := true
+	var nilUser *user
+	created := time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, test := range []struct {
+		name     string
+		expected any
+		actual   any
+		exact    bool
+		want     []string
+	}{
+		{
+			name:     "nil",
+			expected: nil,
+			actual:   nil,
+		},
+		{
+			name:     "unexpected non-nil",
+			expected: nil,
+			actual:   "Ada",
+			want:     []string{": expected nil, got string: Ada"},
+		},
+		{
+			name:     "string",
+			expected: "Ada",
+			actual:   "Ada",
+		},
+		{
+			name:     "unexpected string",
+			expected: "Ada",
+			actual:   "Grace",
+			want:     []string{": expected Ada, got Grace"},
+		},
+		{
+			name:     "missing string",
+			expected: "Ada",
+			actual:   nil,
+			want:     []string{": expected string, got nothing"},
+		},
+		{
+			name:     "string pointer",
+			expected: &name,
+			actual:   "Ada",
+		},
+		{
+			name:     "bool pointer",
+			expected: &admin,
+			actual:   false,
+			want:     []string{": expected true, got false"},
+		},
+		{
+			name:     "bytes",
+			expected: []byte("Ada"),
+			actual:   "Ada",
+		},
+		{
+			name:     "named string type",
+			expected: key("id"),
+			actual:   "id",
+		},
+		{
+			name:     "int against float64",
+			expected: 42,
+			actual:   float64(42),
+		},
+		{
+			name:     "uint8 against int64",
+			expected: uint8(255),
+			actual:   int64(255),
+		},
+		{
+			name:     "named int type",
+			expected: level(3),
+			actual:   float64(3),
+		},
+		{
+			name:     "float32",
+			expected: float32(1.5),
+			actual:   float64(1.5),
+		},
+		{
+			name:     "negative int against uint",
+			expected: -1,
+			actual:   uint(1),
+			want:     []string{": expected -1, got 1"},
+		},
+		{
+			name:     "unexpected number",
+			expected: int64(42),
+			actual:   float64(42.5),
+			want:     []string{": expected 42, got 42.5"},
+		},
+		{
+			name:     "number against string",
+			expected: 42,
+			actual:   "42",
+			want:     []string{": expected type int, got string: 42"},
+		},
+		{
+			name:     "typed slice",
+			expected: []string{"a", "b"},
+			actual:   []any{"a", "b", "c"},
+		},
+		{
+			name:     "typed slice exactly",
+			expected: []int{1, 2},
+			actual:   []any{float64(1), float64(2), float64(3)},
+			exact:    true,
+			want:     []string{": expected 2 elements, got 3: [\n  1,\n  2,\n  3\n]"},
+		},
+		{
+			name:     "array",
+			expected: [2]int{1, 2},
+			actual:   []any{float64(1), float64(3)},
+			want:     []string{"[1]: expected 2, got 3"},
+		},
+		{
+			name:     "too few elements",
+			expected: []any{"a", "b"},
+			actual:   []any{"a"},
+			want:     []string{": expected at least 2 elements, got 1: [\n  \"a\"\n]"},
+		},
+		{
+			name:     "typed map",
+			expected: map[string]int{"a": 1, "b": 2},
+			actual:   map[string]any{"a": float64(1), "b": float64(3)},
+			want:     []string{"b: expected 2, got 3"},
+		},
+		{
+			name:     "integer keys",
+			expected: map[int]string{1: "a"},
+			actual:   map[string]any{"1": "a"},
+		},
+		{
+			name:     "unsupported map keys",
+			expected: map[[2]int]string{{1, 2}: "a"},
+			actual:   map[string]any{},
+			want:     []string{": unsupported map key type in map[[2]int]string"},
+		},
+		{
+			name:     "nested object",
+			expected: mtjson.Object{"user": mtjson.Object{"tags": mtjson.Array{"a", "c"}}},
+			actual:   map[string]any{"user": map[string]any{"tags": []any{"a", "b"}}},
+			want:     []string{"user.tags[1]: expected c, got b"},
+		},
+		{
+			name:     "missing field",
+			expected: map[string]any{"name": "Ada", "email": "ada@example.com"},
+			actual:   map[string]any{"name": "Ada"},
+			want:     []string{"email: expected string, got nothing"},
+		},
+		{
+			name:     "unexpected fields",
+			expected: map[string]any{"name": "Ada"},
+			actual:   map[string]any{"name": "Ada", "admin": true},
+			exact:    true,
+			want:     []string{": expected 1 fields, got 2:\n{\n  \"admin\": true,\n  \"name\": \"Ada\"\n}"},
+		},
+		{
+			name:     "unexpected key",
+			expected: map[string]any{"name": "Ada"},
+			actual:   map[string]any{"nom": "Ada"},
+			exact:    true,
+			want:     []string{`: expected key "name", got "nom": Ada`, "name: expected string, got nothing"},
+		},
+		{
+			name:     "struct",
+			expected: user{Name: "Ada", Tags: []string{"a"}},
+			actual:   map[string]any{"name": "Ada", "tags": []any{"a"}},
+			exact:    true,
+		},
+		{
+			name:     "unexpected struct field",
+			expected: &user{Name: "Ada", Admin: true, Tags: []string{}},
+			actual:   map[string]any{"name": "Ada", "admin": false, "tags": []any{}},
+			want:     []string{"admin: expected true, got false"},
+		},
+		{
+			name:     "nil struct pointer",
+			expected: nilUser,
+			actual:   map[string]any{},
+			want:     []string{": expected nil, got map[string]interface {}: map[]"},
+		},
+		{
+			name:     "JSON marshaler",
+			expected: version{1, 2},
+			actual:   map[string]any{"major": float64(1), "minor": float64(3)},
+			want:     []string{"minor: expected 2, got 3"},
+		},
+		{
+			name:     "time against RFC 3339 string",
+			expected: created,
+			actual:   "2022-07-01T14:00:00+02:00",
+		},
+		{
+			name:     "unexpected time",
+			expected: created,
+			actual:   "2022-07-01T12:00:01Z",
+			want:     []string{": expected 2022-07-01T12:00:00Z, got 2022-07-01T12:00:01Z"},
+		},
+		{
+			name:     "invalid time",
+			expected: created,
+			actual:   "yesterday",
+			want:     []string{`: expected RFC 3339 time 2022-07-01T12:00:00Z, got "yesterday"`},
+		},
+		{
+			name:     "predicate",
+			expected: expect.String("Ada", "Grace"),
+			actual:   "Alan",
+			want:     []string{": expected one of [Ada Grace], got \"Alan\""},
+		},
+		{
+			name:     "predicate function",
+			expected: func(any) error { return errors.New("nope") },
+			actual:   "Ada",
+			want:     []string{": nope"},
+		},
+		{
+			name:     "unsupported expectation",
+			expected: make(chan int),
+			actual:   "Ada",
+			want:     []string{": unexpected value type: chan int"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, messages(expect.CompareValues(test.expected, test.actual, test.exact)))
+		})
+	}
+}
@@ -64,3 +64,17 @@ func wrongValueError(expected []any, actual any) *FailedPredicateError {
 
 	return failedPredicate(errors.New(msg))
 }
+
+// FailedPredicateErrors is a list of failures reported by a single predicate,
+// such as one for each violation of a schema. CompareValues reports each
+// failure separately.
+type FailedPredicateErrors []*FailedPredicateError
+
+func (e FailedPredicateErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
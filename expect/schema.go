@@ -0,0 +1,57 @@
+package expect
+
+import (
+	"errors"
+	"sync"
+
+	mtjson "github.com/jefflinse/melatonin/json"
+)
+
+// Schema creates a predicate requiring a value to conform to a JSON Schema
+// (draft 2020-12). The schema may be the path of a schema file, a compiled
+// *json.Schema, or a schema document, such as a map or JSON text as a []byte.
+// Schema files are loaded from the schema directory set by json.SetSchemaDir:
+// relative paths, and references to schema files from schema documents, are
+// resolved against it, and only files within it may be loaded. The mt package
+// sets the schema directory to its working directory, which is the current
+// directory unless MELATONIN_WORKDIR is set.
+//
+// Each violation is reported as a separate FailedPredicateError, located at
+// the violating value.
+func Schema(schema any) Predicate {
+	var once sync.Once
+	var compiled *mtjson.Schema
+	var err error
+
+	return func(actual any) error {
+		once.Do(func() {
+			switch v := schema.(type) {
+			case *mtjson.Schema:
+				compiled = v
+			case string:
+				compiled, err = mtjson.LoadSchema(v)
+			default:
+				compiled, err = mtjson.CompileSchema(v, "")
+			}
+		})
+
+		if err != nil {
+			return err
+		}
+
+		violations := compiled.Validate(actual)
+		if len(violations) == 0 {
+			return nil
+		}
+
+		errs := make(FailedPredicateErrors, len(violations))
+		for i, v := range violations {
+			errs[i] = &FailedPredicateError{
+				Cause:      errors.New(v.Message),
+				FieldStack: append([]string{}, v.Fields...),
+			}
+		}
+
+		return errs
+	}
+}
@@ -0,0 +1,141 @@
+package expect_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jefflinse/melatonin/expect"
+	mtjson "github.com/jefflinse/melatonin/json"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchema(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"user.json":   `{"type": "object", "required": ["id"], "properties": {"id": {"$ref": "common.json#/$defs/id"}}}`,
+		"common.json": `{"$defs": {"id": {"type": "integer", "minimum": 1}}}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	setSchemaDir(t, dir)
+
+	outside := filepath.Join(t.TempDir(), "user.json")
+	if err := os.WriteFile(outside, []byte(`{"type": "object"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tags := map[string]any{
+		"type":     "object",
+		"required": []any{"id"},
+		"properties": map[string]any{
+			"tags": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		},
+	}
+
+	compiled, err := mtjson.CompileSchema(tags, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name   string
+		schema any
+		actual any
+		want   []string
+	}{
+		{
+			name:   "map",
+			schema: tags,
+			actual: map[string]any{"id": 1.0, "tags": []any{"a"}},
+		},
+		{
+			name:   "violations reported separately",
+			schema: tags,
+			actual: map[string]any{"tags": []any{"a", 1.0}},
+			want: []string{
+				"id: expected required field, got nothing",
+				"tags[1]: expected type string, got integer",
+			},
+		},
+		{
+			name:   "JSON text",
+			schema: []byte(`{"type": "string", "maxLength": 3}`),
+			actual: "Ada",
+		},
+		{
+			name:   "compiled schema",
+			schema: compiled,
+			actual: map[string]any{"id": 1.0, "tags": "a"},
+			want:   []string{"tags: expected type array, got string"},
+		},
+		{
+			name:   "schema file with references",
+			schema: "user.json",
+			actual: map[string]any{"id": 0.0},
+			want:   []string{"id: expected at least 1, got 0"},
+		},
+		{
+			name:   "schema document with references",
+			schema: map[string]any{"$ref": "common.json#/$defs/id"},
+			actual: 0.0,
+			want:   []string{": expected at least 1, got 0"},
+		},
+		{
+			name:   "schema file outside the schema directory",
+			schema: outside,
+			actual: map[string]any{},
+			want:   []string{fmt.Sprintf(`: schema %q: outside of schema directory %q`, outside, dir)},
+		},
+		{
+			name:   "missing schema file",
+			schema: filepath.Join(dir, "missing.json"),
+			actual: map[string]any{},
+			want: []string{fmt.Sprintf(`: schema %[1]q: open %[1]s: no such file or directory`,
+				filepath.Join(dir, "missing.json"))},
+		},
+		{
+			name:   "invalid JSON text",
+			schema: []byte(`{"type": `),
+			actual: map[string]any{},
+			want:   []string{": schema: unexpected end of JSON input"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, messages(expect.CompareValues(expect.Schema(test.schema), test.actual, false)))
+		})
+	}
+}
+
+func TestSchemaLoadedOnce(t *testing.T) {
+	dir := t.TempDir()
+	setSchemaDir(t, dir)
+
+	path := filepath.Join(dir, "schema.json")
+	if err := os.WriteFile(path, []byte(`{"type": "integer"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	schema := expect.Schema(path)
+	assert.NoError(t, schema(1.0))
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+
+	err := schema("1")
+	if assert.Error(t, err) {
+		assert.Equal(t, ": expected type integer, got string", err.Error())
+	}
+}
+
+func setSchemaDir(t *testing.T, dir string) {
+	previous := mtjson.SchemaDir()
+	mtjson.SetSchemaDir(dir)
+	t.Cleanup(func() { mtjson.SetSchemaDir(previous) })
+}
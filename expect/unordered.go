@@ -0,0 +1,221 @@
+package expect
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ElementsMatch creates a predicate requiring a value to be a slice whose
+// elements match the expected values in any order. Each expected value must
+// match a different element, and every element must be matched.
+//
+// Expected values may be anything accepted by CompareValues, and objects are
+// matched as subsets of the elements. Each unmatched expected value and each
+// unclaimed element is reported as a separate failure.
+func ElementsMatch(expected ...any) Predicate {
+	return unorderedElements(expected, true)
+}
+
+// ContainsElements creates a predicate requiring a value to be a slice
+// containing elements that match the expected values in any order. Each
+// expected value must match a different element, and other elements are
+// ignored.
+//
+// Expected values may be anything accepted by CompareValues, and objects are
+// matched as subsets of the elements. Each unmatched expected value is
+// reported as a separate failure.
+func ContainsElements(expected ...any) Predicate {
+	return unorderedElements(expected, false)
+}
+
+// ContainsElement creates a predicate requiring a value to be a slice with at
+// least one element matching an expected value, which may be anything
+// accepted by CompareValues.
+func ContainsElement(expected any) Predicate {
+	return func(actual any) error {
+		a, ok := toSlice(actual)
+		if !ok {
+			return fmt.Errorf("expected slice, got %T: %+v", actual, actual)
+		}
+
+		for _, v := range a {
+			if len(CompareValues(expected, v, false)) == 0 {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("expected an element matching %s, got none", describeExpected(expected))
+	}
+}
+
+// NoElementMatches creates a predicate requiring a value to be a slice with no
+// elements matching an expected value, which may be anything accepted by
+// CompareValues. Each matching element is reported as a separate failure.
+func NoElementMatches(expected any) Predicate {
+	return func(actual any) error {
+		a, ok := toSlice(actual)
+		if !ok {
+			return fmt.Errorf("expected slice, got %T: %+v", actual, actual)
+		}
+
+		var errs FailedPredicateErrors
+		for i, v := range a {
+			if len(CompareValues(expected, v, false)) == 0 {
+				err := failedPredicate(fmt.Errorf("expected no element matching %s, got %s", describeExpected(expected), describeExpected(v)))
+				err.PushField(fmt.Sprintf("[%d]", i))
+				errs = append(errs, err)
+			}
+		}
+
+		if len(errs) > 0 {
+			return errs
+		}
+
+		return nil
+	}
+}
+
+// unorderedElements matches expected values to the elements of a slice,
+// pairing them so that as many expected values as possible are matched.
+func unorderedElements(expected []any, exact bool) Predicate {
+	return func(actual any) error {
+		a, ok := toSlice(actual)
+		if !ok {
+			return fmt.Errorf("expected slice, got %T: %+v", actual, actual)
+		}
+
+		matches := make([][]bool, len(expected))
+		for i, e := range expected {
+			matches[i] = make([]bool, len(a))
+			for j, v := range a {
+				matches[i][j] = len(CompareValues(e, v, false)) == 0
+			}
+		}
+
+		claimedBy := matchElements(matches, len(a))
+		matched := make([]bool, len(expected))
+		for _, i := range claimedBy {
+			if i >= 0 {
+				matched[i] = true
+			}
+		}
+
+		var errs FailedPredicateErrors
+		for i, e := range expected {
+			if matched[i] {
+				continue
+			}
+
+			msg := "expected an element matching %s, got none"
+			for _, m := range matches[i] {
+				if m {
+					msg = "expected an unclaimed element matching %s, got none"
+					break
+				}
+			}
+			errs = append(errs, failedPredicate(fmt.Errorf(msg, describeExpected(e))))
+		}
+
+		if exact {
+			for j, i := range claimedBy {
+				if i < 0 {
+					err := failedPredicate(fmt.Errorf("unexpected element %s", describeExpected(a[j])))
+					err.PushField(fmt.Sprintf("[%d]", j))
+					errs = append(errs, err)
+				}
+			}
+		}
+
+		if len(errs) > 0 {
+			return errs
+		}
+
+		return nil
+	}
+}
+
+// matchElements finds a maximum matching between expected values and
+// elements, given which elements each expected value matches. It returns the
+// index of the expected value claiming each element, or -1 for unclaimed
+// elements.
+func matchElements(matches [][]bool, n int) []int {
+	claimedBy := make([]int, n)
+	for j := range claimedBy {
+		claimedBy[j] = -1
+	}
+
+	var claim func(i int, visited []bool) bool
+	claim = func(i int, visited []bool) bool {
+		for j, m := range matches[i] {
+			if !m || visited[j] {
+				continue
+			}
+
+			visited[j] = true
+			if claimedBy[j] < 0 || claim(claimedBy[j], visited) {
+				claimedBy[j] = i
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for i := range matches {
+		claim(i, make([]bool, n))
+	}
+
+	return claimedBy
+}
+
+// describeExpected formats an expected or actual value for a failure message,
+// using its JSON representation where possible. Predicates are shown as
+// <predicate>.
+func describeExpected(v any) string {
+	v = describePredicates(v)
+	if s, ok := v.(string); ok && s == predicatePlaceholder {
+		return s
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprintf("%+v", v)
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+const predicatePlaceholder = "<predicate>"
+
+// describePredicates replaces the predicates within a value with a
+// placeholder, so that the value can be marshaled.
+func describePredicates(v any) any {
+	switch v.(type) {
+	case Predicate, func(any) error:
+		return predicatePlaceholder
+	case json.Marshaler:
+		return v
+	}
+
+	if m, ok := toMap(v); ok {
+		described := make(map[string]any, len(m))
+		for k, child := range m {
+			described[k] = describePredicates(child)
+		}
+		return described
+	}
+
+	if s, ok := toSlice(v); ok {
+		described := make([]any, len(s))
+		for i, child := range s {
+			described[i] = describePredicates(child)
+		}
+		return described
+	}
+
+	return v
+}
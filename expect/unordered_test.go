@@ -0,0 +1,138 @@
+package expect_test
+
+import (
+	"testing"
+
+	"github.com/jefflinse/melatonin/expect"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnorderedElements(t *testing.T) {
+	users := []any{
+		map[string]any{"id": 1.0, "name": "a", "admin": true},
+		map[string]any{"id": 2.0, "name": "b", "admin": false},
+		map[string]any{"id": 3.0, "name": "c", "admin": true},
+	}
+
+	for _, test := range []struct {
+		name     string
+		expected any
+		actual   any
+		want     []string
+	}{
+		{
+			name:     "elements in any order",
+			expected: expect.ElementsMatch(3, 1, 2),
+			actual:   []any{1.0, 2.0, 3.0},
+		},
+		{
+			name:     "objects matched as subsets",
+			expected: expect.ElementsMatch(map[string]any{"id": 3}, map[string]any{"name": "a"}, map[string]any{"id": 2}),
+			actual:   users,
+		},
+		{
+			name:     "every expected value matched",
+			expected: expect.ElementsMatch(expect.Float(), 1),
+			actual:   []any{1.0, 2.0},
+		},
+		{
+			name:     "missing and unexpected elements",
+			expected: expect.ElementsMatch(1, 4),
+			actual:   []any{1.0, 2.0},
+			want: []string{
+				": expected an element matching 4, got none",
+				"[1]: unexpected element 2",
+			},
+		},
+		{
+			name:     "element already claimed",
+			expected: expect.ElementsMatch(1, 1),
+			actual:   []any{1.0, 2.0},
+			want: []string{
+				": expected an unclaimed element matching 1, got none",
+				"[1]: unexpected element 2",
+			},
+		},
+		{
+			name:     "predicates described",
+			expected: expect.ElementsMatch(map[string]any{"id": expect.String()}),
+			actual:   []any{map[string]any{"id": 1.0}},
+			want: []string{
+				`: expected an element matching {"id":"<predicate>"}, got none`,
+				`[0]: unexpected element {"id":1}`,
+			},
+		},
+		{
+			name:     "elements of a nested slice",
+			expected: map[string]any{"tags": expect.ElementsMatch("b", "a")},
+			actual:   map[string]any{"tags": []any{"a", "b", "c"}},
+			want:     []string{`tags[2]: unexpected element "c"`},
+		},
+		{
+			name:     "elements of a non-slice",
+			expected: expect.ElementsMatch(1),
+			actual:   "1",
+			want:     []string{": expected slice, got string: 1"},
+		},
+		{
+			name:     "contains elements",
+			expected: expect.ContainsElements(map[string]any{"name": "c"}, map[string]any{"admin": true}),
+			actual:   users,
+		},
+		{
+			name:     "contains typed elements",
+			expected: expect.ContainsElements(2),
+			actual:   []int{1, 2, 3},
+		},
+		{
+			name:     "missing elements",
+			expected: expect.ContainsElements(2, 5, map[string]any{"id": 6}),
+			actual:   []any{1.0, 2.0, 3.0},
+			want: []string{
+				": expected an element matching 5, got none",
+				`: expected an element matching {"id":6}, got none`,
+			},
+		},
+		{
+			name:     "contains element",
+			expected: expect.ContainsElement(map[string]any{"name": "b"}),
+			actual:   users,
+		},
+		{
+			name:     "missing element",
+			expected: expect.ContainsElement(map[string]any{"name": "d"}),
+			actual:   users,
+			want:     []string{`: expected an element matching {"name":"d"}, got none`},
+		},
+		{
+			name:     "element of a non-slice",
+			expected: expect.ContainsElement(1),
+			actual:   map[string]any{},
+			want:     []string{": expected slice, got map[string]interface {}: map[]"},
+		},
+		{
+			name:     "no matching elements",
+			expected: expect.NoElementMatches(map[string]any{"name": "d"}),
+			actual:   users,
+		},
+		{
+			name:     "matching elements",
+			expected: expect.NoElementMatches(map[string]any{"admin": true}),
+			actual:   users,
+			want: []string{
+				`[0]: expected no element matching {"admin":true}, got {"admin":true,"id":1,"name":"a"}`,
+				`[2]: expected no element matching {"admin":true}, got {"admin":true,"id":3,"name":"c"}`,
+			},
+		},
+		{
+			name:     "no matching elements of a non-slice",
+			expected: expect.NoElementMatches(1),
+			actual:   nil,
+			want:     []string{": expected slice, got <nil>: <nil>"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, messages(expect.CompareValues(test.expected, test.actual, false)))
+		})
+	}
+}
@@ -19,6 +19,11 @@ type Golden struct {
 	// WantStatus is the expected response status code.
 	WantStatus int
 
+	// WantStatusPattern is a pattern matching the expected response status
+	// code, such as "2xx" or "200|204", if the golden file doesn't specify a
+	// single status code. See ParseStatusPattern.
+	WantStatusPattern string
+
 	// WantHeaders are the expected response headers.
 	//
 	// If MatchHeadersExactly is set to true, any unexpected headers will cause
@@ -65,7 +70,7 @@ func LoadFile(path string) (*Golden, error) {
 	golden := &Golden{}
 	var headersLines, bodyLines []string
 	var target *[]string
-	var foundHeaders, foundBody, bodyIsJSON bool
+	var foundStatus, foundHeaders, foundBody, bodyIsJSON bool
 
 	scanner := bufio.NewScanner(f)
 	matcher := search.New(language.English, search.IgnoreCase)
@@ -78,10 +83,11 @@ func LoadFile(path string) (*Golden, error) {
 		}
 
 		// status must be the first non-empty line encountered
-		if golden.WantStatus == 0 {
+		if !foundStatus {
 			if err := golden.parseStatusLine(line); err != nil {
 				return nil, newGoldenFileError(path, err)
 			}
+			foundStatus = true
 			continue
 		}
 
@@ -132,7 +138,7 @@ func LoadFile(path string) (*Golden, error) {
 		return nil, newGoldenFileError(path, err)
 	}
 
-	if golden.WantStatus == 0 && golden.WantHeaders == nil && golden.WantBody == nil {
+	if golden.WantStatus == 0 && golden.WantStatusPattern == "" && golden.WantHeaders == nil && golden.WantBody == nil {
 		return nil, newGoldenFileError(path, fmt.Errorf("no expected status, headers, or body specified"))
 	}
 
@@ -141,11 +147,16 @@ func LoadFile(path string) (*Golden, error) {
 
 // SaveFile saves a golden file to the given path.
 func (g *Golden) SaveFile(path string) error {
-	if g.WantStatus == 0 {
+	status := g.WantStatusPattern
+	if g.WantStatus != 0 {
+		status = strconv.Itoa(g.WantStatus)
+	} else if status == "" {
 		return newGoldenFileError(path, fmt.Errorf("expected status is required"))
+	} else if _, err := ParseStatusPattern(status); err != nil {
+		return newGoldenFileError(path, err)
 	}
 
-	lines := []string{fmt.Sprintf("%d", g.WantStatus)}
+	lines := []string{status}
 
 	if g.WantHeaders != nil {
 		headersDirectives := []string{headersLinePrefix}
@@ -199,15 +210,68 @@ func (g *Golden) SaveFile(path string) error {
 }
 
 func (g *Golden) parseStatusLine(line string) error {
-	var err error
-	g.WantStatus, err = strconv.Atoi(line)
-	if err != nil {
-		return fmt.Errorf("invalid status %q", line)
+	line = strings.TrimSpace(line)
+	if status, err := strconv.Atoi(line); err == nil {
+		g.WantStatus = status
+		return nil
 	}
 
+	if _, err := ParseStatusPattern(line); err != nil {
+		return err
+	}
+
+	g.WantStatusPattern = line
 	return nil
 }
 
+// A StatusRange is an inclusive range of HTTP status codes.
+type StatusRange struct {
+	Min int
+	Max int
+}
+
+// ParseStatusPattern parses a status pattern into the ranges of status codes
+// it matches. A pattern is one or more alternatives separated by "|", each of
+// which is a status code such as "204", a status class such as "2xx", or an
+// inclusive range such as "200-299".
+func ParseStatusPattern(pattern string) ([]StatusRange, error) {
+	var ranges []StatusRange
+	for _, alt := range strings.Split(pattern, "|") {
+		alt = strings.TrimSpace(alt)
+		if len(alt) == 3 && alt[0] >= '1' && alt[0] <= '9' && strings.EqualFold(alt[1:], "xx") {
+			class := int(alt[0]-'0') * 100
+			ranges = append(ranges, StatusRange{Min: class, Max: class + 99})
+			continue
+		}
+
+		lo, hi, isRange := strings.Cut(alt, "-")
+		min, err := parseStatusCode(lo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid status %q", pattern)
+		}
+
+		max := min
+		if isRange {
+			if max, err = parseStatusCode(hi); err != nil || max < min {
+				return nil, fmt.Errorf("invalid status %q", pattern)
+			}
+		}
+
+		ranges = append(ranges, StatusRange{Min: min, Max: max})
+	}
+
+	return ranges, nil
+}
+
+func parseStatusCode(s string) (int, error) {
+	status, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || status < 100 || status > 999 {
+		return 0, fmt.Errorf("invalid status code %q", s)
+	}
+
+	return status, nil
+}
+
 func (g *Golden) parseHeaderDirectives(line string) error {
 	headersDirectives := strings.Split(line, " ")
 	for _, directive := range headersDirectives[2:] {
@@ -24,6 +24,23 @@ func TestLoadFile(t *testing.T) {
 				WantStatus: 200,
 			},
 		},
+		{
+			name:    "success, status class specified",
+			content: "2xx",
+			wantGolden: &golden.Golden{
+				WantStatusPattern: "2xx",
+			},
+		},
+		{
+			name:    "success, status set specified with headers",
+			content: "200|204\n--- headers\nSome-Header: foo",
+			wantGolden: &golden.Golden{
+				WantStatusPattern: "200|204",
+				WantHeaders: http.Header{
+					"Some-Header": []string{"foo"},
+				},
+			},
+		},
 		{
 			name:    "success, status and headers specified",
 			content: "200\n--- headers\nSome-Header: foo\nContent-Type: application/json\nSome-Header: bar",
@@ -126,6 +143,11 @@ func TestLoadFile(t *testing.T) {
 			content:   "foo",
 			wantError: `invalid status "foo"`,
 		},
+		{
+			name:      "failure, invalid status pattern",
+			content:   "2xy|204",
+			wantError: `invalid status "2xy|204"`,
+		},
 		{
 			name:      "failure, headers before status",
 			content:   "--- headers\nContent-Type: application/xml",
@@ -226,6 +248,12 @@ func TestSaveFile(t *testing.T) {
 				WantStatus: 200,
 			},
 		},
+		{
+			name: "success, status pattern only",
+			g: &golden.Golden{
+				WantStatusPattern: "2xx|304",
+			},
+		},
 		{
 			name: "success, status and headers",
 			g: &golden.Golden{
@@ -285,6 +313,13 @@ func TestSaveFile(t *testing.T) {
 			g:         &golden.Golden{},
 			wantError: `expected status is required`,
 		},
+		{
+			name: "failure, invalid status pattern",
+			g: &golden.Golden{
+				WantStatusPattern: "200-",
+			},
+			wantError: `invalid status "200-"`,
+		},
 		{
 			name: "failure, invalid body JSON",
 			g: &golden.Golden{
@@ -330,3 +365,32 @@ func TestSaveFile(t *testing.T) {
 		})
 	}
 }
+
+func TestParseStatusPattern(t *testing.T) {
+	for _, test := range []struct {
+		pattern    string
+		wantRanges []golden.StatusRange
+		wantError  string
+	}{
+		{pattern: "200", wantRanges: []golden.StatusRange{{Min: 200, Max: 200}}},
+		{pattern: "2xx", wantRanges: []golden.StatusRange{{Min: 200, Max: 299}}},
+		{pattern: "4XX", wantRanges: []golden.StatusRange{{Min: 400, Max: 499}}},
+		{pattern: "200-204", wantRanges: []golden.StatusRange{{Min: 200, Max: 204}}},
+		{pattern: "200 | 204|3xx", wantRanges: []golden.StatusRange{{Min: 200, Max: 200}, {Min: 204, Max: 204}, {Min: 300, Max: 399}}},
+		{pattern: "", wantError: `invalid status ""`},
+		{pattern: "0xx", wantError: `invalid status "0xx"`},
+		{pattern: "99", wantError: `invalid status "99"`},
+		{pattern: "204-200", wantError: `invalid status "204-200"`},
+		{pattern: "200||204", wantError: `invalid status "200||204"`},
+	} {
+		t.Run(test.pattern, func(t *testing.T) {
+			ranges, err := golden.ParseStatusPattern(test.pattern)
+			if test.wantError != "" {
+				assert.EqualError(t, err, test.wantError)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.wantRanges, ranges)
+			}
+		})
+	}
+}
@@ -0,0 +1,329 @@
+package json
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Fake data generators produce deferred values, which are resolved each time a
+// test case using them is executed. Each generator draws from its own source
+// of randomness, derived from the seed and the order in which generators are
+// created, so a run can be reproduced by using the same seed. The seed is read
+// from the MELATONIN_SEED environment variable, is random if it isn't set, and
+// can be changed with SetSeed.
+
+var (
+	seed       = time.Now().UnixNano()
+	generators int64
+	seedMu     sync.Mutex
+)
+
+func init() {
+	if seedStr := os.Getenv("MELATONIN_SEED"); seedStr != "" {
+		if s, err := strconv.ParseInt(seedStr, 10, 64); err == nil {
+			seed = s
+		} else {
+			fmt.Fprintf(os.Stderr, "invalid MELATONIN_SEED value %q in environment, using random seed %d\n",
+				seedStr, seed)
+		}
+	}
+}
+
+// Seed returns the seed used by fake data generators.
+func Seed() int64 {
+	seedMu.Lock()
+	defer seedMu.Unlock()
+	return seed
+}
+
+// SetSeed sets the seed used by fake data generators created from now on.
+func SetSeed(s int64) {
+	seedMu.Lock()
+	defer seedMu.Unlock()
+	seed = s
+	generators = 0
+}
+
+// lockedRand is a source of randomness that is safe for concurrent use.
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func newRand() *lockedRand {
+	seedMu.Lock()
+	defer seedMu.Unlock()
+	generators++
+	return &lockedRand{r: rand.New(rand.NewSource(seed + generators))}
+}
+
+func (r *lockedRand) with(f func(r *rand.Rand) any) any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return f(r.r)
+}
+
+var (
+	firstNames = []string{
+		"Ada", "Alan", "Barbara", "Charles", "Dennis", "Edsger", "Frances", "Grace",
+		"Hedy", "Ivan", "Jean", "John", "Ken", "Linus", "Margaret", "Niklaus",
+		"Radia", "Rob", "Sophie", "Tim",
+	}
+	lastNames = []string{
+		"Allen", "Babbage", "Berners-Lee", "Dijkstra", "Hamilton", "Hopper", "Kernighan", "Knuth",
+		"Lamarr", "Liskov", "Lovelace", "McCarthy", "Perlman", "Pike", "Ritchie", "Sutherland",
+		"Thompson", "Torvalds", "Turing", "Wirth",
+	}
+)
+
+// UUIDv4 returns a deferred value that generates a random version 4 UUID.
+func UUIDv4() func() any {
+	r := newRand()
+	return func() any {
+		return r.with(func(r *rand.Rand) any {
+			var b [16]byte
+			r.Read(b[:])
+			b[6] = b[6]&0x0f | 0x40
+			b[8] = b[8]&0x3f | 0x80
+			return formatUUID(b)
+		})
+	}
+}
+
+// UUIDv7 returns a deferred value that generates a version 7 UUID, which is
+// ordered by the time it is generated.
+func UUIDv7() func() any {
+	r := newRand()
+	return func() any {
+		return r.with(func(r *rand.Rand) any {
+			var b [16]byte
+			r.Read(b[6:])
+			ms := uint64(time.Now().UnixMilli())
+			for i := 0; i < 6; i++ {
+				b[i] = byte(ms >> (40 - 8*i))
+			}
+			b[6] = b[6]&0x0f | 0x70
+			b[8] = b[8]&0x3f | 0x80
+			return formatUUID(b)
+		})
+	}
+}
+
+func formatUUID(b [16]byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// Name returns a deferred value that generates a person's full name.
+func Name() func() any {
+	r := newRand()
+	return func() any {
+		return r.with(func(r *rand.Rand) any {
+			return firstNames[r.Intn(len(firstNames))] + " " + lastNames[r.Intn(len(lastNames))]
+		})
+	}
+}
+
+// Email returns a deferred value that generates an email address at the
+// example.com domain, with a random suffix to keep addresses unique.
+func Email() func() any {
+	r := newRand()
+	return func() any {
+		return r.with(func(r *rand.Rand) any {
+			return fmt.Sprintf("%s.%s.%08x@example.com",
+				strings.ToLower(firstNames[r.Intn(len(firstNames))]),
+				strings.ToLower(lastNames[r.Intn(len(lastNames))]),
+				r.Uint32())
+		})
+	}
+}
+
+// Sequence returns a deferred value that generates consecutive integers,
+// beginning with start.
+func Sequence(start int) func() any {
+	var mu sync.Mutex
+	next := start
+	return func() any {
+		mu.Lock()
+		defer mu.Unlock()
+		n := next
+		next++
+		return n
+	}
+}
+
+// IntBetween returns a deferred value that generates a random integer in the
+// range [lo, hi].
+func IntBetween(lo, hi int) func() any {
+	r := newRand()
+	return func() any {
+		return r.with(func(r *rand.Rand) any {
+			if hi <= lo {
+				return lo
+			}
+			return lo + int(r.Int63n(int64(hi)-int64(lo)+1))
+		})
+	}
+}
+
+// FloatBetween returns a deferred value that generates a random float in the
+// range [lo, hi).
+func FloatBetween(lo, hi float64) func() any {
+	r := newRand()
+	return func() any {
+		return r.with(func(r *rand.Rand) any {
+			return lo + r.Float64()*(hi-lo)
+		})
+	}
+}
+
+// Enum returns a deferred value that picks one of the given values at random.
+// The picked value may itself be a deferred value, which is resolved.
+func Enum(values ...any) func() (any, error) {
+	r := newRand()
+	return func() (any, error) {
+		if len(values) == 0 {
+			return nil, errors.New("no values to pick from")
+		}
+
+		picked := r.with(func(r *rand.Rand) any {
+			return values[r.Intn(len(values))]
+		})
+		return ResolveDeferred(picked)
+	}
+}
+
+// Timestamp returns a deferred value that generates an RFC 3339 timestamp,
+// offset from the time it is generated by the given duration.
+func Timestamp(offset time.Duration) func() any {
+	return func() any {
+		return time.Now().Add(offset).UTC().Format(time.RFC3339)
+	}
+}
+
+// maxPatternRepeat limits the number of repetitions generated for unbounded
+// pattern operators, such as * and +.
+const maxPatternRepeat = 10
+
+// Pattern returns a deferred value that generates a random string matching a
+// regular expression, using the same syntax as the regexp package. Unbounded
+// repetitions are limited to 10 occurrences. An invalid pattern is reported
+// when the value is resolved.
+func Pattern(pattern string) func() (any, error) {
+	r := newRand()
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err == nil {
+		re = re.Simplify()
+	}
+
+	return func() (any, error) {
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", pattern, err)
+		}
+
+		return r.with(func(r *rand.Rand) any {
+			sb := &strings.Builder{}
+			generatePattern(sb, re, r)
+			return sb.String()
+		}), nil
+	}
+}
+
+func generatePattern(sb *strings.Builder, re *syntax.Regexp, r *rand.Rand) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		for _, c := range re.Rune {
+			if re.Flags&syntax.FoldCase != 0 && r.Intn(2) == 0 {
+				c = unicode.SimpleFold(c)
+			}
+			sb.WriteRune(c)
+		}
+
+	case syntax.OpCharClass:
+		// prefer printable ASCII characters, so that negated classes such as
+		// [^,] don't generate arbitrary Unicode
+		ranges := clipRanges(re.Rune, ' ', '~')
+		if len(ranges) == 0 {
+			ranges = re.Rune
+		}
+
+		// character classes are stored as pairs of inclusive bounds
+		var total int
+		for i := 0; i < len(ranges); i += 2 {
+			total += int(ranges[i+1]-ranges[i]) + 1
+		}
+		if total == 0 {
+			return
+		}
+
+		n := r.Intn(total)
+		for i := 0; i < len(ranges); i += 2 {
+			size := int(ranges[i+1]-ranges[i]) + 1
+			if n < size {
+				sb.WriteRune(ranges[i] + rune(n))
+				return
+			}
+			n -= size
+		}
+
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		sb.WriteRune(rune(' ' + r.Intn('~'-' '+1)))
+
+	case syntax.OpCapture:
+		generatePattern(sb, re.Sub[0], r)
+
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			generatePattern(sb, sub, r)
+		}
+
+	case syntax.OpAlternate:
+		generatePattern(sb, re.Sub[r.Intn(len(re.Sub))], r)
+
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		lo, hi := re.Min, re.Max
+		switch re.Op {
+		case syntax.OpStar:
+			lo, hi = 0, -1
+		case syntax.OpPlus:
+			lo, hi = 1, -1
+		case syntax.OpQuest:
+			lo, hi = 0, 1
+		}
+		if hi < 0 {
+			hi = lo + maxPatternRepeat
+		}
+
+		for n := lo + r.Intn(hi-lo+1); n > 0; n-- {
+			generatePattern(sb, re.Sub[0], r)
+		}
+	}
+
+	// anchors, word boundaries, and empty matches generate nothing
+}
+
+// clipRanges intersects pairs of inclusive rune bounds with [lo, hi].
+func clipRanges(ranges []rune, lo, hi rune) []rune {
+	var clipped []rune
+	for i := 0; i < len(ranges); i += 2 {
+		from, to := ranges[i], ranges[i+1]
+		if from < lo {
+			from = lo
+		}
+		if to > hi {
+			to = hi
+		}
+		if from <= to {
+			clipped = append(clipped, from, to)
+		}
+	}
+
+	return clipped
+}
@@ -0,0 +1,128 @@
+package json_test
+
+import (
+	"os"
+	"os/exec"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/jefflinse/melatonin/json"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerators(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		gen     func() any
+		pattern string
+	}{
+		{"UUIDv4", json.UUIDv4(), `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+		{"UUIDv7", json.UUIDv7(), `^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+		{"Name", json.Name(), `^[A-Z][a-z]+ [A-Z][a-zA-Z-]+$`},
+		{"Email", json.Email(), `^[a-z]+\.[a-z-]+\.[0-9a-f]{8}@example\.com$`},
+		{"Timestamp", json.Timestamp(time.Hour), `^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z$`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			seen := map[any]bool{}
+			for i := 0; i < 10; i++ {
+				v, err := json.ResolveDeferred(test.gen)
+				assert.NoError(t, err)
+				assert.Regexp(t, test.pattern, v)
+				seen[v] = true
+			}
+
+			if test.name != "Timestamp" && test.name != "Name" {
+				assert.Len(t, seen, 10)
+			}
+		})
+	}
+}
+
+func TestGeneratorsAreReproducible(t *testing.T) {
+	previous := json.Seed()
+	t.Cleanup(func() { json.SetSeed(previous) })
+
+	generate := func() []any {
+		json.SetSeed(42)
+		gens := []func() any{json.UUIDv4(), json.Email(), json.IntBetween(0, 1000), json.FloatBetween(0, 1)}
+		var values []any
+		for _, gen := range gens {
+			values = append(values, gen(), gen())
+		}
+		return values
+	}
+
+	assert.Equal(t, generate(), generate())
+}
+
+func TestSeedFromEnvironment(t *testing.T) {
+	if os.Getenv("MELATONIN_TEST_SEED_PROCESS") != "" {
+		if json.Seed() != 42 {
+			t.Fatalf("expected seed 42, got %d", json.Seed())
+		}
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSeedFromEnvironment$")
+	cmd.Env = append(os.Environ(), "MELATONIN_TEST_SEED_PROCESS=1", "MELATONIN_SEED=42")
+	out, err := cmd.CombinedOutput()
+	assert.NoError(t, err, string(out))
+}
+
+func TestSequence(t *testing.T) {
+	seq := json.Sequence(5)
+	assert.Equal(t, []any{5, 6, 7}, []any{seq(), seq(), seq()})
+}
+
+func TestRanges(t *testing.T) {
+	ints := json.IntBetween(-2, 2)
+	floats := json.FloatBetween(1.5, 2.5)
+	for i := 0; i < 100; i++ {
+		n := ints().(int)
+		assert.True(t, n >= -2 && n <= 2, "%d out of range", n)
+
+		f := floats().(float64)
+		assert.True(t, f >= 1.5 && f < 2.5, "%f out of range", f)
+	}
+
+	assert.Equal(t, 3, json.IntBetween(3, 3)())
+}
+
+func TestEnum(t *testing.T) {
+	s := "deferred"
+	enum := json.Enum("a", 1, &s)
+	for i := 0; i < 20; i++ {
+		v, err := enum()
+		assert.NoError(t, err)
+		assert.Contains(t, []any{"a", 1, "deferred"}, v)
+	}
+
+	_, err := json.Enum()()
+	assert.EqualError(t, err, "no values to pick from")
+}
+
+func TestPattern(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		wantErr string
+	}{
+		{pattern: `^[A-Z]{3}-\d{4}$`},
+		{pattern: `(foo|bar)+baz?`},
+		{pattern: `[^,]{5,8}`},
+		{pattern: `(?i)hello\s\w+`},
+		{pattern: `a.b*`},
+		{pattern: `[`, wantErr: "pattern \"[\": error parsing regexp: missing closing ]: `[`"},
+	} {
+		t.Run(test.pattern, func(t *testing.T) {
+			v, err := json.Pattern(test.pattern)()
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Regexp(t, regexp.MustCompile(`^(?:`+test.pattern+`)$`), v)
+		})
+	}
+}
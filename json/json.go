@@ -1,7 +1,12 @@
 package json
 
 import (
+	"encoding"
+	stdjson "encoding/json"
 	"fmt"
+	"io"
+	"reflect"
+	"sort"
 	"strings"
 )
 
@@ -30,84 +35,435 @@ func (dve DeferredValueError) WithPrefix(prefix string) DeferredValueError {
 	}
 }
 
+// A Resolver is a value that is resolved to a concrete value by
+// ResolveDeferred.
+type Resolver interface {
+	Resolve() (any, error)
+}
+
+// A Deferred is a typed value that is computed each time it is resolved.
+type Deferred[T any] func() (T, error)
+
+// Defer creates a Deferred from a function that computes a value.
+func Defer[T any](f func() T) Deferred[T] {
+	return func() (T, error) {
+		return f(), nil
+	}
+}
+
+// Get computes the value.
+func (d Deferred[T]) Get() (T, error) {
+	return d()
+}
+
+// Resolve computes the value, satisfying the Resolver interface.
+func (d Deferred[T]) Resolve() (any, error) {
+	return d()
+}
+
 // ResolveDeferred resolves a concrete value from a number of different input types,
-// such as pointers, functions, and maps and slices potentially containing more
-// deferred values.
+// such as pointers, functions, and Resolvers, and walks maps, slices, arrays, and
+// structs of any type, resolving any deferred values they contain.
+//
+// Values containing deferred values are copied rather than modified. A copy has
+// the same type as the original unless a resolved value can't be assigned to it,
+// such as when a map[string]*int is resolved, in which case maps and slices are
+// copied to map[string]any and []any, and structs are copied to map[string]any
+// using the field names and options from their JSON tags. Pointer fields of
+// structs are left for the encoder rather than dereferenced. Values that don't
+// contain any deferred values are returned as-is.
 func ResolveDeferred(v any) (any, error) {
+	resolved, _, err := resolve(v, map[uintptr]bool{})
+	if err != nil {
+		return nil, err
+	}
+
+	return resolved, nil
+}
+
+var (
+	anyType   = reflect.TypeOf((*any)(nil)).Elem()
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// resolve resolves a value, reporting whether the result differs from the
+// original. Pointers currently being resolved are tracked to avoid cycles.
+func resolve(v any, visiting map[uintptr]bool) (any, bool, error) {
 	switch value := v.(type) {
+	case nil:
+		return nil, false, nil
 	case func() any:
-		return value(), nil
+		return value(), true, nil
 	case func() (any, error):
-		return value()
-	case map[string]any:
-		mapVal, err := getDeferredMapValue(value)
+		resolved, err := value()
+		return resolved, true, err
+	case Resolver:
+		resolved, err := value.Resolve()
+		return resolved, true, err
+	case io.Reader, stdjson.Marshaler, encoding.TextMarshaler:
+		// values that know how to represent themselves are left alone
+		return v, false, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Func:
+		return resolveFunc(rv)
+	case reflect.Pointer:
+		return resolvePointer(rv, visiting)
+	case reflect.Map:
+		return resolveMap(rv, visiting)
+	case reflect.Slice, reflect.Array:
+		return resolveSlice(rv, visiting)
+	case reflect.Struct:
+		return resolveStruct(rv, visiting)
+	default:
+		return v, false, nil
+	}
+}
+
+// resolveFunc calls named function types with the same signatures as
+// func() any and func() (any, error). Other functions are left alone.
+func resolveFunc(rv reflect.Value) (any, bool, error) {
+	t := rv.Type()
+	if rv.IsNil() || t.NumIn() != 0 || t.NumOut() == 0 || t.NumOut() > 2 || t.Out(0) != anyType {
+		return rv.Interface(), false, nil
+	}
+	if t.NumOut() == 2 && t.Out(1) != errorType {
+		return rv.Interface(), false, nil
+	}
+
+	out := rv.Call(nil)
+	if len(out) == 2 && !out[1].IsNil() {
+		return nil, true, out[1].Interface().(error)
+	}
+
+	return out[0].Interface(), true, nil
+}
+
+// resolvePointer dereferences pointers to scalars and resolves the targets of
+// other pointers.
+func resolvePointer(rv reflect.Value, visiting map[uintptr]bool) (any, bool, error) {
+	if rv.IsNil() {
+		return rv.Interface(), false, nil
+	}
+
+	elem := rv.Elem()
+	if isScalarPointer(rv) {
+		return elem.Interface(), true, nil
+	}
+
+	if visiting[rv.Pointer()] {
+		return rv.Interface(), false, nil
+	}
+	visiting[rv.Pointer()] = true
+	defer delete(visiting, rv.Pointer())
+
+	resolved, changed, err := resolve(elem.Interface(), visiting)
+	if err != nil || !changed {
+		return rv.Interface(), false, err
+	}
+
+	if assignableTo([]any{resolved}, elem.Type()) {
+		ptr := reflect.New(elem.Type())
+		ptr.Elem().Set(valueOf(resolved, elem.Type()))
+		return ptr.Interface(), true, nil
+	}
+
+	return resolved, true, nil
+}
+
+// isScalarPointer reports whether a value is a non-nil pointer to a value
+// that can't contain deferred values.
+func isScalarPointer(rv reflect.Value) bool {
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return false
+	}
+
+	switch rv.Elem().Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct, reflect.Interface, reflect.Pointer, reflect.Func:
+		return false
+	default:
+		return true
+	}
+}
+
+func resolveMap(rv reflect.Value, visiting map[uintptr]bool) (any, bool, error) {
+	keys := rv.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+
+	values := make([]any, len(keys))
+	changed := false
+	for i, key := range keys {
+		value, c, err := resolve(rv.MapIndex(key).Interface(), visiting)
 		if err != nil {
-			return nil, err
+			return nil, false, withLabel(err, fmt.Sprint(key.Interface()))
 		}
-		return mapVal, nil
-	case []any:
-		mapVal, err := getDeferredSliceValue(value)
+
+		values[i] = value
+		changed = changed || c
+	}
+
+	if !changed {
+		return rv.Interface(), false, nil
+	}
+
+	if assignableTo(values, rv.Type().Elem()) {
+		result := reflect.MakeMapWithSize(rv.Type(), len(keys))
+		for i, key := range keys {
+			result.SetMapIndex(key, valueOf(values[i], rv.Type().Elem()))
+		}
+		return result.Interface(), true, nil
+	}
+
+	result := make(map[string]any, len(keys))
+	for i, key := range keys {
+		result[fmt.Sprint(key.Interface())] = values[i]
+	}
+
+	return result, true, nil
+}
+
+func resolveSlice(rv reflect.Value, visiting map[uintptr]bool) (any, bool, error) {
+	if rv.Type().Elem().Kind() == reflect.Uint8 || (rv.Kind() == reflect.Slice && rv.IsNil()) {
+		return rv.Interface(), false, nil
+	}
+
+	values := make([]any, rv.Len())
+	changed := false
+	for i := range values {
+		value, c, err := resolve(rv.Index(i).Interface(), visiting)
 		if err != nil {
-			return nil, err
-		}
-		return mapVal, nil
-	case *bool:
-		return *value, nil
-	case *float32:
-		return *value, nil
-	case *float64:
-		return *value, nil
-	case *int:
-		return *value, nil
-	case *int32:
-		return *value, nil
-	case *int64:
-		return *value, nil
-	case *string:
-		return *value, nil
+			return nil, false, withLabel(err, fmt.Sprintf("[%d]", i))
+		}
 
-	default:
-		return value, nil
+		values[i] = value
+		changed = changed || c
+	}
+
+	if !changed {
+		return rv.Interface(), false, nil
 	}
+
+	if assignableTo(values, rv.Type().Elem()) {
+		result := reflect.New(rv.Type()).Elem()
+		if rv.Kind() == reflect.Slice {
+			result = reflect.MakeSlice(rv.Type(), len(values), len(values))
+		}
+		for i := range values {
+			result.Index(i).Set(valueOf(values[i], rv.Type().Elem()))
+		}
+		return result.Interface(), true, nil
+	}
+
+	return values, true, nil
 }
 
-func getDeferredMapValue(m map[string]any) (map[string]any, error) {
-	result := make(map[string]any, len(m))
-	for k, v := range m {
-		value, err := ResolveDeferred(v)
+func resolveStruct(rv reflect.Value, visiting map[uintptr]bool) (any, bool, error) {
+	fields := jsonFields(rv.Type())
+	values := make([]any, len(fields))
+	present := make([]bool, len(fields))
+	changed := false
+	for i, field := range fields {
+		fv, err := rv.FieldByIndexErr(field.index)
+		if err != nil || !fv.CanInterface() {
+			// a field of a nil embedded struct pointer, or one promoted from an
+			// unexported embedded struct
+			continue
+		}
+
+		if isScalarPointer(fv) {
+			// pointer fields are left for the encoder, which respects their
+			// tag options, such as omitempty on a pointer to a zero value
+			values[i] = fv.Interface()
+			present[i] = true
+			continue
+		}
+
+		value, c, err := resolve(fv.Interface(), visiting)
 		if err != nil {
-			if dve, ok := err.(DeferredValueError); ok {
-				prefix := k
+			return nil, false, withLabel(err, field.name)
+		}
+
+		values[i] = value
+		present[i] = true
+		changed = changed || c
+	}
+
+	if !changed {
+		return rv.Interface(), false, nil
+	}
+
+	result := reflect.New(rv.Type()).Elem()
+	result.Set(rv)
+	assignable := true
+	for i, field := range fields {
+		if !present[i] {
+			continue
+		}
 
-				// hacky
-				if !strings.HasPrefix(dve.Label, "[") {
-					prefix += "."
-				}
+		fv, err := result.FieldByIndexErr(field.index)
+		if err != nil || !fv.CanSet() || !assignableTo(values[i:i+1], fv.Type()) {
+			assignable = false
+			break
+		}
 
-				return nil, dve.WithPrefix(prefix)
+		fv.Set(valueOf(values[i], fv.Type()))
+	}
+
+	if assignable {
+		return result.Interface(), true, nil
+	}
+
+	m := make(map[string]any, len(fields))
+	for i, field := range fields {
+		if !present[i] {
+			continue
+		}
+
+		// omitempty applies to the field as declared, not to what it resolved to
+		if field.omitEmpty && isEmpty(rv.FieldByIndex(field.index).Interface()) {
+			continue
+		}
+
+		value := values[i]
+		if field.quoted {
+			value = quote(value)
+		}
+		m[field.name] = value
+	}
+
+	return m, true, nil
+}
+
+type jsonField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+	quoted    bool
+}
+
+// jsonFields returns the exported fields of a struct type as encoding/json
+// would encode them, flattening untagged embedded structs.
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for _, embedded := range jsonFields(ft) {
+				embedded.index = append([]int{i}, embedded.index...)
+				fields = append(fields, embedded)
 			}
-			return nil, DeferredValueError{k, err}
+			continue
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
 		}
 
-		result[k] = value
+		fields = append(fields, jsonField{
+			name:      name,
+			index:     []int{i},
+			omitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+			quoted:    strings.Contains(","+opts+",", ",string,"),
+		})
 	}
 
-	return result, nil
+	return fields
 }
 
-func getDeferredSliceValue(s []any) ([]any, error) {
-	result := make([]any, len(s))
-	for i, v := range s {
-		value, err := ResolveDeferred(v)
-		if err != nil {
-			if dve, ok := err.(DeferredValueError); ok {
-				return nil, dve.WithPrefix(fmt.Sprintf("[%d]", i))
+// withLabel prefixes an error's label with the key or index of the element
+// that produced it.
+func withLabel(err error, label string) error {
+	if dve, ok := err.(DeferredValueError); ok {
+		if !strings.HasPrefix(dve.Label, "[") {
+			label += "."
+		}
+		return dve.WithPrefix(label)
+	}
+
+	return DeferredValueError{label, err}
+}
+
+// assignableTo reports whether all the values can be assigned to a type.
+func assignableTo(values []any, t reflect.Type) bool {
+	for _, v := range values {
+		if v == nil {
+			switch t.Kind() {
+			case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+				continue
 			}
-			return nil, DeferredValueError{fmt.Sprintf("[%d]", i), err}
+			return false
+		}
+
+		if !reflect.TypeOf(v).AssignableTo(t) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func valueOf(v any, t reflect.Type) reflect.Value {
+	if v == nil {
+		return reflect.Zero(t)
+	}
+
+	return reflect.ValueOf(v)
+}
+
+// quote encodes scalar values as JSON strings, as the string option of
+// encoding/json does. Other values are returned as-is.
+func quote(v any) any {
+	if isScalarPointer(reflect.ValueOf(v)) {
+		v = reflect.ValueOf(v).Elem().Interface()
+	}
+
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.String:
+		b, err := stdjson.Marshal(v)
+		if err != nil {
+			return v
 		}
+		return string(b)
+	default:
+		return v
+	}
+}
 
-		result[i] = value
+// isEmpty reports whether a value is empty as defined by the omitempty
+// option of encoding/json.
+func isEmpty(v any) bool {
+	if v == nil {
+		return true
 	}
 
-	return result, nil
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Struct:
+		return false
+	default:
+		return rv.IsZero()
+	}
 }
@@ -2,8 +2,11 @@ package json_test
 
 import (
 	"fmt"
+	"io"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/jefflinse/melatonin/json"
 	"github.com/stretchr/testify/assert"
@@ -106,6 +109,84 @@ func TestGetDeferredValue(t *testing.T) {
 			nil,
 			"foo",
 		},
+		{
+			"resolve a slice with map with error",
+			[]any{map[string]any{"foo": func() (any, error) { return nil, fmt.Errorf("error") }}},
+			nil,
+			"[0].foo: error",
+		},
+		{
+			"resolve a deferred json.Object",
+			json.Object{"foo": strPtr("bar"), "baz": json.Array{intPtr(1)}},
+			json.Object{"foo": "bar", "baz": json.Array{1}},
+			"",
+		},
+		{
+			"resolve a typed map",
+			map[string]*int{"foo": intPtr(1)},
+			map[string]any{"foo": 1},
+			"",
+		},
+		{
+			"resolve a typed slice",
+			[]*string{strPtr("foo")},
+			[]any{"foo"},
+			"",
+		},
+		{
+			"resolve a typed map without deferred values",
+			map[string]int{"foo": 1},
+			map[string]int{"foo": 1},
+			"",
+		},
+		{
+			"resolve a struct with deferred values",
+			person{Name: strPtr("Bob"), Age: 42, Tags: []any{func() any { return "a" }}},
+			person{Name: strPtr("Bob"), Age: 42, Tags: []any{"a"}},
+			"",
+		},
+		{
+			"resolve a struct with assignable deferred values",
+			struct{ Value any }{Value: func() any { return "foo" }},
+			struct{ Value any }{Value: "foo"},
+			"",
+		},
+		{
+			"resolve a pointer to a struct with deferred values",
+			&person{Tags: []any{func() any { return "a" }}},
+			&person{Tags: []any{"a"}},
+			"",
+		},
+		{
+			"resolve a struct with unassignable deferred values",
+			invoice{Count: intPtr(0), Total: func() any { return 12 }},
+			map[string]any{"count": intPtr(0), "total": "12"},
+			"",
+		},
+		{
+			"resolve a struct with error",
+			person{Tags: []any{func() (any, error) { return nil, fmt.Errorf("error") }}},
+			nil,
+			"tags[0]: error",
+		},
+		{
+			"resolve a typed deferred value",
+			json.Defer(func() int { return 42 }),
+			42,
+			"",
+		},
+		{
+			"resolve a typed deferred value with error",
+			map[string]any{"foo": json.Deferred[string](func() (string, error) { return "", fmt.Errorf("error") })},
+			nil,
+			"foo: error",
+		},
+		{
+			"resolve a named deferred function",
+			generator(func() any { return "foo" }),
+			"foo",
+			"",
+		},
 		{
 			"resolve an unknown type by passing the value through directy",
 			struct{}{},
@@ -127,6 +208,42 @@ func TestGetDeferredValue(t *testing.T) {
 	}
 }
 
+type person struct {
+	Name   *string `json:"name"`
+	Age    int
+	Tags   []any  `json:"tags,omitempty"`
+	Secret string `json:"-"`
+}
+
+type invoice struct {
+	Count *int      `json:"count,omitempty"`
+	Total generator `json:"total,string"`
+	Note  string    `json:"note,omitempty"`
+}
+
+type generator func() any
+
+func TestResolveDeferredLeavesValuesAlone(t *testing.T) {
+	type node struct {
+		Next *node
+	}
+	cycle := &node{}
+	cycle.Next = cycle
+
+	now := time.Now()
+	reader := strings.NewReader("foo")
+	stream := func() io.Reader { return reader }
+	for _, v := range []any{cycle, &now, reader, &person{Age: 1}} {
+		got, err := json.ResolveDeferred(v)
+		assert.NoError(t, err)
+		assert.Same(t, v, got)
+	}
+
+	got, err := json.ResolveDeferred(stream)
+	assert.NoError(t, err)
+	assert.Equal(t, reflect.ValueOf(stream).Pointer(), reflect.ValueOf(got).Pointer())
+}
+
 func boolPtr(b bool) *bool {
 	return &b
 }
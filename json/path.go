@@ -0,0 +1,879 @@
+package json
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A Path is a compiled JSONPath expression, such as
+// $.store.book[?(@.price < 10)].title.
+//
+// Paths support child and recursive descent (..) segments, and name,
+// wildcard, index, slice, union and filter selectors. Filters may compare
+// values selected relative to the current node (@) or the root ($) against
+// literals or each other using ==, !=, <, <=, > and >=, match strings
+// against regular expressions using =~, test for the existence of values,
+// and combine conditions using &&, || and !.
+type Path struct {
+	expr     string
+	segments []pathSegment
+}
+
+// A PathMatch is a value selected by a Path.
+type PathMatch struct {
+	// Fields is the concrete location of the value, as a list of object keys
+	// and bracketed array indices, such as ["store", "book", "[0]"]. Keys
+	// that aren't valid JSONPath names are bracketed and quoted.
+	Fields []string
+
+	// Value is the selected value.
+	Value any
+}
+
+// String returns the concrete location of the match as a JSONPath, such as
+// $.store.book[0].
+func (m PathMatch) String() string {
+	return strings.ReplaceAll(strings.Join(append([]string{"$"}, m.Fields...), "."), ".[", "[")
+}
+
+// CompilePath parses a JSONPath expression.
+func CompilePath(expr string) (*Path, error) {
+	p := &pathParser{expr: expr}
+	if !p.consume("$") {
+		return nil, p.errorf("expected $")
+	}
+
+	segments, err := p.parseSegments()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos < len(p.expr) {
+		return nil, p.errorf("unexpected %q", p.expr[p.pos:])
+	}
+
+	return &Path{expr: expr, segments: segments}, nil
+}
+
+// SelectPath compiles a JSONPath expression and selects the values it matches
+// within v.
+func SelectPath(expr string, v any) ([]PathMatch, error) {
+	p, err := CompilePath(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.Select(v), nil
+}
+
+// Select returns the values matched by the path within v, which is expected
+// to contain values decoded from JSON, such as map[string]any and []any.
+// Object members are visited in sorted key order.
+func (p *Path) Select(v any) []PathMatch {
+	return applySegments(p.segments, []PathMatch{{Value: v}}, v)
+}
+
+// String returns the expression the path was compiled from.
+func (p *Path) String() string {
+	return p.expr
+}
+
+// A pathSegment applies its selectors to each input node, or if descendant
+// is true, to each input node and all of its descendants.
+type pathSegment struct {
+	descendant bool
+	selectors  []pathSelector
+}
+
+type pathSelector interface {
+	apply(node PathMatch, root any, emit func(PathMatch))
+}
+
+func applySegments(segments []pathSegment, nodes []PathMatch, root any) []PathMatch {
+	for _, segment := range segments {
+		var next []PathMatch
+		emit := func(m PathMatch) {
+			next = append(next, m)
+		}
+
+		for _, node := range nodes {
+			visit := func(n PathMatch) {
+				for _, selector := range segment.selectors {
+					selector.apply(n, root, emit)
+				}
+			}
+
+			if segment.descendant {
+				walkPath(node, visit)
+			} else {
+				visit(node)
+			}
+		}
+
+		nodes = next
+	}
+
+	return nodes
+}
+
+// walkPath visits a node and all of its descendants, in document order.
+func walkPath(node PathMatch, visit func(PathMatch)) {
+	visit(node)
+	eachChild(node, func(child PathMatch) {
+		walkPath(child, visit)
+	})
+}
+
+// eachChild visits the members of an object, in sorted key order, or the
+// elements of an array.
+func eachChild(node PathMatch, visit func(PathMatch)) {
+	if m, ok := asObject(node.Value); ok {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			visit(childMatch(node, keyField(k), m[k]))
+		}
+	} else if a, ok := asArray(node.Value); ok {
+		for i, v := range a {
+			visit(childMatch(node, indexField(i), v))
+		}
+	}
+}
+
+func childMatch(node PathMatch, field string, v any) PathMatch {
+	fields := make([]string, len(node.Fields), len(node.Fields)+1)
+	copy(fields, node.Fields)
+	return PathMatch{Fields: append(fields, field), Value: v}
+}
+
+func keyField(k string) string {
+	if k != "" && nameLength(k) == len(k) {
+		return k
+	}
+
+	return "['" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(k) + "']"
+}
+
+func indexField(i int) string {
+	return fmt.Sprintf("[%d]", i)
+}
+
+func asObject(v any) (map[string]any, bool) {
+	switch m := v.(type) {
+	case map[string]any:
+		return m, true
+	case Object:
+		return m, true
+	}
+	return nil, false
+}
+
+func asArray(v any) ([]any, bool) {
+	switch a := v.(type) {
+	case []any:
+		return a, true
+	case Array:
+		return a, true
+	}
+	return nil, false
+}
+
+type nameSelector string
+
+func (s nameSelector) apply(node PathMatch, _ any, emit func(PathMatch)) {
+	if m, ok := asObject(node.Value); ok {
+		if v, ok := m[string(s)]; ok {
+			emit(childMatch(node, keyField(string(s)), v))
+		}
+	}
+}
+
+type wildcardSelector struct{}
+
+func (wildcardSelector) apply(node PathMatch, _ any, emit func(PathMatch)) {
+	eachChild(node, emit)
+}
+
+type indexSelector int
+
+func (s indexSelector) apply(node PathMatch, _ any, emit func(PathMatch)) {
+	a, ok := asArray(node.Value)
+	if !ok {
+		return
+	}
+
+	i := int(s)
+	if i < 0 {
+		i += len(a)
+	}
+
+	if i >= 0 && i < len(a) {
+		emit(childMatch(node, indexField(i), a[i]))
+	}
+}
+
+type sliceSelector struct {
+	start, end *int
+	step       int
+}
+
+func (s sliceSelector) apply(node PathMatch, _ any, emit func(PathMatch)) {
+	a, ok := asArray(node.Value)
+	if !ok || s.step == 0 {
+		return
+	}
+
+	n := len(a)
+	bound := func(i *int, def, lo, hi int) int {
+		if i == nil {
+			return def
+		}
+
+		v := *i
+		if v < 0 {
+			v += n
+		}
+
+		if v < lo {
+			return lo
+		} else if v > hi {
+			return hi
+		}
+		return v
+	}
+
+	if s.step > 0 {
+		lower, upper := bound(s.start, 0, 0, n), bound(s.end, n, 0, n)
+		for i := lower; i < upper; i += s.step {
+			emit(childMatch(node, indexField(i), a[i]))
+		}
+	} else {
+		lower, upper := bound(s.end, -1, -1, n-1), bound(s.start, n-1, -1, n-1)
+		for i := upper; i > lower; i += s.step {
+			emit(childMatch(node, indexField(i), a[i]))
+		}
+	}
+}
+
+type filterSelector struct {
+	expr filterExpr
+}
+
+func (s filterSelector) apply(node PathMatch, root any, emit func(PathMatch)) {
+	eachChild(node, func(child PathMatch) {
+		if s.expr.eval(child.Value, root) {
+			emit(child)
+		}
+	})
+}
+
+// A filterExpr is a logical expression evaluated against a candidate node.
+type filterExpr interface {
+	eval(current, root any) bool
+}
+
+type orExpr []filterExpr
+
+func (e orExpr) eval(current, root any) bool {
+	for _, expr := range e {
+		if expr.eval(current, root) {
+			return true
+		}
+	}
+	return false
+}
+
+type andExpr []filterExpr
+
+func (e andExpr) eval(current, root any) bool {
+	for _, expr := range e {
+		if !expr.eval(current, root) {
+			return false
+		}
+	}
+	return true
+}
+
+type notExpr struct {
+	expr filterExpr
+}
+
+func (e notExpr) eval(current, root any) bool {
+	return !e.expr.eval(current, root)
+}
+
+type existsExpr struct {
+	query *filterQuery
+}
+
+func (e existsExpr) eval(current, root any) bool {
+	return len(e.query.nodes(current, root)) > 0
+}
+
+type compareExpr struct {
+	op          string
+	left, right filterOperand
+	regex       *regexp.Regexp
+}
+
+func (e compareExpr) eval(current, root any) bool {
+	left, leftOK := e.left.value(current, root)
+	if e.op == "=~" {
+		s, ok := left.(string)
+		return leftOK && ok && e.regex.MatchString(s)
+	}
+
+	right, rightOK := e.right.value(current, root)
+	switch e.op {
+	case "==":
+		return leftOK == rightOK && (!leftOK || valuesEqual(left, right))
+	case "!=":
+		return leftOK != rightOK || (leftOK && !valuesEqual(left, right))
+	}
+
+	if !leftOK || !rightOK {
+		return false
+	}
+
+	var cmp int
+	if l, ok := toNumber(left); ok {
+		r, ok := toNumber(right)
+		if !ok {
+			return false
+		}
+		cmp = compareOrdered(l, r)
+	} else if l, ok := left.(string); ok {
+		r, ok := right.(string)
+		if !ok {
+			return false
+		}
+		cmp = strings.Compare(l, r)
+	} else {
+		return false
+	}
+
+	switch e.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+func compareOrdered(l, r float64) int {
+	if l < r {
+		return -1
+	} else if l > r {
+		return 1
+	}
+	return 0
+}
+
+// A filterOperand is a value in a comparison. Its value is undefined if it
+// is a query that doesn't select exactly one node.
+type filterOperand interface {
+	value(current, root any) (any, bool)
+}
+
+type literalOperand struct {
+	v any
+}
+
+func (o literalOperand) value(any, any) (any, bool) {
+	return o.v, true
+}
+
+// A filterQuery selects nodes relative to the current node (@) or the root
+// node ($).
+type filterQuery struct {
+	relative bool
+	segments []pathSegment
+}
+
+func (q *filterQuery) nodes(current, root any) []PathMatch {
+	start := root
+	if q.relative {
+		start = current
+	}
+
+	return applySegments(q.segments, []PathMatch{{Value: start}}, root)
+}
+
+func (q *filterQuery) value(current, root any) (any, bool) {
+	nodes := q.nodes(current, root)
+	if len(nodes) != 1 {
+		return nil, false
+	}
+	return nodes[0].Value, true
+}
+
+func valuesEqual(a, b any) bool {
+	if x, ok := toNumber(a); ok {
+		y, ok := toNumber(b)
+		return ok && x == y
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func toNumber(v any) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+type pathParser struct {
+	expr string
+	pos  int
+}
+
+func (p *pathParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("jsonpath %q: %s at position %d", p.expr, fmt.Sprintf(format, args...), p.pos)
+}
+
+func (p *pathParser) peek() byte {
+	if p.pos < len(p.expr) {
+		return p.expr[p.pos]
+	}
+	return 0
+}
+
+func (p *pathParser) consume(s string) bool {
+	if strings.HasPrefix(p.expr[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *pathParser) skipSpace() {
+	for p.pos < len(p.expr) && strings.IndexByte(" \t\n\r", p.expr[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *pathParser) parseSegments() ([]pathSegment, error) {
+	var segments []pathSegment
+	for {
+		var segment pathSegment
+		switch {
+		case p.consume(".."):
+			segment.descendant = true
+			if p.peek() == '[' {
+				selectors, err := p.parseBracket()
+				if err != nil {
+					return nil, err
+				}
+				segment.selectors = selectors
+				break
+			}
+			fallthrough
+
+		case !segment.descendant && p.consume("."):
+			if p.consume("*") {
+				segment.selectors = []pathSelector{wildcardSelector{}}
+				break
+			}
+
+			n := nameLength(p.expr[p.pos:])
+			if n == 0 {
+				return nil, p.errorf("expected name")
+			}
+			segment.selectors = []pathSelector{nameSelector(p.expr[p.pos : p.pos+n])}
+			p.pos += n
+
+		case p.peek() == '[':
+			selectors, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			segment.selectors = selectors
+
+		default:
+			return segments, nil
+		}
+
+		segments = append(segments, segment)
+	}
+}
+
+// nameLength returns the length of the member name shorthand at the start of
+// s, which may contain letters, digits, underscores, hyphens and non-ASCII
+// characters, but may not start with a digit or hyphen.
+func nameLength(s string) int {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= utf8.RuneSelf ||
+			i > 0 && (c == '-' || c >= '0' && c <= '9')) {
+			return i
+		}
+	}
+
+	return len(s)
+}
+
+func (p *pathParser) parseBracket() ([]pathSelector, error) {
+	p.pos++ // [
+	var selectors []pathSelector
+	for {
+		p.skipSpace()
+		selector, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, selector)
+
+		p.skipSpace()
+		if p.consume("]") {
+			return selectors, nil
+		} else if !p.consume(",") {
+			return nil, p.errorf("expected , or ]")
+		}
+	}
+}
+
+func (p *pathParser) parseSelector() (pathSelector, error) {
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return nameSelector(s), nil
+
+	case c == '*':
+		p.pos++
+		return wildcardSelector{}, nil
+
+	case c == '?':
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return filterSelector{expr}, nil
+	}
+
+	start, err := p.parseInt()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if !p.consume(":") {
+		if start == nil {
+			return nil, p.errorf("invalid selector")
+		}
+		return indexSelector(*start), nil
+	}
+
+	s := sliceSelector{start: start, step: 1}
+	p.skipSpace()
+	if s.end, err = p.parseInt(); err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	if p.consume(":") {
+		p.skipSpace()
+		step, err := p.parseInt()
+		if err != nil {
+			return nil, err
+		} else if step != nil {
+			s.step = *step
+		}
+	}
+
+	return s, nil
+}
+
+// parseInt parses an optional integer.
+func (p *pathParser) parseInt() (*int, error) {
+	start := p.pos
+	p.consume("-")
+	for p.peek() >= '0' && p.peek() <= '9' {
+		p.pos++
+	}
+
+	if p.pos == start {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(p.expr[start:p.pos])
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf("invalid integer")
+	}
+
+	return &n, nil
+}
+
+func (p *pathParser) parseString() (string, error) {
+	quote := p.expr[p.pos]
+	start := p.pos
+	p.pos++
+
+	sb := &strings.Builder{}
+	for p.pos < len(p.expr) {
+		c := p.expr[p.pos]
+		p.pos++
+		switch {
+		case c == quote:
+			return sb.String(), nil
+
+		case c == '\\' && p.pos < len(p.expr):
+			e := p.expr[p.pos]
+			p.pos++
+			switch e {
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case 'u':
+				if p.pos+4 > len(p.expr) {
+					return "", p.errorf("invalid escape sequence")
+				}
+				r, err := strconv.ParseUint(p.expr[p.pos:p.pos+4], 16, 32)
+				if err != nil {
+					return "", p.errorf("invalid escape sequence")
+				}
+				sb.WriteRune(rune(r))
+				p.pos += 4
+			default:
+				sb.WriteByte(e)
+			}
+
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	p.pos = start
+	return "", p.errorf("unterminated string")
+}
+
+func (p *pathParser) parseOr() (filterExpr, error) {
+	exprs := orExpr{}
+	for {
+		expr, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+
+		p.skipSpace()
+		if !p.consume("||") {
+			break
+		}
+	}
+
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return exprs, nil
+}
+
+func (p *pathParser) parseAnd() (filterExpr, error) {
+	exprs := andExpr{}
+	for {
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+
+		p.skipSpace()
+		if !p.consume("&&") {
+			break
+		}
+	}
+
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return exprs, nil
+}
+
+func (p *pathParser) parseUnary() (filterExpr, error) {
+	p.skipSpace()
+	if p.peek() == '!' && !strings.HasPrefix(p.expr[p.pos:], "!=") {
+		p.pos++
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{expr}, nil
+	}
+
+	if p.consume("(") {
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipSpace()
+		if !p.consume(")") {
+			return nil, p.errorf("expected )")
+		}
+		return expr, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *pathParser) parseComparison() (filterExpr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	var op string
+	for _, candidate := range []string{"==", "!=", "<=", ">=", "=~", "<", ">"} {
+		if p.consume(candidate) {
+			op = candidate
+			break
+		}
+	}
+
+	if op == "" {
+		query, ok := left.(*filterQuery)
+		if !ok {
+			return nil, p.errorf("expected comparison operator")
+		}
+		return existsExpr{query}, nil
+	}
+
+	p.skipSpace()
+	if op == "=~" {
+		regex, err := p.parseRegex()
+		if err != nil {
+			return nil, err
+		}
+		return compareExpr{op: op, left: left, regex: regex}, nil
+	}
+
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	return compareExpr{op: op, left: left, right: right}, nil
+}
+
+func (p *pathParser) parseOperand() (filterOperand, error) {
+	switch c := p.peek(); {
+	case c == '@' || c == '$':
+		p.pos++
+		segments, err := p.parseSegments()
+		if err != nil {
+			return nil, err
+		}
+		return &filterQuery{relative: c == '@', segments: segments}, nil
+
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return literalOperand{s}, nil
+
+	case c == '-' || c >= '0' && c <= '9':
+		start := p.pos
+		for p.pos < len(p.expr) && strings.IndexByte("+-.0123456789eE", p.expr[p.pos]) >= 0 {
+			p.pos++
+		}
+
+		f, err := strconv.ParseFloat(p.expr[start:p.pos], 64)
+		if err != nil {
+			p.pos = start
+			return nil, p.errorf("invalid number")
+		}
+		return literalOperand{f}, nil
+	}
+
+	for word, v := range map[string]any{"true": true, "false": false, "null": nil} {
+		if p.consume(word) {
+			return literalOperand{v}, nil
+		}
+	}
+
+	return nil, p.errorf("expected value")
+}
+
+// parseRegex parses a regular expression written as a string, or between
+// slashes with an optional i flag, such as /^foo/i.
+func (p *pathParser) parseRegex() (*regexp.Regexp, error) {
+	start := p.pos
+	var pattern string
+	switch p.peek() {
+	case '\'', '"':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		pattern = s
+
+	case '/':
+		p.pos++
+		sb := &strings.Builder{}
+		for {
+			if p.pos >= len(p.expr) {
+				p.pos = start
+				return nil, p.errorf("unterminated regular expression")
+			}
+
+			c := p.expr[p.pos]
+			p.pos++
+			if c == '/' {
+				break
+			} else if c == '\\' && p.peek() == '/' {
+				c = '/'
+				p.pos++
+			}
+			sb.WriteByte(c)
+		}
+
+		pattern = sb.String()
+		if p.consume("i") {
+			pattern = "(?i)" + pattern
+		}
+
+	default:
+		return nil, p.errorf("expected regular expression")
+	}
+
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf("invalid regular expression: %s", err)
+	}
+
+	return regex, nil
+}
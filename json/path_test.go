@@ -0,0 +1,214 @@
+package json_test
+
+import (
+	"testing"
+
+	"github.com/jefflinse/melatonin/json"
+	"github.com/stretchr/testify/assert"
+)
+
+var store = map[string]any{
+	"store": map[string]any{
+		"book": []any{
+			map[string]any{"category": "reference", "author": "Nigel Rees", "title": "Sayings of the Century", "price": 8.95},
+			map[string]any{"category": "fiction", "author": "Evelyn Waugh", "title": "Sword of Honour", "price": 12.99},
+			map[string]any{"category": "fiction", "author": "Herman Melville", "title": "Moby Dick", "isbn": "0-553-21311-3", "price": 8.99},
+			map[string]any{"category": "fiction", "author": "J. R. R. Tolkien", "title": "The Lord of the Rings", "isbn": "0-395-19395-8", "price": 22.99},
+		},
+		"bicycle": map[string]any{"color": "red", "price": 399.0},
+	},
+	"max price": 10.0,
+	"tags":      []any{"a", "b", "c", "d", "e"},
+}
+
+func TestSelectPath(t *testing.T) {
+	for _, test := range []struct {
+		path       string
+		wantPaths  []string
+		wantValues []any
+		wantErr    string
+	}{
+		{
+			path:       "$",
+			wantPaths:  []string{"$"},
+			wantValues: []any{store},
+		},
+		{
+			path:       "$.store.bicycle.color",
+			wantPaths:  []string{"$.store.bicycle.color"},
+			wantValues: []any{"red"},
+		},
+		{
+			path:       `$['store']["bicycle"]['color']`,
+			wantPaths:  []string{"$.store.bicycle.color"},
+			wantValues: []any{"red"},
+		},
+		{
+			path:       "$['max price']",
+			wantPaths:  []string{"$['max price']"},
+			wantValues: []any{10.0},
+		},
+		{
+			path:       "$.store.book[*].author",
+			wantPaths:  []string{"$.store.book[0].author", "$.store.book[1].author", "$.store.book[2].author", "$.store.book[3].author"},
+			wantValues: []any{"Nigel Rees", "Evelyn Waugh", "Herman Melville", "J. R. R. Tolkien"},
+		},
+		{
+			path:       "$.store.*.color",
+			wantPaths:  []string{"$.store.bicycle.color"},
+			wantValues: []any{"red"},
+		},
+		{
+			path:       "$..price",
+			wantPaths:  []string{"$.store.bicycle.price", "$.store.book[0].price", "$.store.book[1].price", "$.store.book[2].price", "$.store.book[3].price"},
+			wantValues: []any{399.0, 8.95, 12.99, 8.99, 22.99},
+		},
+		{
+			path:       "$..book[2].title",
+			wantPaths:  []string{"$.store.book[2].title"},
+			wantValues: []any{"Moby Dick"},
+		},
+		{
+			path:       "$..book[-1].title",
+			wantPaths:  []string{"$.store.book[3].title"},
+			wantValues: []any{"The Lord of the Rings"},
+		},
+		{
+			path:       "$.store.book[0,2].price",
+			wantPaths:  []string{"$.store.book[0].price", "$.store.book[2].price"},
+			wantValues: []any{8.95, 8.99},
+		},
+		{
+			path:       "$.tags[1:3]",
+			wantPaths:  []string{"$.tags[1]", "$.tags[2]"},
+			wantValues: []any{"b", "c"},
+		},
+		{
+			path:       "$.tags[:2]",
+			wantValues: []any{"a", "b"},
+		},
+		{
+			path:       "$.tags[-2:]",
+			wantValues: []any{"d", "e"},
+		},
+		{
+			path:       "$.tags[::2]",
+			wantValues: []any{"a", "c", "e"},
+		},
+		{
+			path:       "$.tags[::-2]",
+			wantValues: []any{"e", "c", "a"},
+		},
+		{
+			path:       "$.tags[3:1:-1]",
+			wantValues: []any{"d", "c"},
+		},
+		{
+			path:       "$.tags[0:5:0]",
+			wantValues: nil,
+		},
+		{
+			path:       "$.store.book[?(@.price < 10)].title",
+			wantPaths:  []string{"$.store.book[0].title", "$.store.book[2].title"},
+			wantValues: []any{"Sayings of the Century", "Moby Dick"},
+		},
+		{
+			path:       "$.store.book[?@.price > $['max price']].title",
+			wantValues: []any{"Sword of Honour", "The Lord of the Rings"},
+		},
+		{
+			path:       "$..book[?(@.isbn)].title",
+			wantValues: []any{"Moby Dick", "The Lord of the Rings"},
+		},
+		{
+			path:       "$..book[?(!@.isbn)].title",
+			wantValues: []any{"Sayings of the Century", "Sword of Honour"},
+		},
+		{
+			path:       `$..book[?(@.category == "fiction" && @.price <= 12.99)].title`,
+			wantValues: []any{"Sword of Honour", "Moby Dick"},
+		},
+		{
+			path:       `$..book[?(@.category != 'fiction' || (@.price >= 20 && @.isbn))].title`,
+			wantValues: []any{"Sayings of the Century", "The Lord of the Rings"},
+		},
+		{
+			path:       `$..book[?(@.author =~ /^j\. r/i)].title`,
+			wantValues: []any{"The Lord of the Rings"},
+		},
+		{
+			path:       `$..book[?(@.author =~ 'Mel')].title`,
+			wantValues: []any{"Moby Dick"},
+		},
+		{
+			path:       `$..book[?(@.missing == null)].title`,
+			wantValues: nil,
+		},
+		{
+			path:       `$..[?(@.color == 'red')].price`,
+			wantPaths:  []string{"$.store.bicycle.price"},
+			wantValues: []any{399.0},
+		},
+		{
+			path:       "$.store.bicycle[0]",
+			wantValues: nil,
+		},
+		{
+			path:    "store.book",
+			wantErr: `jsonpath "store.book": expected $ at position 0`,
+		},
+		{
+			path:    "$.store.",
+			wantErr: `jsonpath "$.store.": expected name at position 8`,
+		},
+		{
+			path:    "$.tags[0",
+			wantErr: `jsonpath "$.tags[0": expected , or ] at position 8`,
+		},
+		{
+			path:    "$['store",
+			wantErr: `jsonpath "$['store": unterminated string at position 2`,
+		},
+		{
+			path:    "$.book[?(@.price <)]",
+			wantErr: `jsonpath "$.book[?(@.price <)]": expected value at position 18`,
+		},
+		{
+			path:    "$.book[?(@.title =~ /[/)]",
+			wantErr: "jsonpath \"$.book[?(@.title =~ /[/)]\": invalid regular expression: error parsing regexp: missing closing ]: `[` at position 20",
+		},
+		{
+			path:    "$.book[?(1)]",
+			wantErr: `jsonpath "$.book[?(1)]": expected comparison operator at position 10`,
+		},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			matches, err := json.SelectPath(test.path, store)
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+
+			var paths []string
+			var values []any
+			for _, m := range matches {
+				paths = append(paths, m.String())
+				values = append(values, m.Value)
+			}
+
+			if test.wantPaths != nil {
+				assert.Equal(t, test.wantPaths, paths)
+			}
+			assert.Equal(t, test.wantValues, values)
+		})
+	}
+}
+
+func TestPathMatchFields(t *testing.T) {
+	matches, err := json.SelectPath("$..['a.b']", map[string]any{"x": []any{map[string]any{"a.b": 1}}})
+	assert.NoError(t, err)
+	assert.Equal(t, []json.PathMatch{{Fields: []string{"x", "[0]", "['a.b']"}, Value: 1}}, matches)
+	assert.Equal(t, "$.x[0]['a.b']", matches[0].String())
+}
@@ -0,0 +1,1080 @@
+package json
+
+import (
+	stdjson "encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A Schema is a compiled JSON Schema (draft 2020-12).
+//
+// References to other schema files, such as "common.json#/$defs/id", are
+// resolved against the location of the referencing document and loaded from
+// the local filesystem. Only files within the schema directory may be loaded;
+// see SetSchemaDir. Files with a .yaml or .yml extension are decoded as YAML. Remote
+// references are only resolved if a loaded document declares a matching $id.
+//
+// The date-time, date, time, email, uuid, uri, uri-reference, ipv4, ipv6,
+// hostname and regex formats are asserted, and other formats are ignored.
+type Schema struct {
+	root     any
+	base     *url.URL
+	registry *schemaRegistry
+}
+
+// A SchemaViolation describes a value that doesn't conform to a schema.
+type SchemaViolation struct {
+	// Fields is the location of the value within the validated instance, in
+	// the same form as PathMatch.Fields.
+	Fields []string
+
+	// Keyword is the schema keyword that failed, such as "required".
+	Keyword string
+
+	// Message describes the violation.
+	Message string
+}
+
+func (v SchemaViolation) Error() string {
+	return fmt.Sprintf("%s: %s", PathMatch{Fields: v.Fields}, v.Message)
+}
+
+var (
+	schemaDir   string
+	schemaDirMu sync.Mutex
+)
+
+// SchemaDir returns the directory that schema files are loaded from.
+func SchemaDir() string {
+	schemaDirMu.Lock()
+	defer schemaDirMu.Unlock()
+	return schemaDir
+}
+
+// SetSchemaDir sets the directory that schema files are loaded from. Relative
+// schema paths, and relative references from schema documents that aren't
+// files themselves, are resolved against it, and only files within it may be
+// loaded. If empty, which is the default, the current directory is used. The
+// mt package sets it to its working directory.
+func SetSchemaDir(dir string) {
+	schemaDirMu.Lock()
+	defer schemaDirMu.Unlock()
+	schemaDir = dir
+}
+
+// CompileSchema compiles a JSON Schema document, which may be a map, a bool,
+// or JSON text as a []byte. Relative references to other schema files are
+// resolved against dir, or the schema directory if dir is empty, and must
+// refer to files within it.
+func CompileSchema(schema any, dir string) (*Schema, error) {
+	switch v := schema.(type) {
+	case []byte:
+		if err := stdjson.Unmarshal(v, &schema); err != nil {
+			return nil, fmt.Errorf("schema: %w", err)
+		}
+	case stdjson.RawMessage:
+		if err := stdjson.Unmarshal(v, &schema); err != nil {
+			return nil, fmt.Errorf("schema: %w", err)
+		}
+	}
+
+	if dir == "" {
+		dir = SchemaDir()
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("schema: %w", err)
+	}
+
+	base := fileURI(dir)
+	base.Path += "/"
+	r := newSchemaRegistry(dir)
+	r.resources[base.String()] = schemaResource{node: schema, base: base}
+	if err := r.compile(schema, base); err != nil {
+		return nil, err
+	}
+
+	return &Schema{root: schema, base: base, registry: r}, nil
+}
+
+// LoadSchema loads and compiles a JSON Schema file. A relative path is
+// resolved against the schema directory, and the file, and any schema files it
+// refers to, must be within it.
+func LoadSchema(path string) (*Schema, error) {
+	root, err := filepath.Abs(SchemaDir())
+	if err != nil {
+		return nil, fmt.Errorf("schema %q: %w", path, err)
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+
+	r := newSchemaRegistry(root)
+	base := fileURI(filepath.Clean(path))
+	doc, err := r.load(base)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.compile(doc.node, doc.base); err != nil {
+		return nil, err
+	}
+
+	return &Schema{root: doc.node, base: doc.base, registry: r}, nil
+}
+
+// Subschema returns the schema located at a JSON pointer within the schema's
+// document, such as "/components/schemas/user", sharing the document's
+// references and anchors. It allows validating against schemas embedded in
+// documents that aren't schemas themselves, such as OpenAPI specs.
+func (s *Schema) Subschema(pointer string) (*Schema, error) {
+	ref := (&url.URL{Fragment: pointer}).String()
+	node, base, err := s.registry.resolve(s.base, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.registry.compile(node, base); err != nil {
+		return nil, err
+	}
+
+	return &Schema{root: node, base: base, registry: s.registry}, nil
+}
+
+// Validate validates a value against the schema, returning a violation for
+// each failed constraint. Values other than those decoded from JSON are
+// converted using their JSON representation.
+func (s *Schema) Validate(v any) []SchemaViolation {
+	v, err := normalizeInstance(v)
+	if err != nil {
+		return []SchemaViolation{{Message: err.Error()}}
+	}
+
+	sv := &schemaValidator{registry: s.registry}
+	violations, _ := sv.validate(s.root, s.base, v, nil)
+	return violations
+}
+
+func normalizeInstance(v any) (any, error) {
+	switch v.(type) {
+	case nil, bool, string, float64, map[string]any, []any, Object, Array:
+		return v, nil
+	}
+
+	if _, ok := toNumber(v); ok {
+		return v, nil
+	}
+
+	b, err := stdjson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var normalized any
+	err = stdjson.Unmarshal(b, &normalized)
+	return normalized, err
+}
+
+func fileURI(path string) *url.URL {
+	return &url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+}
+
+// withoutFragment returns a copy of a URI without its fragment.
+func withoutFragment(u *url.URL) *url.URL {
+	c := *u
+	c.Fragment, c.RawFragment = "", ""
+	return &c
+}
+
+// A schemaResource is a schema along with its base URI.
+type schemaResource struct {
+	node any
+	base *url.URL
+}
+
+type schemaReference struct {
+	base *url.URL
+	ref  string
+}
+
+// A schemaRegistry holds the schema documents, embedded resources and anchors
+// that references may resolve to. Schema files are only loaded from within
+// the root directory.
+type schemaRegistry struct {
+	root      string
+	resources map[string]schemaResource
+	anchors   map[string]schemaResource
+	dynamic   map[string]bool
+	regexps   map[string]*regexp.Regexp
+	refs      []schemaReference
+}
+
+func newSchemaRegistry(root string) *schemaRegistry {
+	return &schemaRegistry{
+		root:      root,
+		resources: map[string]schemaResource{},
+		anchors:   map[string]schemaResource{},
+		dynamic:   map[string]bool{},
+		regexps:   map[string]*regexp.Regexp{},
+	}
+}
+
+// Keywords whose values are subschemas, maps of subschemas, or arrays of
+// subschemas.
+var (
+	schemaKeywords = []string{
+		"additionalProperties", "contains", "contentSchema", "else", "if", "items",
+		"not", "propertyNames", "then", "unevaluatedItems", "unevaluatedProperties",
+	}
+	schemaMapKeywords   = []string{"$defs", "definitions", "dependentSchemas", "patternProperties", "properties"}
+	schemaArrayKeywords = []string{"allOf", "anyOf", "oneOf", "prefixItems"}
+)
+
+// compile indexes a schema document and resolves all references, loading any
+// referenced schema files.
+func (r *schemaRegistry) compile(schema any, base *url.URL) error {
+	if err := r.index(schema, base); err != nil {
+		return err
+	}
+
+	for i := 0; i < len(r.refs); i++ {
+		if _, _, err := r.resolve(r.refs[i].base, r.refs[i].ref); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// index registers the embedded resources and anchors of a schema, compiles
+// its regular expressions, and records its references.
+func (r *schemaRegistry) index(schema any, base *url.URL) error {
+	m, ok := asObject(schema)
+	if !ok {
+		if _, ok := schema.(bool); !ok {
+			return fmt.Errorf("schema %s: expected object or boolean, got %T", base, schema)
+		}
+		return nil
+	}
+
+	if id, ok := m["$id"].(string); ok {
+		u, err := base.Parse(id)
+		if err != nil {
+			return fmt.Errorf("schema %s: invalid $id %q: %w", base, id, err)
+		}
+		base = withoutFragment(u)
+		r.resources[base.String()] = schemaResource{node: schema, base: base}
+	}
+
+	for _, keyword := range []string{"$anchor", "$dynamicAnchor"} {
+		if anchor, ok := m[keyword].(string); ok {
+			key := base.String() + "#" + anchor
+			r.anchors[key] = schemaResource{node: schema, base: base}
+			if keyword == "$dynamicAnchor" {
+				r.dynamic[key] = true
+			}
+		}
+	}
+
+	for _, keyword := range []string{"$ref", "$dynamicRef"} {
+		if ref, ok := m[keyword].(string); ok {
+			r.refs = append(r.refs, schemaReference{base: base, ref: ref})
+		}
+	}
+
+	patterns := []string{}
+	if pattern, ok := m["pattern"].(string); ok {
+		patterns = append(patterns, pattern)
+	}
+	if props, ok := asObject(m["patternProperties"]); ok {
+		for pattern := range props {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("schema %s: invalid pattern %q: %w", base, pattern, err)
+		}
+		r.regexps[pattern] = re
+	}
+
+	var subschemas []any
+	for _, keyword := range schemaKeywords {
+		if sub, ok := m[keyword]; ok {
+			subschemas = append(subschemas, sub)
+		}
+	}
+	for _, keyword := range schemaMapKeywords {
+		if subs, ok := asObject(m[keyword]); ok {
+			for _, sub := range subs {
+				subschemas = append(subschemas, sub)
+			}
+		}
+	}
+	for _, keyword := range schemaArrayKeywords {
+		if subs, ok := asArray(m[keyword]); ok {
+			subschemas = append(subschemas, subs...)
+		}
+	}
+
+	for _, sub := range subschemas {
+		if err := r.index(sub, base); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// load reads, indexes and registers a schema file.
+func (r *schemaRegistry) load(u *url.URL) (schemaResource, error) {
+	path := filepath.FromSlash(u.Path)
+	if rel, err := filepath.Rel(r.root, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return schemaResource{}, fmt.Errorf("schema %q: outside of schema directory %q", path, r.root)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return schemaResource{}, fmt.Errorf("schema %q: %w", path, err)
+	}
+
+	var doc any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &doc)
+	default:
+		err = stdjson.Unmarshal(b, &doc)
+	}
+	if err != nil {
+		return schemaResource{}, fmt.Errorf("schema %q: %w", path, err)
+	}
+
+	resource := schemaResource{node: doc, base: u}
+	r.resources[u.String()] = resource
+	if err := r.index(doc, u); err != nil {
+		return schemaResource{}, err
+	}
+
+	return resource, nil
+}
+
+// resolve resolves a reference against a base URI, loading the referenced
+// schema file if necessary.
+func (r *schemaRegistry) resolve(base *url.URL, ref string) (any, *url.URL, error) {
+	u, err := base.Parse(ref)
+	if err != nil {
+		return nil, nil, fmt.Errorf("schema %s: invalid reference %q: %w", base, ref, err)
+	}
+
+	fragment := u.Fragment
+	u = withoutFragment(u)
+	doc, ok := r.resources[u.String()]
+	if !ok {
+		if u.Scheme != "file" {
+			return nil, nil, fmt.Errorf("schema %s: unresolvable reference %q", base, ref)
+		}
+
+		if doc, err = r.load(u); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if fragment == "" {
+		return doc.node, doc.base, nil
+	}
+
+	if !strings.HasPrefix(fragment, "/") {
+		anchor, ok := r.anchors[u.String()+"#"+fragment]
+		if !ok {
+			return nil, nil, fmt.Errorf("schema %s: unresolvable reference %q", base, ref)
+		}
+		return anchor.node, anchor.base, nil
+	}
+
+	node, nodeBase := doc.node, doc.base
+	for _, token := range strings.Split(fragment[1:], "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		if m, ok := asObject(node); ok {
+			if id, ok := m["$id"].(string); ok {
+				if idURI, err := nodeBase.Parse(id); err == nil {
+					nodeBase = withoutFragment(idURI)
+				}
+			}
+
+			if node, ok = m[token]; !ok {
+				return nil, nil, fmt.Errorf("schema %s: unresolvable reference %q", base, ref)
+			}
+		} else if a, ok := asArray(node); ok {
+			i, err := parseIndex(token, len(a))
+			if err != nil {
+				return nil, nil, fmt.Errorf("schema %s: unresolvable reference %q", base, ref)
+			}
+			node = a[i]
+		} else {
+			return nil, nil, fmt.Errorf("schema %s: unresolvable reference %q", base, ref)
+		}
+	}
+
+	return node, nodeBase, nil
+}
+
+func parseIndex(token string, n int) (int, error) {
+	var i int
+	if _, err := fmt.Sscanf(token, "%d", &i); err != nil || fmt.Sprint(i) != token || i < 0 || i >= n {
+		return 0, fmt.Errorf("invalid index %q", token)
+	}
+	return i, nil
+}
+
+// evaluated records the object members and array elements evaluated by a
+// schema, for use by unevaluatedProperties and unevaluatedItems.
+type evaluated struct {
+	props    map[string]bool
+	items    int
+	allItems bool
+	indices  map[int]bool
+}
+
+func newEvaluated() *evaluated {
+	return &evaluated{props: map[string]bool{}, indices: map[int]bool{}}
+}
+
+func (e *evaluated) merge(other *evaluated) {
+	for k := range other.props {
+		e.props[k] = true
+	}
+	for i := range other.indices {
+		e.indices[i] = true
+	}
+	if other.items > e.items {
+		e.items = other.items
+	}
+	e.allItems = e.allItems || other.allItems
+}
+
+type schemaValidator struct {
+	registry *schemaRegistry
+	scope    []*url.URL
+	active   map[refVisit]bool
+}
+
+// A refVisit identifies a schema reached through a reference while it's being
+// applied to a value, so that circular references can be detected.
+type refVisit struct {
+	node     uintptr
+	location string
+	kind     string
+}
+
+func (sv *schemaValidator) validate(schema any, base *url.URL, instance any, fields []string) ([]SchemaViolation, *evaluated) {
+	ev := newEvaluated()
+	var violations []SchemaViolation
+	fail := func(keyword, format string, args ...any) {
+		violations = append(violations, SchemaViolation{
+			Fields:  fields,
+			Keyword: keyword,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	if b, ok := schema.(bool); ok {
+		if !b {
+			fail("false", "expected no value, got %s", describeJSON(instance))
+		}
+		return violations, ev
+	}
+
+	m, ok := asObject(schema)
+	if !ok {
+		return nil, ev
+	}
+
+	if id, ok := m["$id"].(string); ok {
+		if u, err := base.Parse(id); err == nil {
+			base = withoutFragment(u)
+		}
+	}
+
+	if len(sv.scope) == 0 || sv.scope[len(sv.scope)-1].String() != base.String() {
+		sv.scope = append(sv.scope, base)
+		defer func() { sv.scope = sv.scope[:len(sv.scope)-1] }()
+	}
+
+	// apply runs a subschema against a value, recording its violations, and
+	// returns whether it passed along with the members and elements it
+	// evaluated.
+	apply := func(sub any, subBase *url.URL, value any, subFields []string) (bool, *evaluated) {
+		v, e := sv.validate(sub, subBase, value, subFields)
+		violations = append(violations, v...)
+		return len(v) == 0, e
+	}
+
+	// test runs a subschema without recording its violations.
+	test := func(sub any, value any, subFields []string) (bool, *evaluated) {
+		v, e := sv.validate(sub, base, value, subFields)
+		return len(v) == 0, e
+	}
+
+	// applyRef applies the target of a reference, unless it's already being
+	// applied to the same value through a chain of references that would
+	// otherwise recurse forever.
+	applyRef := func(keyword, ref string, target any, targetBase *url.URL) {
+		visit := refVisit{location: strings.Join(fields, "\x00"), kind: fmt.Sprintf("%T", instance)}
+		if rv := reflect.ValueOf(target); rv.Kind() == reflect.Map {
+			visit.node = rv.Pointer()
+		}
+
+		if visit.node != 0 {
+			if sv.active[visit] {
+				fail(keyword, "circular reference %q", ref)
+				return
+			}
+
+			if sv.active == nil {
+				sv.active = map[refVisit]bool{}
+			}
+			sv.active[visit] = true
+			defer delete(sv.active, visit)
+		}
+
+		if ok, e := apply(target, targetBase, instance, fields); ok {
+			ev.merge(e)
+		}
+	}
+
+	// references
+	if ref, ok := m["$ref"].(string); ok {
+		if target, targetBase, err := sv.registry.resolve(base, ref); err != nil {
+			fail("$ref", "%s", err)
+		} else {
+			applyRef("$ref", ref, target, targetBase)
+		}
+	}
+
+	if ref, ok := m["$dynamicRef"].(string); ok {
+		if target, targetBase, err := sv.resolveDynamic(base, ref); err != nil {
+			fail("$dynamicRef", "%s", err)
+		} else {
+			applyRef("$dynamicRef", ref, target, targetBase)
+		}
+	}
+
+	// any instance type
+	if t, ok := m["type"]; ok {
+		var types []string
+		if s, ok := t.(string); ok {
+			types = []string{s}
+		} else if a, ok := asArray(t); ok {
+			for _, v := range a {
+				if s, ok := v.(string); ok {
+					types = append(types, s)
+				}
+			}
+		}
+
+		if !hasJSONType(instance, types) {
+			fail("type", "expected type %s, got %s", strings.Join(types, " or "), jsonType(instance))
+		}
+	}
+
+	if enum, ok := asArray(m["enum"]); ok {
+		found := false
+		for _, v := range enum {
+			if jsonEqual(v, instance) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			fail("enum", "expected one of %s, got %s", describeJSON(enum), describeJSON(instance))
+		}
+	}
+
+	if c, ok := m["const"]; ok && !jsonEqual(c, instance) {
+		fail("const", "expected %s, got %s", describeJSON(c), describeJSON(instance))
+	}
+
+	// numbers
+	if n, ok := toNumber(instance); ok {
+		if d, ok := toNumber(m["multipleOf"]); ok && d > 0 {
+			if q := n / d; math.Abs(q-math.Round(q)) > 1e-9 {
+				fail("multipleOf", "expected a multiple of %g, got %g", d, n)
+			}
+		}
+		if limit, ok := toNumber(m["minimum"]); ok && n < limit {
+			fail("minimum", "expected at least %g, got %g", limit, n)
+		}
+		if limit, ok := toNumber(m["exclusiveMinimum"]); ok && n <= limit {
+			fail("exclusiveMinimum", "expected more than %g, got %g", limit, n)
+		}
+		if limit, ok := toNumber(m["maximum"]); ok && n > limit {
+			fail("maximum", "expected at most %g, got %g", limit, n)
+		}
+		if limit, ok := toNumber(m["exclusiveMaximum"]); ok && n >= limit {
+			fail("exclusiveMaximum", "expected less than %g, got %g", limit, n)
+		}
+	}
+
+	// strings
+	if s, ok := instance.(string); ok {
+		length := utf8.RuneCountInString(s)
+		if limit, ok := toNumber(m["minLength"]); ok && float64(length) < limit {
+			fail("minLength", "expected at least %g characters, got %d", limit, length)
+		}
+		if limit, ok := toNumber(m["maxLength"]); ok && float64(length) > limit {
+			fail("maxLength", "expected at most %g characters, got %d", limit, length)
+		}
+		if pattern, ok := m["pattern"].(string); ok && !sv.registry.regexps[pattern].MatchString(s) {
+			fail("pattern", "expected to match pattern %q, got %q", pattern, s)
+		}
+		if format, ok := m["format"].(string); ok && !checkFormat(format, s) {
+			fail("format", "expected %s format, got %q", format, s)
+		}
+	}
+
+	// arrays
+	if a, ok := asArray(instance); ok {
+		prefix := 0
+		if prefixItems, ok := asArray(m["prefixItems"]); ok {
+			for i := 0; i < len(prefixItems) && i < len(a); i++ {
+				apply(prefixItems[i], base, a[i], childFields(fields, indexField(i)))
+				prefix++
+			}
+			ev.items = prefix
+		}
+
+		if items, ok := m["items"]; ok {
+			for i := prefix; i < len(a); i++ {
+				if items == false {
+					violations = append(violations, SchemaViolation{Fields: childFields(fields, indexField(i)), Keyword: "items", Message: "unexpected element"})
+					continue
+				}
+				apply(items, base, a[i], childFields(fields, indexField(i)))
+			}
+			ev.allItems = true
+		}
+
+		if contains, ok := m["contains"]; ok {
+			matched := 0
+			for i, v := range a {
+				if ok, _ := test(contains, v, childFields(fields, indexField(i))); ok {
+					matched++
+					ev.indices[i] = true
+				}
+			}
+
+			minContains := 1.0
+			if limit, ok := toNumber(m["minContains"]); ok {
+				minContains = limit
+			}
+			if float64(matched) < minContains {
+				fail("contains", "expected at least %g elements matching contains, got %d", minContains, matched)
+			}
+			if limit, ok := toNumber(m["maxContains"]); ok && float64(matched) > limit {
+				fail("maxContains", "expected at most %g elements matching contains, got %d", limit, matched)
+			}
+		}
+
+		if limit, ok := toNumber(m["minItems"]); ok && float64(len(a)) < limit {
+			fail("minItems", "expected at least %g elements, got %d", limit, len(a))
+		}
+		if limit, ok := toNumber(m["maxItems"]); ok && float64(len(a)) > limit {
+			fail("maxItems", "expected at most %g elements, got %d", limit, len(a))
+		}
+		if unique, _ := m["uniqueItems"].(bool); unique {
+		unique:
+			for i := range a {
+				for j := i + 1; j < len(a); j++ {
+					if jsonEqual(a[i], a[j]) {
+						fail("uniqueItems", "expected unique elements, got duplicates at %s and %s", indexField(i), indexField(j))
+						break unique
+					}
+				}
+			}
+		}
+	}
+
+	// objects
+	if obj, ok := asObject(instance); ok {
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		props, _ := asObject(m["properties"])
+		patternProps, _ := asObject(m["patternProperties"])
+		patterns := make([]string, 0, len(patternProps))
+		for pattern := range patternProps {
+			patterns = append(patterns, pattern)
+		}
+		sort.Strings(patterns)
+
+		for _, k := range keys {
+			known := false
+			if sub, ok := props[k]; ok {
+				known = true
+				apply(sub, base, obj[k], childFields(fields, keyField(k)))
+			}
+
+			for _, pattern := range patterns {
+				if sv.registry.regexps[pattern].MatchString(k) {
+					known = true
+					apply(patternProps[pattern], base, obj[k], childFields(fields, keyField(k)))
+				}
+			}
+
+			if additional, ok := m["additionalProperties"]; ok && !known {
+				known = true
+				if additional == false {
+					violations = append(violations, SchemaViolation{Fields: childFields(fields, keyField(k)), Keyword: "additionalProperties", Message: "unexpected field"})
+				} else {
+					apply(additional, base, obj[k], childFields(fields, keyField(k)))
+				}
+			}
+
+			if known {
+				ev.props[k] = true
+			}
+
+			if names, ok := m["propertyNames"]; ok {
+				if nameViolations, _ := sv.validate(names, base, k, nil); len(nameViolations) > 0 {
+					violations = append(violations, SchemaViolation{
+						Fields:  childFields(fields, keyField(k)),
+						Keyword: "propertyNames",
+						Message: "invalid field name: " + nameViolations[0].Message,
+					})
+				}
+			}
+		}
+
+		if required, ok := asArray(m["required"]); ok {
+			for _, r := range required {
+				if name, ok := r.(string); ok {
+					if _, ok := obj[name]; !ok {
+						violations = append(violations, SchemaViolation{Fields: childFields(fields, keyField(name)), Keyword: "required", Message: "expected required field, got nothing"})
+					}
+				}
+			}
+		}
+
+		if dependentRequired, ok := asObject(m["dependentRequired"]); ok {
+			for _, k := range sortedKeys(dependentRequired) {
+				if _, ok := obj[k]; !ok {
+					continue
+				}
+
+				names, _ := asArray(dependentRequired[k])
+				for _, n := range names {
+					if name, ok := n.(string); ok {
+						if _, ok := obj[name]; !ok {
+							violations = append(violations, SchemaViolation{Fields: childFields(fields, keyField(name)), Keyword: "dependentRequired", Message: fmt.Sprintf("expected field required by %q, got nothing", k)})
+						}
+					}
+				}
+			}
+		}
+
+		if dependentSchemas, ok := asObject(m["dependentSchemas"]); ok {
+			for _, k := range sortedKeys(dependentSchemas) {
+				if _, ok := obj[k]; ok {
+					if ok, e := apply(dependentSchemas[k], base, instance, fields); ok {
+						ev.merge(e)
+					}
+				}
+			}
+		}
+
+		if limit, ok := toNumber(m["minProperties"]); ok && float64(len(obj)) < limit {
+			fail("minProperties", "expected at least %g fields, got %d", limit, len(obj))
+		}
+		if limit, ok := toNumber(m["maxProperties"]); ok && float64(len(obj)) > limit {
+			fail("maxProperties", "expected at most %g fields, got %d", limit, len(obj))
+		}
+	}
+
+	// applicators
+	if allOf, ok := asArray(m["allOf"]); ok {
+		for _, sub := range allOf {
+			if ok, e := apply(sub, base, instance, fields); ok {
+				ev.merge(e)
+			}
+		}
+	}
+
+	if anyOf, ok := asArray(m["anyOf"]); ok {
+		matched := 0
+		for _, sub := range anyOf {
+			if ok, e := test(sub, instance, fields); ok {
+				matched++
+				ev.merge(e)
+			}
+		}
+
+		if matched == 0 {
+			fail("anyOf", "expected to match at least one of %d schemas, matched none", len(anyOf))
+		}
+	}
+
+	if oneOf, ok := asArray(m["oneOf"]); ok {
+		matched := 0
+		for _, sub := range oneOf {
+			if ok, e := test(sub, instance, fields); ok {
+				matched++
+				ev.merge(e)
+			}
+		}
+
+		if matched != 1 {
+			fail("oneOf", "expected to match exactly one of %d schemas, matched %d", len(oneOf), matched)
+		}
+	}
+
+	if not, ok := m["not"]; ok {
+		if ok, _ := test(not, instance, fields); ok {
+			fail("not", "expected not to match schema")
+		}
+	}
+
+	if cond, ok := m["if"]; ok {
+		if ok, e := test(cond, instance, fields); ok {
+			ev.merge(e)
+			if then, ok := m["then"]; ok {
+				if ok, e := apply(then, base, instance, fields); ok {
+					ev.merge(e)
+				}
+			}
+		} else if els, ok := m["else"]; ok {
+			if ok, e := apply(els, base, instance, fields); ok {
+				ev.merge(e)
+			}
+		}
+	}
+
+	// unevaluated members and elements, after all other keywords
+	if unevaluated, ok := m["unevaluatedProperties"]; ok {
+		if obj, ok := asObject(instance); ok {
+			for _, k := range sortedKeys(obj) {
+				if ev.props[k] {
+					continue
+				}
+
+				if unevaluated == false {
+					violations = append(violations, SchemaViolation{Fields: childFields(fields, keyField(k)), Keyword: "unevaluatedProperties", Message: "unexpected field"})
+				} else {
+					apply(unevaluated, base, obj[k], childFields(fields, keyField(k)))
+				}
+				ev.props[k] = true
+			}
+		}
+	}
+
+	if unevaluated, ok := m["unevaluatedItems"]; ok {
+		if a, ok := asArray(instance); ok && !ev.allItems {
+			for i := ev.items; i < len(a); i++ {
+				if ev.indices[i] {
+					continue
+				}
+
+				if unevaluated == false {
+					violations = append(violations, SchemaViolation{Fields: childFields(fields, indexField(i)), Keyword: "unevaluatedItems", Message: "unexpected element"})
+				} else {
+					apply(unevaluated, base, a[i], childFields(fields, indexField(i)))
+				}
+			}
+			ev.allItems = true
+		}
+	}
+
+	return violations, ev
+}
+
+// resolveDynamic resolves a $dynamicRef. If the reference statically resolves
+// to a $dynamicAnchor, the outermost schema resource in the dynamic scope that
+// declares the same $dynamicAnchor is used instead.
+func (sv *schemaValidator) resolveDynamic(base *url.URL, ref string) (any, *url.URL, error) {
+	target, targetBase, err := sv.registry.resolve(base, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u, err := base.Parse(ref)
+	if err != nil || u.Fragment == "" || strings.HasPrefix(u.Fragment, "/") {
+		return target, targetBase, nil
+	}
+
+	if !sv.registry.dynamic[withoutFragment(u).String()+"#"+u.Fragment] {
+		return target, targetBase, nil
+	}
+
+	for _, scope := range sv.scope {
+		key := scope.String() + "#" + u.Fragment
+		if sv.registry.dynamic[key] {
+			anchor := sv.registry.anchors[key]
+			return anchor.node, anchor.base, nil
+		}
+	}
+
+	return target, targetBase, nil
+}
+
+func childFields(fields []string, field string) []string {
+	c := make([]string, len(fields), len(fields)+1)
+	copy(c, fields)
+	return append(c, field)
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// jsonType returns the JSON Schema type of a value. Numbers without a
+// fractional part are integers.
+func jsonType(v any) string {
+	if n, ok := toNumber(v); ok {
+		if n == math.Trunc(n) && !math.IsInf(n, 0) {
+			return "integer"
+		}
+		return "number"
+	}
+
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	}
+
+	if _, ok := asObject(v); ok {
+		return "object"
+	} else if _, ok := asArray(v); ok {
+		return "array"
+	}
+
+	return fmt.Sprintf("%T", v)
+}
+
+func hasJSONType(v any, types []string) bool {
+	actual := jsonType(v)
+	for _, t := range types {
+		if t == actual || t == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonEqual compares two JSON values, comparing numbers numerically.
+func jsonEqual(a, b any) bool {
+	if x, ok := toNumber(a); ok {
+		y, ok := toNumber(b)
+		return ok && x == y
+	}
+
+	if x, ok := asObject(a); ok {
+		y, ok := asObject(b)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			if w, ok := y[k]; !ok || !jsonEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if x, ok := asArray(a); ok {
+		y, ok := asArray(b)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// describeJSON formats a value as JSON for use in violation messages.
+func describeJSON(v any) string {
+	b, err := stdjson.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%+v", v)
+	}
+	return string(b)
+}
+
+var (
+	uuidPattern     = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hostnamePattern = regexp.MustCompile(`^(?i:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?)(?:\.(?i:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?))*$`)
+)
+
+// checkFormat reports whether a string conforms to a format. Unknown formats
+// always conform.
+func checkFormat(format, s string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339Nano, strings.ToUpper(s))
+		return err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", s)
+		return err == nil
+	case "time":
+		_, err := time.Parse("15:04:05.999999999Z07:00", strings.ToUpper(s))
+		return err == nil
+	case "email":
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	case "uuid":
+		return uuidPattern.MatchString(s)
+	case "uri":
+		u, err := url.Parse(s)
+		return err == nil && u.IsAbs()
+	case "uri-reference":
+		_, err := url.Parse(s)
+		return err == nil
+	case "ipv4":
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil && !strings.Contains(s, ":")
+	case "ipv6":
+		return net.ParseIP(s) != nil && strings.Contains(s, ":")
+	case "hostname":
+		return len(s) <= 253 && hostnamePattern.MatchString(s)
+	case "regex":
+		_, err := regexp.Compile(s)
+		return err == nil
+	}
+
+	return true
+}
@@ -0,0 +1,300 @@
+package json_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jefflinse/melatonin/json"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchemaValidate(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		schema   string
+		instance any
+		want     []string
+	}{
+		{
+			name:     "true schema",
+			schema:   `true`,
+			instance: map[string]any{"a": 1.0},
+		},
+		{
+			name:     "false schema",
+			schema:   `false`,
+			instance: 1.0,
+			want:     []string{"$: expected no value, got 1"},
+		},
+		{
+			name:     "types",
+			schema:   `{"type": "object", "properties": {"id": {"type": "integer"}, "name": {"type": ["string", "null"]}, "score": {"type": "number"}}}`,
+			instance: map[string]any{"id": 1.5, "name": true, "score": 3.0},
+			want: []string{
+				"$.id: expected type integer, got number",
+				"$.name: expected type string or null, got boolean",
+			},
+		},
+		{
+			name:     "enum and const",
+			schema:   `{"properties": {"role": {"enum": ["admin", "user"]}, "version": {"const": 2}}}`,
+			instance: map[string]any{"role": "guest", "version": 2.0},
+			want:     []string{`$.role: expected one of ["admin","user"], got "guest"`},
+		},
+		{
+			name:     "numbers",
+			schema:   `{"items": {"multipleOf": 0.5, "minimum": 0, "exclusiveMaximum": 10}}`,
+			instance: []any{1.5, 0.3, -1.0, 10.0},
+			want: []string{
+				"$[1]: expected a multiple of 0.5, got 0.3",
+				"$[2]: expected at least 0, got -1",
+				"$[3]: expected less than 10, got 10",
+			},
+		},
+		{
+			name:     "strings",
+			schema:   `{"properties": {"code": {"minLength": 3, "maxLength": 4, "pattern": "^[A-Z]+$"}, "email": {"format": "email"}, "at": {"format": "date-time"}, "id": {"format": "uuid"}}}`,
+			instance: map[string]any{"code": "ab", "email": "bob@example.com", "at": "2024-01-02T03:04:05Z", "id": "nope"},
+			want: []string{
+				"$.code: expected at least 3 characters, got 2",
+				`$.code: expected to match pattern "^[A-Z]+$", got "ab"`,
+				`$.id: expected uuid format, got "nope"`,
+			},
+		},
+		{
+			name:     "arrays",
+			schema:   `{"prefixItems": [{"type": "string"}], "items": {"type": "integer"}, "minItems": 4, "uniqueItems": true, "contains": {"const": 3}}`,
+			instance: []any{"a", 1.0, 2.5, 1.0},
+			want: []string{
+				"$[2]: expected type integer, got number",
+				"$: expected at least 1 elements matching contains, got 0",
+				"$: expected unique elements, got duplicates at [1] and [3]",
+			},
+		},
+		{
+			name:     "objects",
+			schema:   `{"properties": {"id": {}}, "patternProperties": {"^x-": {"type": "string"}}, "additionalProperties": false, "required": ["id", "name"], "dependentRequired": {"id": ["version"]}, "maxProperties": 2}`,
+			instance: map[string]any{"id": 1.0, "x-trace": 2.0, "extra": true},
+			want: []string{
+				"$.extra: unexpected field",
+				"$.x-trace: expected type string, got integer",
+				"$.name: expected required field, got nothing",
+				`$.version: expected field required by "id", got nothing`,
+				"$: expected at most 2 fields, got 3",
+			},
+		},
+		{
+			name:     "property names",
+			schema:   `{"propertyNames": {"pattern": "^[a-z]+$"}}`,
+			instance: map[string]any{"ok": 1.0, "Bad": 2.0},
+			want:     []string{`$.Bad: invalid field name: expected to match pattern "^[a-z]+$", got "Bad"`},
+		},
+		{
+			name:     "applicators",
+			schema:   `{"allOf": [{"type": "object"}], "anyOf": [{"required": ["a"]}, {"required": ["b"]}], "oneOf": [{"required": ["c"]}, {"required": ["d"]}], "not": {"required": ["e"]}}`,
+			instance: map[string]any{"c": 1.0, "d": 1.0, "e": 1.0},
+			want: []string{
+				"$: expected to match at least one of 2 schemas, matched none",
+				"$: expected to match exactly one of 2 schemas, matched 2",
+				"$: expected not to match schema",
+			},
+		},
+		{
+			name:     "if then else",
+			schema:   `{"if": {"properties": {"kind": {"const": "card"}}}, "then": {"required": ["number"]}, "else": {"required": ["iban"]}}`,
+			instance: []any{map[string]any{"kind": "card"}, map[string]any{"kind": "bank"}},
+		},
+		{
+			name:     "if then else on elements",
+			schema:   `{"items": {"if": {"properties": {"kind": {"const": "card"}}}, "then": {"required": ["number"]}, "else": {"required": ["iban"]}}}`,
+			instance: []any{map[string]any{"kind": "card"}, map[string]any{"kind": "bank"}},
+			want: []string{
+				"$[0].number: expected required field, got nothing",
+				"$[1].iban: expected required field, got nothing",
+			},
+		},
+		{
+			name:     "unevaluated properties",
+			schema:   `{"allOf": [{"properties": {"a": {}}}], "anyOf": [{"properties": {"b": {}}, "required": ["b"]}, {"required": ["zzz"]}], "unevaluatedProperties": false}`,
+			instance: map[string]any{"a": 1.0, "b": 2.0, "c": 3.0},
+			want:     []string{"$.c: unexpected field"},
+		},
+		{
+			name:     "unevaluated items",
+			schema:   `{"prefixItems": [{}], "contains": {"type": "string"}, "unevaluatedItems": {"type": "boolean"}}`,
+			instance: []any{1.0, "a", true, 2.0},
+			want:     []string{"$[3]: expected type boolean, got integer"},
+		},
+		{
+			name:     "local references and anchors",
+			schema:   `{"$defs": {"id": {"$anchor": "id", "type": "integer", "minimum": 1}, "user": {"properties": {"id": {"$ref": "#id"}, "friends": {"items": {"$ref": "#/$defs/user"}}}}}, "$ref": "#/$defs/user"}`,
+			instance: map[string]any{"id": 1.0, "friends": []any{map[string]any{"id": 0.0}}},
+			want:     []string{"$.friends[0].id: expected at least 1, got 0"},
+		},
+		{
+			name:     "embedded resources",
+			schema:   `{"$id": "https://example.com/root", "properties": {"tag": {"$ref": "tag"}}, "$defs": {"tag": {"$id": "tag", "type": "string"}}}`,
+			instance: map[string]any{"tag": 1.0},
+			want:     []string{"$.tag: expected type string, got integer"},
+		},
+		{
+			name: "dynamic references",
+			schema: `{
+				"$id": "https://example.com/strings",
+				"$ref": "list",
+				"$defs": {
+					"string": {"$dynamicAnchor": "item", "type": "string"},
+					"list": {"$id": "list", "type": "array", "items": {"$dynamicRef": "#item"}, "$defs": {"any": {"$dynamicAnchor": "item"}}}
+				}
+			}`,
+			instance: []any{"a", 1.0},
+			want:     []string{"$[1]: expected type string, got integer"},
+		},
+		{
+			name:     "circular references",
+			schema:   `{"$defs": {"a": {"$ref": "#/$defs/b"}, "b": {"$ref": "#/$defs/a"}}, "$ref": "#/$defs/a"}`,
+			instance: 1.0,
+			want:     []string{`$: circular reference "#/$defs/a"`},
+		},
+		{
+			name:     "recursion through property names",
+			schema:   `{"$defs": {"key": {"propertyNames": {"$ref": "#/$defs/key"}, "maxLength": 2}}, "$ref": "#/$defs/key"}`,
+			instance: map[string]any{"ab": 1.0, "abc": 2.0},
+			want:     []string{`$.abc: invalid field name: expected at most 2 characters, got 3`},
+		},
+		{
+			name:   "typed instances",
+			schema: `{"properties": {"name": {"type": "string"}, "age": {"type": "integer", "minimum": 18}}}`,
+			instance: struct {
+				Name string `json:"name"`
+				Age  int    `json:"age"`
+			}{"Bob", 17},
+			want: []string{"$.age: expected at least 18, got 17"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			schema, err := json.CompileSchema([]byte(test.schema), "")
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			var got []string
+			for _, v := range schema.Validate(test.instance) {
+				got = append(got, v.Error())
+			}
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestLoadSchema(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"user.json":        `{"type": "object", "properties": {"id": {"$ref": "common/defs.json#/$defs/id"}, "address": {"$ref": "common/address.yaml"}}}`,
+		"common/defs.json": `{"$defs": {"id": {"type": "integer"}}}`,
+		"common/address.yaml": `
+type: object
+required: [city]
+properties:
+  zip: {$ref: "defs.json#/$defs/id"}
+`,
+		"broken.json":   `{"$ref": "missing.json"}`,
+		"pointer.json":  `{"$ref": "common/defs.json#/$defs/nope"}`,
+		"parent.json":   `{"$ref": "../outside.json"}`,
+		"absolute.json": `{"$ref": "file:///etc/passwd"}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	_, err := json.LoadSchema(filepath.Join(dir, "user.json"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "outside of schema directory")
+	}
+
+	previous := json.SchemaDir()
+	json.SetSchemaDir(dir)
+	t.Cleanup(func() { json.SetSchemaDir(previous) })
+
+	schema, err := json.LoadSchema("user.json")
+	if assert.NoError(t, err) {
+		var got []string
+		for _, v := range schema.Validate(map[string]any{"id": "1", "address": map[string]any{"zip": 1.5}}) {
+			got = append(got, v.Error())
+		}
+		assert.Equal(t, []string{
+			"$.address.zip: expected type integer, got number",
+			"$.address.city: expected required field, got nothing",
+			"$.id: expected type integer, got string",
+		}, got)
+	}
+
+	_, err = json.LoadSchema(filepath.Join(dir, "broken.json"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "missing.json")
+	}
+
+	_, err = json.LoadSchema(filepath.Join(dir, "pointer.json"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `unresolvable reference "common/defs.json#/$defs/nope"`)
+	}
+
+	for _, name := range []string{"parent.json", "absolute.json"} {
+		_, err = json.LoadSchema(filepath.Join(dir, name))
+		if assert.Error(t, err, name) {
+			assert.Contains(t, err.Error(), "outside of schema directory")
+		}
+	}
+
+	for _, d := range []string{dir, ""} {
+		inline, err := json.CompileSchema(map[string]any{"$ref": "common/defs.json#/$defs/id"}, d)
+		if assert.NoError(t, err) {
+			assert.Len(t, inline.Validate(1.0), 0)
+			assert.Len(t, inline.Validate("1"), 1)
+		}
+	}
+
+	_, err = json.CompileSchema(map[string]any{"pattern": "["}, dir)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `invalid pattern "["`)
+	}
+}
+
+func TestSubschema(t *testing.T) {
+	doc := map[string]any{
+		"paths": map[string]any{
+			"/users/{id}": map[string]any{
+				"schema": map[string]any{"$ref": "#/components/schemas/user"},
+			},
+		},
+		"components": map[string]any{
+			"schemas": map[string]any{
+				"user": map[string]any{"properties": map[string]any{"name": map[string]any{"pattern": "^[a-z]+$"}}},
+			},
+		},
+	}
+
+	schema, err := json.CompileSchema(doc, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = schema.Subschema("/components/schemas/user")
+	assert.NoError(t, err)
+
+	sub, err := schema.Subschema("/paths/~1users~1{id}/schema")
+	if assert.NoError(t, err) {
+		violations := sub.Validate(map[string]any{"name": "Bob"})
+		if assert.Len(t, violations, 1) {
+			assert.Equal(t, `$.name: expected to match pattern "^[a-z]+$", got "Bob"`, violations[0].Error())
+		}
+	}
+
+	_, err = schema.Subschema("/components/schemas/missing")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unresolvable reference")
+	}
+}
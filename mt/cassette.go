@@ -0,0 +1,242 @@
+package mt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/jefflinse/melatonin/cassette"
+)
+
+const (
+	// CassetteOff causes requests to be sent normally, without recording or
+	// replaying them.
+	CassetteOff = iota
+
+	// CassetteRecord causes requests to be sent normally and recorded to a
+	// cassette, replacing any existing cassette.
+	CassetteRecord
+
+	// CassetteReplay causes requests to be answered from a cassette without
+	// accessing the network.
+	CassetteReplay
+
+	// CassetteAuto causes requests to be replayed if the cassette exists,
+	// and recorded otherwise.
+	CassetteAuto
+)
+
+// cassetteRecorder records and replays the HTTP interactions of a context.
+type cassetteRecorder struct {
+	path      string
+	mode      int
+	match     cassette.MatchOn
+	redactors []cassette.Redactor
+
+	cassette   *cassette.Cassette
+	activeMode int
+	mu         sync.Mutex
+}
+
+// load prepares the recorder's cassette on first use, returning the mode in
+// effect for the rest of the run.
+func (r *cassetteRecorder) load() (int, error) {
+	if r.cassette != nil {
+		return r.activeMode, nil
+	}
+
+	if r.path == "" {
+		return CassetteOff, fmt.Errorf("no cassette path specified")
+	}
+
+	mode := r.mode
+	if cfg.CassetteMode >= 0 {
+		mode = cfg.CassetteMode
+	}
+
+	if mode == CassetteAuto {
+		exists, err := cassette.Exists(r.path)
+		if err != nil {
+			return CassetteOff, err
+		}
+
+		mode = CassetteRecord
+		if exists {
+			mode = CassetteReplay
+		}
+	}
+
+	switch mode {
+	case CassetteReplay:
+		c, err := cassette.Load(r.path)
+		if err != nil {
+			return CassetteOff, err
+		}
+		r.cassette = c
+	default:
+		r.cassette = cassette.New()
+	}
+
+	r.activeMode = mode
+	return mode, nil
+}
+
+// redact applies the recorder's redactors to an interaction.
+func (r *cassetteRecorder) redact(interaction *cassette.Interaction) {
+	for _, redactor := range cassette.DefaultRedactors {
+		redactor(interaction)
+	}
+
+	for _, redactor := range r.redactors {
+		redactor(interaction)
+	}
+}
+
+// cassetteTransport is an http.RoundTripper that records or replays requests
+// using a cassette.
+type cassetteTransport struct {
+	recorder *cassetteRecorder
+	base     http.RoundTripper
+}
+
+func (t *cassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.recorder.mu.Lock()
+	defer t.recorder.mu.Unlock()
+
+	mode, err := t.recorder.load()
+	if err != nil {
+		return nil, err
+	}
+
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	switch mode {
+	case CassetteRecord:
+		return t.record(req, base)
+	case CassetteReplay:
+		return t.replay(req)
+	default:
+		return base.RoundTrip(req)
+	}
+}
+
+func (t *cassetteTransport) record(req *http.Request, base http.RoundTripper) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	interaction := &cassette.Interaction{
+		Request:  cassette.NewRequest(req.Method, req.URL.String(), req.Header, reqBody),
+		Response: cassette.NewResponse(resp.StatusCode, resp.Header, respBody),
+	}
+
+	t.recorder.redact(interaction)
+	t.recorder.cassette.Add(interaction)
+	if err := t.recorder.cassette.Save(t.recorder.path); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (t *cassetteTransport) replay(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// redact the request the same way recorded requests were redacted so
+	// that redacted values still match
+	incoming := &cassette.Interaction{
+		Request: cassette.NewRequest(req.Method, req.URL.String(), req.Header, reqBody),
+	}
+	t.recorder.redact(incoming)
+
+	interaction, err := t.recorder.cassette.Match(incoming.Request, t.recorder.match)
+	if err != nil {
+		return nil, fmt.Errorf("cassette %q: %w", t.recorder.path, err)
+	}
+
+	body, err := interaction.Response.BodyBytes()
+	if err != nil {
+		return nil, fmt.Errorf("cassette %q: %w", t.recorder.path, err)
+	}
+
+	headers := interaction.Response.Headers.Clone()
+	if headers == nil {
+		headers = http.Header{}
+	}
+
+	// redaction may have changed the length of the recorded body
+	if headers.Get("Content-Length") != "" {
+		headers.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.Response.Status, http.StatusText(interaction.Response.Status)),
+		StatusCode:    interaction.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        headers,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// readRequestBody reads a request's body without consuming it.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+
+		defer body.Close()
+		return ioutil.ReadAll(body)
+	}
+
+	b, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
+
+// cassettePath resolves a cassette path relative to the working directory.
+func cassettePath(path string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cfg.WorkingDir, path)
+	}
+
+	return path
+}
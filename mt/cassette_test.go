@@ -0,0 +1,126 @@
+package mt_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/jefflinse/melatonin/cassette"
+	"github.com/jefflinse/melatonin/mt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":%q,"hit":%d}`, r.URL.Query().Get("id"), hits)
+	}))
+	t.Cleanup(server.Close)
+
+	path := filepath.Join(t.TempDir(), "cassettes", "users.json")
+	recording := mt.NewURLContext(server.URL).WithCassette(path, mt.CassetteRecord)
+	result := execute(t, recording.GET("/users").
+		WithQueryParam("id", "1").
+		WithHeader("Authorization", "Bearer secret").
+		ExpectBody(map[string]any{"id": "1", "hit": 1}))
+	assert.Nil(t, failures(result))
+
+	recorded, err := cassette.Load(path)
+	if assert.NoError(t, err) && assert.Len(t, recorded.Interactions, 1) {
+		assert.Equal(t, cassette.Redacted, recorded.Interactions[0].Request.Headers.Get("Authorization"))
+	}
+
+	for _, test := range []struct {
+		name         string
+		tc           func(*mt.HTTPTestContext) *mt.HTTPTestCase
+		wantFailures []string
+	}{
+		{
+			name: "recorded request",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/users").WithQueryParam("id", "1").ExpectBody(map[string]any{"id": "1", "hit": 1})
+			},
+		},
+		{
+			name: "unrecorded request",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/users").WithQueryParam("id", "2")
+			},
+			wantFailures: []string{fmt.Sprintf(
+				`failed to execute HTTP request: Get "%s/users?id=2": cassette %q: no recorded interaction matches GET %s/users?id=2`,
+				server.URL, path, server.URL)},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := mt.NewURLContext(server.URL).WithCassette(path, mt.CassetteReplay)
+			result := execute(t, test.tc(ctx))
+			assert.Equal(t, test.wantFailures, failures(result))
+		})
+	}
+
+	assert.Equal(t, 1, hits)
+}
+
+func TestCassetteModes(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		mode         int
+		record       bool
+		wantHits     int
+		wantCassette bool
+		wantFailures []string
+	}{
+		{
+			name:         "auto records when the cassette is missing",
+			mode:         mt.CassetteAuto,
+			wantHits:     1,
+			wantCassette: true,
+		},
+		{
+			name:         "auto replays when the cassette exists",
+			mode:         mt.CassetteAuto,
+			record:       true,
+			wantHits:     1,
+			wantCassette: true,
+		},
+		{
+			name:     "off sends requests without recording",
+			mode:     mt.CassetteOff,
+			wantHits: 1,
+		},
+		{
+			name:         "replay fails when the cassette is missing",
+			mode:         mt.CassetteReplay,
+			wantFailures: []string{`cassette %q: not found`},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			hits := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits++
+			}))
+			t.Cleanup(server.Close)
+
+			path := filepath.Join(t.TempDir(), "cassette.json")
+			if test.record {
+				execute(t, mt.NewURLContext(server.URL).WithCassette(path, mt.CassetteRecord).GET("/"))
+			}
+
+			result := execute(t, mt.NewURLContext(server.URL).WithCassette(path, test.mode).GET("/"))
+			var wantFailures []string
+			for _, failure := range test.wantFailures {
+				wantFailures = append(wantFailures, fmt.Sprintf(`failed to execute HTTP request: Get "%s/": `+failure, server.URL, path))
+			}
+			assert.Equal(t, wantFailures, failures(result))
+			assert.Equal(t, test.wantHits, hits)
+
+			exists, err := cassette.Exists(path)
+			assert.NoError(t, err)
+			assert.Equal(t, test.wantCassette, exists)
+		})
+	}
+}
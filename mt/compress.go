@@ -0,0 +1,42 @@
+package mt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+)
+
+// compressBody compresses a request body using the given content encoding.
+//
+// If malformed is true, the compressed stream is truncated so that it can't
+// be decompressed.
+func compressBody(b []byte, encoding string, malformed bool) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(buf)
+	case "deflate":
+		// the HTTP deflate encoding is a zlib stream
+		w = zlib.NewWriter(buf)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	compressed := buf.Bytes()
+	if malformed {
+		compressed = compressed[:len(compressed)/2]
+	}
+
+	return compressed, nil
+}
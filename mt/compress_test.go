@@ -0,0 +1,111 @@
+package mt_test
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jefflinse/melatonin/mt"
+	"github.com/stretchr/testify/assert"
+)
+
+// decompressingHandler responds with the content encoding and decompressed
+// body of a request, or a 400 if the body can't be decompressed.
+func decompressingHandler(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader
+	var err error
+	encoding := r.Header.Get("Content-Encoding")
+	switch encoding {
+	case "gzip":
+		body, err = gzip.NewReader(r.Body)
+	case "deflate":
+		body, err = zlib.NewReader(r.Body)
+	default:
+		body = r.Body
+	}
+
+	var b []byte
+	if err == nil {
+		b, err = io.ReadAll(body)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fmt.Fprintf(w, "%s %s", encoding, b)
+}
+
+func TestCompressedBodies(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		tc           func(*mt.HTTPTestContext) *mt.HTTPTestCase
+		wantStatus   int
+		wantBody     string
+		wantFailures []string
+	}{
+		{
+			name: "gzip",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/").WithBody(map[string]any{"a": 1}).WithCompression("gzip")
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `gzip {"a":1}`,
+		},
+		{
+			name: "deflate",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/").WithBody("hello").WithCompression("deflate")
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   "deflate hello",
+		},
+		{
+			name: "malformed gzip",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/").WithBody(strings.Repeat("hello", 100)).WithMalformedCompression("gzip")
+			},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "unexpected EOF\n",
+		},
+		{
+			name: "mismatched Content-Encoding header",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/").
+					WithHeader("Content-Encoding", "deflate").
+					WithBody("hello").
+					WithCompression("gzip")
+			},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "zlib: invalid header\n",
+		},
+		{
+			name: "unsupported encoding",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/").WithBody("hello").WithCompression("br")
+			},
+			wantFailures: []string{`request body: unsupported content encoding "br"`},
+		},
+		{
+			name: "streamed body",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/").WithBody(strings.NewReader("hello")).WithCompression("gzip")
+			},
+			wantFailures: []string{"request body: compression is not supported for streamed bodies"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			result := execute(t, test.tc(mt.NewHandlerContext(http.HandlerFunc(decompressingHandler))))
+			assert.Equal(t, test.wantFailures, failures(result))
+			if test.wantFailures == nil {
+				assert.Equal(t, test.wantStatus, result.Status)
+				assert.Equal(t, test.wantBody, string(result.Body))
+			}
+		})
+	}
+}
@@ -1,8 +1,11 @@
 package mt
 
 import (
+	"fmt"
 	"io"
 	"os"
+
+	mtjson "github.com/jefflinse/melatonin/json"
 )
 
 const (
@@ -12,11 +15,13 @@ const (
 )
 
 var cfg = struct {
+	CassetteMode      int
 	ContinueOnFailure bool
 	OutputType        int
 	Stdout            io.Writer
 	WorkingDir        string
 }{
+	CassetteMode:      -1,
 	ContinueOnFailure: false,
 	OutputType:        outputTypeFormattedTable,
 	Stdout:            os.Stdout,
@@ -24,6 +29,20 @@ var cfg = struct {
 }
 
 func init() {
+	switch mode := os.Getenv("MELATONIN_CASSETTE_MODE"); mode {
+	case "":
+	case "off":
+		cfg.CassetteMode = CassetteOff
+	case "record":
+		cfg.CassetteMode = CassetteRecord
+	case "replay":
+		cfg.CassetteMode = CassetteReplay
+	case "auto":
+		cfg.CassetteMode = CassetteAuto
+	default:
+		fmt.Fprintf(os.Stderr, "invalid MELATONIN_CASSETTE_MODE value %q in environment, using context settings\n", mode)
+	}
+
 	if os.Getenv("MELATONIN_CONTINUE_ON_FAILURE") != "" {
 		cfg.ContinueOnFailure = true
 	}
@@ -48,4 +67,6 @@ func init() {
 		}
 		cfg.WorkingDir = dir
 	}
+
+	mtjson.SetSchemaDir(cfg.WorkingDir)
 }
@@ -0,0 +1,246 @@
+package mt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A Decoder decodes response bodies of a particular content type.
+type Decoder interface {
+	// Decode decodes a response body into a value that can be compared
+	// against expectations.
+	Decode(body []byte) (any, error)
+}
+
+// A DecoderFunc is a function that implements the Decoder interface.
+type DecoderFunc func(body []byte) (any, error)
+
+// Decode calls f(body).
+func (f DecoderFunc) Decode(body []byte) (any, error) {
+	return f(body)
+}
+
+// NewDecoder creates a Decoder from an unmarshaling function, such as the
+// Unmarshal function of a MessagePack or CBOR library.
+func NewDecoder(unmarshal func(data []byte, v any) error) Decoder {
+	return DecoderFunc(func(body []byte) (any, error) {
+		var v any
+		err := unmarshal(body, &v)
+		return v, err
+	})
+}
+
+var (
+	// JSONDecoder decodes JSON response bodies, including top-level scalars.
+	JSONDecoder = NewDecoder(json.Unmarshal)
+
+	// NDJSONDecoder decodes newline-delimited JSON response bodies into a
+	// slice containing one element for each non-empty line.
+	NDJSONDecoder = DecoderFunc(decodeNDJSON)
+
+	// XMLDecoder decodes XML response bodies into a map with a single key,
+	// the name of the root element. Elements containing only text decode to
+	// strings, and other elements decode to maps of their child elements.
+	// Repeated child elements decode to slices. Attributes are decoded to
+	// keys prefixed with "@", and text alongside child elements to "#text".
+	XMLDecoder = DecoderFunc(decodeXML)
+
+	// YAMLDecoder decodes YAML response bodies.
+	YAMLDecoder = NewDecoder(yaml.Unmarshal)
+
+	// FormDecoder decodes application/x-www-form-urlencoded response bodies
+	// into a map of strings, or slices of strings for repeated keys.
+	FormDecoder = DecoderFunc(func(body []byte) (any, error) {
+		return decodeForm(body)
+	})
+)
+
+var (
+	decoders = map[string]Decoder{
+		"application/json":     JSONDecoder,
+		"application/x-ndjson": NDJSONDecoder,
+		"application/jsonl":    NDJSONDecoder,
+		"application/xml":      XMLDecoder,
+		"text/xml":             XMLDecoder,
+		"application/yaml":     YAMLDecoder,
+		"application/x-yaml":   YAMLDecoder,
+		"text/yaml":            YAMLDecoder,
+		formContentType:        FormDecoder,
+	}
+	decodersMu sync.RWMutex
+)
+
+// RegisterDecoder registers a decoder for a media type, such as
+// "application/msgpack", replacing any decoder previously registered for it.
+//
+// Response bodies are decoded using the decoder registered for the media type
+// of the response's Content-Type header before they are compared against
+// expectations. Numbers are converted to float64, maps to map[string]any,
+// and slices to []any, so decoders may return any such types.
+func RegisterDecoder(mediaType string, decoder Decoder) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	decoders[strings.ToLower(mediaType)] = decoder
+}
+
+// lookupDecoder finds the registered decoder for a Content-Type header value,
+// or returns nil if there is none.
+func lookupDecoder(contentType string) Decoder {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	return lookupMediaType(decoders, contentType)
+}
+
+// decodeBody decodes a response body according to its Content-Type. Bodies
+// with no registered decoder, including those sent without a Content-Type or
+// with one sniffed as text/plain, decode to a JSON object or array if
+// possible, or a string otherwise.
+func decodeBody(contentType string, body []byte) (any, error) {
+	if len(body) == 0 {
+		return nil, nil
+	}
+
+	decoder := lookupDecoder(contentType)
+	if decoder == nil {
+		return toInterface(body), nil
+	}
+
+	v, err := decoder.Decode(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s response body: %w", contentType, err)
+	}
+
+	return normalizeDecoded(v), nil
+}
+
+// normalizeDecoded converts a decoded value to the types produced by
+// decoding JSON, so that it can be compared against expectations.
+func normalizeDecoded(v any) any {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.Map:
+		m := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = normalizeDecoded(iter.Value().Interface())
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return v
+		}
+
+		s := make([]any, rv.Len())
+		for i := range s {
+			s[i] = normalizeDecoded(rv.Index(i).Interface())
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+func decodeNDJSON(body []byte) (any, error) {
+	values := []any{}
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(nil, len(body)+1)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var v any
+		if err := json.Unmarshal(scanner.Bytes(), &v); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		values = append(values, v)
+	}
+
+	return values, scanner.Err()
+}
+
+func decodeXML(body []byte) (any, error) {
+	d := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return nil, err
+		}
+
+		if start, ok := token.(xml.StartElement); ok {
+			v, err := decodeXMLElement(d, start)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{start.Name.Local: v}, nil
+		}
+	}
+}
+
+// decodeXMLElement decodes the contents of an element, up to and including
+// its end element.
+func decodeXMLElement(d *xml.Decoder, start xml.StartElement) (any, error) {
+	children := map[string]any{}
+	for _, attr := range start.Attr {
+		children["@"+attr.Name.Local] = attr.Value
+	}
+
+	text := &strings.Builder{}
+	hasChildren := false
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			hasChildren = true
+			child, err := decodeXMLElement(d, t)
+			if err != nil {
+				return nil, err
+			}
+
+			name := t.Name.Local
+			switch existing := children[name].(type) {
+			case nil:
+				children[name] = child
+			case []any:
+				children[name] = append(existing, child)
+			default:
+				children[name] = []any{existing, child}
+			}
+
+		case xml.CharData:
+			text.Write(t)
+
+		case xml.EndElement:
+			content := strings.TrimSpace(text.String())
+			if !hasChildren && len(start.Attr) == 0 {
+				return content, nil
+			}
+
+			if content != "" {
+				children["#text"] = content
+			}
+			return children, nil
+		}
+	}
+}
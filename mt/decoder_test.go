@@ -0,0 +1,127 @@
+package mt_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jefflinse/melatonin/mt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecoders(t *testing.T) {
+	mt.RegisterDecoder("Application/X-KV", mt.DecoderFunc(func(body []byte) (any, error) {
+		m := map[string]int{}
+		for _, line := range strings.Split(string(body), "\n") {
+			var k string
+			var v int
+			if _, err := fmt.Sscanf(line, "%s %d", &k, &v); err != nil {
+				return nil, err
+			}
+			m[k] = v
+		}
+		return m, nil
+	}))
+
+	for _, test := range []struct {
+		name         string
+		contentType  string
+		body         string
+		want         any
+		wantFailures []string
+	}{
+		{
+			name:        "JSON scalar",
+			contentType: "application/json",
+			body:        "42",
+			want:        42,
+		},
+		{
+			name:        "NDJSON",
+			contentType: "application/x-ndjson",
+			body:        "{\"id\":1}\n\n{\"id\":2}\n",
+			want:        []any{map[string]any{"id": 1}, map[string]any{"id": 2}},
+		},
+		{
+			name:        "XML",
+			contentType: "application/xml; charset=utf-8",
+			body:        `<user id="7"><name>Ada</name><tag>a</tag><tag>b</tag></user>`,
+			want: map[string]any{"user": map[string]any{
+				"@id":  "7",
+				"name": "Ada",
+				"tag":  []any{"a", "b"},
+			}},
+		},
+		{
+			name:        "XML text alongside child elements",
+			contentType: "text/xml",
+			body:        `<p>Hello, <b>Ada</b></p>`,
+			want:        map[string]any{"p": map[string]any{"#text": "Hello,", "b": "Ada"}},
+		},
+		{
+			name:        "YAML",
+			contentType: "application/yaml",
+			body:        "name: Ada\nscores:\n  - 1\n  - 2\n",
+			want:        map[string]any{"name": "Ada", "scores": []any{1, 2}},
+		},
+		{
+			name:        "form",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "name=Ada&tag=a&tag=b",
+			want:        map[string]any{"name": "Ada", "tag": []any{"a", "b"}},
+		},
+		{
+			name:        "registered decoder",
+			contentType: "application/x-kv",
+			body:        "a 1\nb 2",
+			want:        map[string]any{"a": 1, "b": 2},
+		},
+		{
+			name: "no Content-Type",
+			body: `{"name": "Ada"}`,
+			want: map[string]any{"name": "Ada"},
+		},
+		{
+			name:         "unexpected decoded value",
+			contentType:  "application/yaml",
+			body:         "name: Ada\n",
+			want:         map[string]any{"name": "Grace"},
+			wantFailures: []string{".name: expected Grace, got Ada"},
+		},
+		{
+			name:        "undecodable body compared as text",
+			contentType: "application/json",
+			body:        `{"name": "Ada"`,
+			want:        `{"name": "Ada"`,
+		},
+		{
+			name:         "undecodable body with structured expectation",
+			contentType:  "application/xml",
+			body:         "<user>",
+			want:         map[string]any{"user": "Ada"},
+			wantFailures: []string{": expected type map[string]interface {}, got string: <user>"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", test.contentType)
+				fmt.Fprint(w, test.body)
+			})
+
+			result := execute(t, mt.NewHandlerContext(handler).GET("/").ExpectBody(test.want))
+			assert.Equal(t, test.wantFailures, failures(result))
+		})
+	}
+}
+
+func TestNewDecoder(t *testing.T) {
+	decoder := mt.NewDecoder(func(data []byte, v any) error {
+		*v.(*any) = strings.Fields(string(data))
+		return nil
+	})
+
+	v, err := decoder.Decode([]byte("a b"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, v)
+}
@@ -0,0 +1,80 @@
+package mt_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	mtjson "github.com/jefflinse/melatonin/json"
+	"github.com/jefflinse/melatonin/mt"
+	"github.com/stretchr/testify/assert"
+)
+
+type order struct {
+	CustomerID *string `json:"customer_id"`
+	Total      any     `json:"total"`
+	Note       string  `json:"-"`
+}
+
+func TestDeferredBodies(t *testing.T) {
+	var customerID string
+	subtotal := 10.0
+
+	for _, test := range []struct {
+		name         string
+		body         any
+		wantBody     string
+		wantFailures []string
+	}{
+		{
+			name: "struct with deferred fields",
+			body: order{
+				CustomerID: &customerID,
+				Total:      mtjson.Defer(func() float64 { return subtotal * 1.2 }),
+				Note:       "not sent",
+			},
+			wantBody: `{"customer_id":"c1","total":12}`,
+		},
+		{
+			name:     "pointer to a struct",
+			body:     &order{CustomerID: &customerID, Total: 5},
+			wantBody: `{"customer_id":"c1","total":5}`,
+		},
+		{
+			name:     "typed map",
+			body:     map[string]mtjson.Deferred[int]{"n": mtjson.Defer(func() int { return 3 })},
+			wantBody: `{"n":3}`,
+		},
+		{
+			name:     "typed slice",
+			body:     []*string{&customerID},
+			wantBody: `["c1"]`,
+		},
+		{
+			name: "deferred value error",
+			body: order{
+				CustomerID: &customerID,
+				Total:      mtjson.Deferred[float64](func() (float64, error) { return 0, errors.New("no subtotal") }),
+			},
+			wantFailures: []string{"total: no subtotal"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(w, r.Body)
+			})
+
+			tc := mt.NewHandlerContext(handler).POST("/").WithBody(test.body)
+
+			// deferred values are resolved when the test case is executed
+			customerID = "c1"
+
+			result := execute(t, tc)
+			assert.Equal(t, test.wantFailures, failures(result))
+			if test.wantFailures == nil {
+				assert.JSONEq(t, test.wantBody, string(result.Body))
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package mt
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// An Encoder encodes request bodies for a particular content type.
+type Encoder interface {
+	// ContentType returns the Content-Type header value for encoded bodies.
+	ContentType() string
+
+	// Encode encodes a value. Any deferred values have already been resolved
+	// by the time Encode is called.
+	Encode(v any) ([]byte, error)
+}
+
+// NewEncoder creates an Encoder for the given content type from a marshaling
+// function, such as the Marshal function of a MessagePack or CBOR library.
+func NewEncoder(contentType string, marshal func(any) ([]byte, error)) Encoder {
+	return funcEncoder{contentType: contentType, marshal: marshal}
+}
+
+type funcEncoder struct {
+	contentType string
+	marshal     func(any) ([]byte, error)
+}
+
+func (e funcEncoder) ContentType() string          { return e.contentType }
+func (e funcEncoder) Encode(v any) ([]byte, error) { return e.marshal(v) }
+
+var (
+	// JSONEncoder encodes request bodies as JSON. It is used whenever no other
+	// encoder is selected.
+	JSONEncoder = NewEncoder("application/json", json.Marshal)
+
+	// XMLEncoder encodes request bodies as XML.
+	//
+	// Structs are encoded using encoding/xml. A map is encoded as an XML
+	// document if it has exactly one key, which names the root element;
+	// nested maps become child elements in sorted key order, and slices
+	// repeat their element once per item.
+	XMLEncoder = NewEncoder("application/xml", encodeXML)
+
+	// YAMLEncoder encodes request bodies as YAML.
+	YAMLEncoder = NewEncoder("application/yaml", yaml.Marshal)
+)
+
+var (
+	encoders = map[string]Encoder{
+		"application/json":   JSONEncoder,
+		"application/xml":    XMLEncoder,
+		"text/xml":           XMLEncoder,
+		"application/yaml":   YAMLEncoder,
+		"application/x-yaml": YAMLEncoder,
+		"text/yaml":          YAMLEncoder,
+	}
+	encodersMu sync.RWMutex
+)
+
+// RegisterEncoder registers an encoder for a media type, such as
+// "application/msgpack", replacing any encoder previously registered for it.
+//
+// When a test case's body isn't raw bytes or a string, the encoder registered
+// for the media type of the request's Content-Type header is used to encode
+// it, unless the test case sets an encoder explicitly using WithEncoder.
+func RegisterEncoder(mediaType string, encoder Encoder) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+	encoders[strings.ToLower(mediaType)] = encoder
+}
+
+// lookupEncoder finds the registered encoder for a Content-Type header value,
+// or returns nil if there is none.
+func lookupEncoder(contentType string) Encoder {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+	return lookupMediaType(encoders, contentType)
+}
+
+// lookupMediaType finds the registry entry for a Content-Type header value.
+// Media types with a structured syntax suffix, such as
+// "application/vnd.api+json", fall back to the entry for the suffix.
+func lookupMediaType[T any](registry map[string]T, contentType string) T {
+	var zero T
+	if contentType == "" {
+		return zero
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return zero
+	}
+
+	if entry, ok := registry[mediaType]; ok {
+		return entry
+	}
+
+	if i := strings.LastIndex(mediaType, "+"); i >= 0 {
+		return registry["application/"+mediaType[i+1:]]
+	}
+
+	return zero
+}
+
+// encodeBody converts a resolved request body to bytes, using the encoder to
+// encode any value that isn't already a raw or self-encoding body. A nil
+// encoder encodes values as JSON.
+func encodeBody(body any, encoder Encoder) ([]byte, error) {
+	switch body.(type) {
+	case nil, []byte, string, func() []byte, func() ([]byte, error), FormBody, *MultipartBody, bodyTemplate:
+		return toBytes(body)
+	}
+
+	if encoder == nil {
+		encoder = JSONEncoder
+	}
+
+	b, err := encoder.Encode(body)
+	if err != nil {
+		return nil, fmt.Errorf("request body: %w", err)
+	}
+
+	return b, nil
+}
+
+func encodeXML(v any) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map {
+		return xml.Marshal(v)
+	}
+
+	if rv.Type().Key().Kind() != reflect.String || rv.Len() != 1 {
+		return nil, errors.New("XML body must be a struct or a map with a single root element")
+	}
+
+	buf := &bytes.Buffer{}
+	enc := xml.NewEncoder(buf)
+	iter := rv.MapRange()
+	iter.Next()
+	if err := encodeXMLElement(enc, iter.Key().String(), iter.Value()); err != nil {
+		return nil, err
+	}
+
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func encodeXMLElement(enc *xml.Encoder, name string, rv reflect.Value) error {
+	for rv.Kind() == reflect.Interface || rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			break
+		}
+		rv = rv.Elem()
+	}
+
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	switch rv.Kind() {
+	case reflect.Invalid, reflect.Interface, reflect.Pointer:
+		// nil values become empty elements
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		return enc.EncodeToken(start.End())
+
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("XML element %q: map keys must be strings", name)
+		}
+
+		keys := make([]string, 0, rv.Len())
+		for _, key := range rv.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, key := range keys {
+			value := rv.MapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()))
+			if err := encodeXMLElement(enc, key, value); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return enc.EncodeElement(rv.Interface(), start)
+		}
+
+		for i := 0; i < rv.Len(); i++ {
+			if err := encodeXMLElement(enc, name, rv.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	default:
+		return enc.EncodeElement(rv.Interface(), start)
+	}
+}
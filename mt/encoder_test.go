@@ -0,0 +1,140 @@
+package mt_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/jefflinse/melatonin/mt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncoders(t *testing.T) {
+	mt.RegisterEncoder("application/x-test", mt.NewEncoder("application/x-test", func(v any) ([]byte, error) {
+		return []byte(fmt.Sprintf("test:%v", v)), nil
+	}))
+
+	failing := mt.NewEncoder("application/x-failing", func(any) ([]byte, error) {
+		return nil, errors.New("can't encode")
+	})
+
+	type user struct {
+		Name string `json:"name" xml:"name" yaml:"name"`
+	}
+
+	type item struct {
+		Name   *string `json:"name" xml:"name" yaml:"item_name"`
+		Count  *int    `json:"count,omitempty" xml:"count,omitempty" yaml:"count,omitempty"`
+		Active *bool   `json:"active,omitempty" xml:"active,omitempty" yaml:"active,omitempty"`
+	}
+	name, zero, inactive := "pen", 0, false
+
+	for _, test := range []struct {
+		name         string
+		tc           func(*mt.HTTPTestContext) *mt.HTTPTestCase
+		wantBody     string
+		wantFailures []string
+	}{
+		{
+			name: "JSON by default",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/").WithBody(map[string]any{"name": "Ada"})
+			},
+			wantBody: `|{"name":"Ada"}`,
+		},
+		{
+			name: "JSON for a structured syntax suffix",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/").WithHeader("Content-Type", "application/vnd.api+json").WithBody(user{Name: "Ada"})
+			},
+			wantBody: `application/vnd.api+json|{"name":"Ada"}`,
+		},
+		{
+			name: "XML map selected by Content-Type",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/").
+					WithHeader("Content-Type", "application/xml; charset=utf-8").
+					WithBody(map[string]any{"user": map[string]any{"name": "Ada", "tags": []any{"a", "b"}}})
+			},
+			wantBody: `application/xml; charset=utf-8|<user><name>Ada</name><tags>a</tags><tags>b</tags></user>`,
+		},
+		{
+			name: "XML struct",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/").WithEncoder(mt.XMLEncoder).WithBody(user{Name: "Ada"})
+			},
+			wantBody: `application/xml|<user><name>Ada</name></user>`,
+		},
+		{
+			name: "JSON struct with pointer fields",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/").WithBody(item{Name: &name, Count: &zero, Active: &inactive})
+			},
+			wantBody: `|{"name":"pen","count":0,"active":false}`,
+		},
+		{
+			name: "XML struct with pointer fields",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/").WithHeader("Content-Type", "application/xml").WithBody(item{Name: &name, Count: &zero})
+			},
+			wantBody: `application/xml|<item><name>pen</name><count>0</count></item>`,
+		},
+		{
+			name: "YAML struct with pointer fields",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/").WithEncoder(mt.YAMLEncoder).WithBody(item{Name: &name, Active: &inactive})
+			},
+			wantBody: "application/yaml|item_name: pen\nactive: false\n",
+		},
+		{
+			name: "XML map without a single root element",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/").WithEncoder(mt.XMLEncoder).WithBody(map[string]any{"a": 1, "b": 2})
+			},
+			wantFailures: []string{"request body: XML body must be a struct or a map with a single root element"},
+		},
+		{
+			name: "YAML set explicitly",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/").WithEncoder(mt.YAMLEncoder).WithBody(map[string]any{"name": "Ada"})
+			},
+			wantBody: "application/yaml|name: Ada\n",
+		},
+		{
+			name: "registered encoder",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/").WithHeader("Content-Type", "application/x-test").WithBody(42)
+			},
+			wantBody: "application/x-test|test:42",
+		},
+		{
+			name: "raw bodies aren't encoded",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/").WithEncoder(mt.YAMLEncoder).WithBody("raw")
+			},
+			wantBody: "application/yaml|raw",
+		},
+		{
+			name: "encoder error",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/").WithEncoder(failing).WithBody(map[string]any{"name": "Ada"})
+			},
+			wantFailures: []string{"request body: can't encode"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				fmt.Fprintf(w, "%s|%s", r.Header.Get("Content-Type"), body)
+			})
+
+			result := execute(t, test.tc(mt.NewHandlerContext(handler)))
+			assert.Equal(t, test.wantFailures, failures(result))
+			if test.wantFailures == nil {
+				assert.Equal(t, test.wantBody, string(result.Body))
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package mt_test
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/jefflinse/melatonin/expect"
+	mtjson "github.com/jefflinse/melatonin/json"
+	"github.com/jefflinse/melatonin/mt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeValuesInBodies(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.Copy(w, r.Body)
+	})
+
+	newTestCase := func() *mt.HTTPTestCase {
+		return mt.NewHandlerContext(handler).POST("/").WithBody(map[string]any{
+			"id": mtjson.UUIDv4(),
+			"n":  mtjson.Sequence(1),
+		})
+	}
+
+	// generators produce new values each time the test case is executed
+	tc := newTestCase().ExpectBody(map[string]any{
+		"id": expect.Pattern(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`),
+		"n":  1,
+	})
+	first := execute(t, tc)
+	assert.Nil(t, failures(first))
+
+	second := execute(t, tc)
+	assert.Equal(t, []string{".n: expected 1, got 2"}, failures(second))
+	assert.NotEqual(t, string(first.Body), string(second.Body))
+
+	// and the same values for the same seed
+	previous := mtjson.Seed()
+	t.Cleanup(func() { mtjson.SetSeed(previous) })
+
+	mtjson.SetSeed(7)
+	a := execute(t, newTestCase())
+	mtjson.SetSeed(7)
+	b := execute(t, newTestCase())
+	assert.Equal(t, string(a.Body), string(b.Body))
+}
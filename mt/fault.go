@@ -0,0 +1,306 @@
+package mt
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	mtjson "github.com/jefflinse/melatonin/json"
+)
+
+const (
+	faultKindLatency     = "latency"
+	faultKindError       = "error"
+	faultKindReset       = "reset"
+	faultKindPartialBody = "partial-body"
+	faultKindStatus      = "status"
+)
+
+// A Fault describes a failure to inject into HTTP exchanges made through a
+// FaultTransport.
+//
+// Create faults using FaultLatency, FaultError, FaultReset, FaultPartialBody,
+// or FaultStatus.
+type Fault struct {
+	name        string
+	kind        string
+	latency     time.Duration
+	err         error
+	bodyBytes   int
+	status      int
+	match       func(*http.Request) bool
+	probability float64
+}
+
+// FaultLatency creates a fault that delays a request by the given duration before
+// it is sent.
+func FaultLatency(d time.Duration) *Fault {
+	return &Fault{kind: faultKindLatency, latency: d, probability: 1}
+}
+
+// FaultError creates a fault that fails a request with the given error instead of
+// sending it.
+func FaultError(err error) *Fault {
+	return &Fault{kind: faultKindError, err: err, probability: 1}
+}
+
+// FaultReset creates a fault that fails a request as if the connection had been
+// reset by the server.
+func FaultReset() *Fault {
+	return &Fault{kind: faultKindReset, err: syscall.ECONNRESET, probability: 1}
+}
+
+// FaultPartialBody creates a fault that truncates a response body after n bytes,
+// after which reading the body fails with io.ErrUnexpectedEOF.
+func FaultPartialBody(n int) *Fault {
+	return &Fault{kind: faultKindPartialBody, bodyBytes: n, probability: 1}
+}
+
+// FaultStatus creates a fault that overrides the status code of a response.
+func FaultStatus(status int) *Fault {
+	return &Fault{kind: faultKindStatus, status: status, probability: 1}
+}
+
+// Named sets a name used to identify the fault in test results and returns
+// the fault.
+func (f *Fault) Named(name string) *Fault {
+	f.name = name
+	return f
+}
+
+// When restricts the fault to requests matching a predicate and returns the
+// fault.
+func (f *Fault) When(match func(*http.Request) bool) *Fault {
+	f.match = match
+	return f
+}
+
+// WhenPath restricts the fault to requests whose URL path has the given
+// prefix and returns the fault.
+func (f *Fault) WhenPath(prefix string) *Fault {
+	return f.When(func(req *http.Request) bool {
+		return strings.HasPrefix(req.URL.Path, prefix)
+	})
+}
+
+// WithProbability sets the probability, between 0 and 1, that the fault is
+// injected into a matching request and returns the fault.
+//
+// Default is 1.
+func (f *Fault) WithProbability(p float64) *Fault {
+	f.probability = p
+	return f
+}
+
+// An InjectedFault records a fault injected into a single HTTP exchange.
+type InjectedFault struct {
+	Name   string `json:"name"`
+	Kind   string `json:"kind"`
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Seed   int64  `json:"seed"`
+}
+
+func (f InjectedFault) String() string {
+	if f.Name == "" {
+		return fmt.Sprintf("%s fault on %s %s (seed %d)", f.Kind, f.Method, f.URL, f.Seed)
+	}
+
+	return fmt.Sprintf("%s fault %q on %s %s (seed %d)", f.Kind, f.Name, f.Method, f.URL, f.Seed)
+}
+
+// A FaultTransport is an http.RoundTripper that injects faults into the HTTP
+// exchanges passing through it.
+//
+// A FaultTransport can be installed on an HTTPTestContext using WithFaults,
+// or on any other http.Client, such as the outbound client of a handler under
+// test. Faults injected by a transport registered with a context are reported
+// in the results of the test cases during which they were injected.
+type FaultTransport struct {
+	// Transport is the underlying round tripper used to send requests.
+	// If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	faults   []*Fault
+	seed     int64
+	rng      *rand.Rand
+	injected []InjectedFault
+	mu       sync.Mutex
+}
+
+// NewFaultTransport creates a new FaultTransport.
+//
+// The random number generator used to decide whether to inject probabilistic
+// faults is seeded from the MELATONIN_SEED environment variable if set, so
+// that a failing run can be reproduced. Otherwise, a random seed is used. The
+// seed is reported with every injected fault.
+func NewFaultTransport(faults ...*Fault) *FaultTransport {
+	return (&FaultTransport{faults: faults}).WithSeed(mtjson.Seed())
+}
+
+// Inject adds one or more faults to the transport and returns the transport.
+func (t *FaultTransport) Inject(faults ...*Fault) *FaultTransport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.faults = append(t.faults, faults...)
+	return t
+}
+
+// Seed returns the seed used by the transport's random number generator.
+func (t *FaultTransport) Seed() int64 {
+	return t.seed
+}
+
+// WithSeed reseeds the transport's random number generator and returns the
+// transport.
+func (t *FaultTransport) WithSeed(seed int64) *FaultTransport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.seed = seed
+	t.rng = rand.New(rand.NewSource(seed))
+	return t
+}
+
+// Injected returns all faults injected by the transport so far.
+func (t *FaultTransport) Injected() []InjectedFault {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]InjectedFault(nil), t.injected...)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *FaultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.roundTrip(req, t.Transport)
+}
+
+// roundTrip sends a request using the given round tripper, injecting any
+// faults that apply to it.
+func (t *FaultTransport) roundTrip(req *http.Request, base http.RoundTripper) (*http.Response, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	faults := t.selectFaults(req)
+	for _, f := range faults {
+		switch f.kind {
+		case faultKindLatency:
+			timer := time.NewTimer(f.latency)
+			select {
+			case <-timer.C:
+			case <-req.Context().Done():
+				timer.Stop()
+				return nil, req.Context().Err()
+			}
+		case faultKindError, faultKindReset:
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, fmt.Errorf("injected fault: %w", f.err)
+		}
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range faults {
+		switch f.kind {
+		case faultKindStatus:
+			resp.StatusCode = f.status
+			resp.Status = fmt.Sprintf("%d %s", f.status, http.StatusText(f.status))
+		case faultKindPartialBody:
+			resp.Body = &partialBody{body: resp.Body, remaining: f.bodyBytes}
+			resp.ContentLength = -1
+		}
+	}
+
+	return resp, nil
+}
+
+// selectFaults determines which faults to inject into a request and records
+// them as injected.
+func (t *FaultTransport) selectFaults(req *http.Request) []*Fault {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var selected []*Fault
+	for _, f := range t.faults {
+		if f.match != nil && !f.match(req) {
+			continue
+		}
+
+		if f.probability < 1 && t.rng.Float64() >= f.probability {
+			continue
+		}
+
+		selected = append(selected, f)
+		t.injected = append(t.injected, InjectedFault{
+			Name:   f.name,
+			Kind:   f.kind,
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Seed:   t.seed,
+		})
+	}
+
+	return selected
+}
+
+// injectedCount returns the number of faults injected by the transport so far.
+func (t *FaultTransport) injectedCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.injected)
+}
+
+// injectedSince returns the faults injected after the first n.
+func (t *FaultTransport) injectedSince(n int) []InjectedFault {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if n >= len(t.injected) {
+		return nil
+	}
+
+	return append([]InjectedFault(nil), t.injected[n:]...)
+}
+
+// faultRoundTripper sends requests through a FaultTransport using a specific
+// underlying round tripper.
+type faultRoundTripper struct {
+	faults *FaultTransport
+	base   http.RoundTripper
+}
+
+func (rt *faultRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return rt.faults.roundTrip(req, rt.base)
+}
+
+// partialBody is a response body that fails after a number of bytes are read.
+type partialBody struct {
+	body      io.ReadCloser
+	remaining int
+}
+
+func (b *partialBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	if len(p) > b.remaining {
+		p = p[:b.remaining]
+	}
+
+	n, err := b.body.Read(p)
+	b.remaining -= n
+	return n, err
+}
+
+func (b *partialBody) Close() error {
+	return b.body.Close()
+}
@@ -0,0 +1,163 @@
+package mt_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jefflinse/melatonin/mt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFaults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello world")
+	}))
+	t.Cleanup(server.Close)
+
+	for _, test := range []struct {
+		name         string
+		fault        *mt.Fault
+		tc           func(*mt.HTTPTestContext) *mt.HTTPTestCase
+		wantKinds    []string
+		wantFailures []string
+	}{
+		{
+			name:      "latency",
+			fault:     mt.FaultLatency(10 * time.Millisecond),
+			wantKinds: []string{"latency"},
+		},
+		{
+			name:         "error",
+			fault:        mt.FaultError(errors.New("boom")),
+			wantKinds:    []string{"error"},
+			wantFailures: []string{fmt.Sprintf(`failed to execute HTTP request: Get "%s/": injected fault: boom`, server.URL)},
+		},
+		{
+			name:         "reset",
+			fault:        mt.FaultReset(),
+			wantKinds:    []string{"reset"},
+			wantFailures: []string{fmt.Sprintf(`failed to execute HTTP request: Get "%s/": injected fault: connection reset by peer`, server.URL)},
+		},
+		{
+			name:         "partial body",
+			fault:        mt.FaultPartialBody(5),
+			wantKinds:    []string{"partial-body"},
+			wantFailures: []string{"failed to execute HTTP request: unexpected EOF"},
+		},
+		{
+			name:  "status",
+			fault: mt.FaultStatus(http.StatusServiceUnavailable),
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/").ExpectStatus(http.StatusOK)
+			},
+			wantKinds:    []string{"status"},
+			wantFailures: []string{"expected status 200 OK, got 503 Service Unavailable"},
+		},
+		{
+			name:  "matching path",
+			fault: mt.FaultStatus(http.StatusServiceUnavailable).WhenPath("/orders"),
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/orders/1").ExpectStatus(http.StatusServiceUnavailable)
+			},
+			wantKinds: []string{"status"},
+		},
+		{
+			name:  "path not matching",
+			fault: mt.FaultStatus(http.StatusServiceUnavailable).WhenPath("/orders"),
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/users/1").ExpectStatus(http.StatusOK)
+			},
+		},
+		{
+			name:  "zero probability",
+			fault: mt.FaultStatus(http.StatusServiceUnavailable).WithProbability(0),
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/").ExpectStatus(http.StatusOK)
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := mt.NewURLContext(server.URL).WithFaults(mt.NewFaultTransport(test.fault))
+
+			tc := ctx.GET("/")
+			if test.tc != nil {
+				tc = test.tc(ctx)
+			}
+
+			result := execute(t, tc)
+			assert.Equal(t, test.wantFailures, failures(result))
+
+			var kinds []string
+			for _, f := range result.Faults {
+				kinds = append(kinds, f.Kind)
+			}
+			assert.Equal(t, test.wantKinds, kinds)
+		})
+	}
+}
+
+func TestFaultProbabilityIsReproducible(t *testing.T) {
+	injected := func(seed int64) []bool {
+		faults := mt.NewFaultTransport(mt.FaultStatus(http.StatusServiceUnavailable).WithProbability(0.5)).
+			WithSeed(seed)
+		faults.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+		})
+
+		var statuses []bool
+		for i := 0; i < 20; i++ {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			resp, err := faults.RoundTrip(req)
+			if !assert.NoError(t, err) {
+				return nil
+			}
+			statuses = append(statuses, resp.StatusCode == http.StatusServiceUnavailable)
+		}
+
+		assert.Equal(t, seed, faults.Seed())
+		return statuses
+	}
+
+	first := injected(7)
+	assert.Equal(t, first, injected(7))
+	assert.Contains(t, first, true)
+	assert.Contains(t, first, false)
+}
+
+func TestTrackFaults(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "upstream")
+	}))
+	t.Cleanup(upstream.Close)
+
+	faults := mt.NewFaultTransport(mt.FaultStatus(http.StatusBadGateway).Named("upstream down")).WithSeed(7)
+	outbound := &http.Client{Transport: faults}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := outbound.Get(upstream.URL + "/status")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer resp.Body.Close()
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	})
+
+	ctx := mt.NewHandlerContext(handler).TrackFaults(faults)
+	result := execute(t, ctx.GET("/").ExpectStatus(http.StatusOK))
+	assert.Equal(t, []string{"expected status 200 OK, got 502 Bad Gateway"}, failures(result))
+	if assert.Len(t, result.Faults, 1) {
+		assert.Equal(t, fmt.Sprintf(`status fault "upstream down" on GET %s/status (seed 7)`, upstream.URL), result.Faults[0].String())
+	}
+
+	// only faults injected while a test case runs are reported in its result
+	result = execute(t, ctx.GET("/").ExpectStatus(http.StatusBadGateway))
+	assert.Nil(t, failures(result))
+	assert.Len(t, result.Faults, 1)
+	assert.Len(t, faults.Injected(), 2)
+}
@@ -0,0 +1,116 @@
+package mt
+
+import (
+	"fmt"
+	"net/url"
+
+	mtjson "github.com/jefflinse/melatonin/json"
+)
+
+const formContentType = "application/x-www-form-urlencoded"
+
+// A FormBody is an application/x-www-form-urlencoded request body.
+//
+// Values may be scalars, deferred values, or slices of either, in which case
+// the key is repeated once for each element. Pass a FormBody to WithBody to
+// send it. The request's Content-Type header is set automatically unless the
+// test case sets it explicitly.
+type FormBody map[string]any
+
+// ContentType returns the Content-Type header value for the body.
+func (f FormBody) ContentType() string {
+	return formContentType
+}
+
+// Values resolves any deferred values in the body and returns the result as
+// url.Values.
+func (f FormBody) Values() (url.Values, error) {
+	resolved, err := mtjson.ResolveDeferred(map[string]any(f))
+	if err != nil {
+		return nil, err
+	}
+
+	values := url.Values{}
+	for k, v := range resolved.(map[string]any) {
+		switch value := v.(type) {
+		case []string:
+			values[k] = append(values[k], value...)
+		case []any:
+			for _, element := range value {
+				str, err := paramString(element)
+				if err != nil {
+					return nil, fmt.Errorf("form value %q: %w", k, err)
+				}
+				values.Add(k, str)
+			}
+		default:
+			str, err := paramString(value)
+			if err != nil {
+				return nil, fmt.Errorf("form value %q: %w", k, err)
+			}
+			values.Add(k, str)
+		}
+	}
+
+	return values, nil
+}
+
+// encode resolves any deferred values in the body and encodes it. Keys are
+// encoded in sorted order.
+func (f FormBody) encode() ([]byte, error) {
+	values, err := f.Values()
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(values.Encode()), nil
+}
+
+// toFormExpectation converts an expected form body into a value comparable
+// against a decoded form body.
+func toFormExpectation(body any) any {
+	switch value := body.(type) {
+	case FormBody:
+		m := make(map[string]any, len(value))
+		for k, v := range value {
+			if strs, ok := v.([]string); ok {
+				v = formValuesToMap(url.Values{k: strs})[k]
+			}
+			m[k] = v
+		}
+		return m
+	case url.Values:
+		return formValuesToMap(value)
+	default:
+		return body
+	}
+}
+
+// decodeForm decodes an application/x-www-form-urlencoded body into a map of
+// strings, or slices of strings for repeated keys.
+func decodeForm(body []byte) (map[string]any, error) {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+
+	return formValuesToMap(values), nil
+}
+
+func formValuesToMap(values url.Values) map[string]any {
+	m := make(map[string]any, len(values))
+	for k, v := range values {
+		if len(v) == 1 {
+			m[k] = v[0]
+			continue
+		}
+
+		elements := make([]any, len(v))
+		for i := range v {
+			elements[i] = v[i]
+		}
+		m[k] = elements
+	}
+
+	return m
+}
@@ -0,0 +1,127 @@
+package mt_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	mtjson "github.com/jefflinse/melatonin/json"
+	"github.com/jefflinse/melatonin/mt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormBodyValues(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		body       mt.FormBody
+		wantValues url.Values
+		wantError  string
+	}{
+		{
+			name:       "scalars",
+			body:       mt.FormBody{"name": "Ada", "age": 36, "admin": true},
+			wantValues: url.Values{"name": {"Ada"}, "age": {"36"}, "admin": {"true"}},
+		},
+		{
+			name:       "repeated keys",
+			body:       mt.FormBody{"tag": []string{"a", "b"}, "id": []any{1, 2}},
+			wantValues: url.Values{"tag": {"a", "b"}, "id": {"1", "2"}},
+		},
+		{
+			name:       "deferred values",
+			body:       mt.FormBody{"token": mtjson.Defer(func() string { return "abc" })},
+			wantValues: url.Values{"token": {"abc"}},
+		},
+		{
+			name:      "deferred value error",
+			body:      mt.FormBody{"token": func() (any, error) { return nil, errors.New("no token") }},
+			wantError: "token: no token",
+		},
+		{
+			name:      "unsupported value",
+			body:      mt.FormBody{"user": map[string]any{"name": "Ada"}},
+			wantError: `form value "user": unsupported parameter type: map[string]interface {}`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			values, err := test.body.Values()
+			if test.wantError != "" {
+				if assert.Error(t, err) {
+					assert.Equal(t, test.wantError, err.Error())
+				}
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.wantValues, values)
+		})
+	}
+}
+
+func TestFormBodyRequest(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		fmt.Fprintf(w, "%s %s", r.Header.Get("Content-Type"), r.PostForm.Encode())
+	})
+
+	result := execute(t, mt.NewHandlerContext(handler).POST("/").
+		WithBody(mt.FormBody{"b": []string{"x", "y"}, "a": 1}).
+		ExpectStatus(http.StatusOK).
+		ExpectBody("application/x-www-form-urlencoded a=1&b=x&b=y"))
+	assert.Nil(t, failures(result))
+}
+
+func TestExpectFormBody(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		contentType  string
+		tc           func(*mt.HTTPTestContext) *mt.HTTPTestCase
+		wantFailures []string
+	}{
+		{
+			name:        "form body",
+			contentType: "text/plain",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/").ExpectFormBody(mt.FormBody{"name": "Ada", "tag": []string{"a", "b"}})
+			},
+		},
+		{
+			name:        "url values",
+			contentType: "text/plain",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/").ExpectFormBody(url.Values{"name": {"Ada"}})
+			},
+		},
+		{
+			name:        "decoded by content type",
+			contentType: "application/x-www-form-urlencoded",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/").ExpectBody(map[string]any{"name": "Ada", "tag": []any{"a", "b"}})
+			},
+		},
+		{
+			name:        "unexpected value",
+			contentType: "text/plain",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/").ExpectFormBody(mt.FormBody{"name": "Grace"})
+			},
+			wantFailures: []string{".name: expected Grace, got Ada"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", test.contentType)
+				fmt.Fprint(w, "name=Ada&tag=a&tag=b")
+			})
+
+			result := execute(t, test.tc(mt.NewHandlerContext(handler)))
+			assert.Equal(t, test.wantFailures, failures(result))
+		})
+	}
+}
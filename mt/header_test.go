@@ -0,0 +1,193 @@
+package mt_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/jefflinse/melatonin/expect"
+	"github.com/jefflinse/melatonin/mt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderExpectations(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("X-Id", "7")
+		w.Header().Add("Vary", "Accept-Encoding")
+		w.Header().Add("Vary", "Origin, Accept")
+		w.Header().Set("Cache-Control", "no-cache, no-store")
+		fmt.Fprint(w, "ok")
+	})
+
+	all := http.Header{
+		"Content-Type":  {"text/plain"},
+		"X-Id":          {"7"},
+		"Vary":          {"Origin, Accept", "Accept-Encoding"},
+		"Cache-Control": {"no-cache, no-store"},
+	}
+
+	for _, test := range []struct {
+		name         string
+		tc           func(*mt.HTTPTestContext) *mt.HTTPTestCase
+		wantFailures []string
+	}{
+		{
+			name: "expected header",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/").ExpectHeader("x-id", "7").ExpectHeader("Vary", []string{"Accept-Encoding"})
+			},
+		},
+		{
+			name: "formatted value",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/").ExpectHeader("X-Id", 7)
+			},
+		},
+		{
+			name: "missing header",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/").ExpectHeader("X-Missing", "a")
+			},
+			wantFailures: []string{`expected header "X-Missing", got nothing`},
+		},
+		{
+			name: "unexpected value",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/").ExpectHeader("X-Id", "8")
+			},
+			wantFailures: []string{`expected header "X-Id" to contain "8", got ["7"]`},
+		},
+		{
+			name: "exact headers",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/").ExpectExactHeaders(all)
+			},
+		},
+		{
+			name: "unexpected headers",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/").ExpectExactHeaders(http.Header{"X-Id": {"7"}})
+			},
+			wantFailures: []string{
+				`unexpected header "Cache-Control": ["no-cache, no-store"]`,
+				`unexpected header "Content-Type": ["text/plain"]`,
+				`unexpected header "Vary": ["Accept-Encoding" "Origin, Accept"]`,
+			},
+		},
+		{
+			name: "unexpected header value",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/").ExpectExactHeaders(http.Header{
+					"Content-Type":  {"text/plain"},
+					"X-Id":          {"7"},
+					"Vary":          {"Accept-Encoding"},
+					"Cache-Control": {"no-cache, no-store"},
+				})
+			},
+			wantFailures: []string{`unexpected value "Origin, Accept" for header "Vary"`},
+		},
+		{
+			name: "predicates satisfy exact headers",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/").
+					ExpectExactHeaders(http.Header{"Content-Type": {"text/plain"}, "X-Id": {"7"}}).
+					ExpectHeader("vary", expect.Pattern("Origin")).
+					ExpectHeaderContains("cache-control", " NO-STORE ")
+			},
+		},
+		{
+			name: "failed predicate",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/").ExpectHeader("X-Id", expect.Pattern(`^\d{2}$`))
+			},
+			wantFailures: []string{`header "X-Id": expected to match pattern "^\\d{2}$", got "7"`},
+		},
+		{
+			name: "failed predicate function",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/").ExpectHeader("X-Id", func(any) error { return errors.New("nope") })
+			},
+			wantFailures: []string{`header "X-Id": nope`},
+		},
+		{
+			name: "predicate for a missing header",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/").ExpectHeader("X-Missing", expect.String())
+			},
+			wantFailures: []string{`expected header "X-Missing", got nothing`},
+		},
+		{
+			name: "list contains elements across lines",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/").ExpectHeaderContains("Vary", "origin", "accept-encoding")
+			},
+		},
+		{
+			name: "list missing elements",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/").ExpectHeaderContains("Vary", "Cookie", "Origin", "Authorization")
+			},
+			wantFailures: []string{`header "Vary": expected list to contain ["Cookie" "Authorization"], got "Accept-Encoding, Origin, Accept"`},
+		},
+		{
+			name: "absent header",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/").ExpectNoHeader("X-Missing")
+			},
+		},
+		{
+			name: "unexpectedly present header",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/").ExpectNoHeader("x-id")
+			},
+			wantFailures: []string{`expected no header "X-Id", got ["7"]`},
+		},
+		{
+			name: "custom ignored headers",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.WithIgnoredHeaders("x-id", "Vary", "Cache-Control").GET("/").ExpectExactHeaders(http.Header{})
+			},
+			wantFailures: []string{`unexpected header "Content-Type": ["text/plain"]`},
+		},
+		{
+			name: "no ignored headers",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.WithIgnoredHeaders().GET("/").ExpectExactHeaders(all)
+			},
+		},
+		{
+			name: "wrong Content-Type",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				header := all.Clone()
+				header.Set("Content-Type", "application/json")
+				return ctx.GET("/").ExpectExactHeaders(header)
+			},
+			wantFailures: []string{
+				`expected header "Content-Type" to contain "application/json", got ["text/plain"]`,
+				`unexpected value "text/plain" for header "Content-Type"`,
+			},
+		},
+		{
+			name: "ignored headers that are expected",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.WithIgnoredHeaders("X-Id").GET("/").ExpectExactHeaders(http.Header{
+					"Content-Type":  {"text/plain"},
+					"X-Id":          {"8"},
+					"Vary":          {"Origin, Accept", "Accept-Encoding"},
+					"Cache-Control": {"no-cache, no-store"},
+				})
+			},
+			wantFailures: []string{
+				`expected header "X-Id" to contain "8", got ["7"]`,
+				`unexpected value "7" for header "X-Id"`,
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			result := execute(t, test.tc(mt.NewHandlerContext(handler)))
+			assert.Equal(t, test.wantFailures, failures(result))
+		})
+	}
+}
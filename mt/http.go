@@ -2,6 +2,7 @@ package mt
 
 import (
 	"context"
+	"encoding"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -83,27 +84,103 @@ func createRequest(method, path string) (*http.Request, context.CancelFunc, erro
 	return req, cancel, nil
 }
 
-func doRequest(c *http.Client, req *http.Request) (int, http.Header, []byte, error) {
+func doRequest(c *http.Client, req *http.Request) (*http.Response, []byte, error) {
 	resp, err := c.Do(req)
 	if err != nil {
-		return -1, nil, nil, err
+		return nil, nil, err
 	}
 
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return -1, nil, nil, err
+		return nil, nil, err
 	}
 
-	return resp.StatusCode, resp.Header, body, nil
+	return resp, body, nil
 }
 
-func handleRequest(h http.Handler, req *http.Request) (int, http.Header, []byte, error) {
-	w := httptest.NewRecorder()
-	h.ServeHTTP(w, req)
-	resp := w.Result()
-	b, err := ioutil.ReadAll(resp.Body)
-	return resp.StatusCode, resp.Header, b, err
+// handleRequest serves a request using a handler directly, following any
+// redirects permitted by the checkRedirect policy in the same manner as
+// http.Client.
+func handleRequest(h http.Handler, req *http.Request, checkRedirect func(*http.Request, []*http.Request) error) (*http.Response, []byte, error) {
+	var via []*http.Request
+	for {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		resp := w.Result()
+		resp.Request = req
+		b, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		next, err := redirectRequest(req, resp)
+		if err != nil {
+			return nil, nil, err
+		} else if next == nil {
+			return resp, b, nil
+		}
+
+		via = append(via, req)
+		if err := checkRedirect(next, via); err == http.ErrUseLastResponse {
+			return resp, b, nil
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		req = next
+	}
+}
+
+// prepareServerRequest points a request at the context's test server and
+// returns a client configured to send it.
+func prepareServerRequest(c *HTTPTestContext, req *http.Request) (*http.Client, error) {
+	server, client, err := c.testServer()
+	if err != nil {
+		return nil, err
+	}
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	req.URL.Scheme = serverURL.Scheme
+	req.URL.Host = serverURL.Host
+	req.Host = ""
+	return client, nil
+}
+
+// withRedirectPolicy returns a copy of a client that follows at most
+// maxRedirects redirects, recording each redirect it follows in a test case
+// result.
+func withRedirectPolicy(client *http.Client, maxRedirects int, result *HTTPTestCaseResult) *http.Client {
+	c := *client
+	c.CheckRedirect = newRedirectPolicy(maxRedirects, client.CheckRedirect, result)
+	return &c
+}
+
+// groupContexts returns the HTTP test contexts used by the test cases of a
+// group and its subgroups.
+func groupContexts(group *TestGroup) []*HTTPTestContext {
+	var contexts []*HTTPTestContext
+	seen := map[*HTTPTestContext]bool{}
+	var walk func(*TestGroup)
+	walk = func(g *TestGroup) {
+		for _, test := range g.Tests {
+			if tc, ok := test.(*HTTPTestCase); ok && !seen[tc.tctx] {
+				seen[tc.tctx] = true
+				contexts = append(contexts, tc.tctx)
+			}
+		}
+
+		for _, subgroup := range g.Subgroups {
+			walk(subgroup)
+		}
+	}
+
+	walk(group)
+	return contexts
 }
 
 func toBytes(body any) ([]byte, error) {
@@ -119,6 +196,12 @@ func toBytes(body any) ([]byte, error) {
 			b = v()
 		case func() ([]byte, error):
 			b, err = v()
+		case FormBody:
+			b, err = v.encode()
+		case *MultipartBody:
+			b, err = v.encode()
+		case bodyTemplate:
+			b, err = v.render()
 		default:
 			b, err = json.Marshal(body)
 		}
@@ -151,27 +234,15 @@ func toInterface(body []byte) any {
 
 type parameters map[string]any
 
-// Apply maps the values to a target path.
+// applyTo expands the values into a path template, returning the escaped
+// path. See expandPath for the template syntax.
 func (p parameters) applyTo(path string) (string, error) {
 	resolved, err := mtjson.ResolveDeferred(map[string]any(p))
 	if err != nil {
 		return "", err
 	}
 
-	result := path
-	for k, v := range resolved.(map[string]any) {
-		str, err := paramString(v)
-		if err != nil {
-			return "", err
-		}
-
-		result = strings.ReplaceAll(result, ":"+k, str)
-		if err != nil {
-			return "", err
-		}
-	}
-
-	return result, nil
+	return expandPath(path, resolved.(map[string]any))
 }
 
 func paramString(v any) (string, error) {
@@ -181,33 +252,23 @@ func paramString(v any) (string, error) {
 		str = fmt.Sprintf("%t", value)
 	case float32, float64:
 		str = fmt.Sprintf("%g", value)
-	case int, int32, int64, uint, uint32, uint64:
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
 		str = fmt.Sprintf("%d", value)
 	case string:
 		str = value
 	case []string:
 		str = strings.Join(value, ",")
-	default:
-		return "", fmt.Errorf("unsupported parameter type: %T", value)
-	}
-
-	return str, nil
-}
-
-func (p parameters) asRawQuery() (string, error) {
-	resolved, err := mtjson.ResolveDeferred(map[string]any(p))
-	if err != nil {
-		return "", err
-	}
-
-	params := url.Values{}
-	for k, v := range resolved.(map[string]any) {
-		str, err := paramString(v)
+	case encoding.TextMarshaler:
+		b, err := value.MarshalText()
 		if err != nil {
 			return "", err
 		}
-		params.Add(k, str)
+		str = string(b)
+	case fmt.Stringer:
+		str = value.String()
+	default:
+		return "", fmt.Errorf("unsupported parameter type: %T", value)
 	}
 
-	return params.Encode(), nil
+	return str, nil
 }
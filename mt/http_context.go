@@ -1,12 +1,37 @@
 package mt
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"path/filepath"
 	"strings"
+	"sync"
+
+	"github.com/jefflinse/melatonin/cassette"
+)
+
+const (
+	// ServeWithRecorder causes a handler context to serve requests by calling
+	// the handler directly with an httptest.ResponseRecorder.
+	ServeWithRecorder = iota
+
+	// ServeWithServer causes a handler context to serve requests over the
+	// network using an httptest.Server.
+	ServeWithServer
+
+	// ServeWithTLSServer causes a handler context to serve requests over the
+	// network using an httptest.Server with TLS enabled.
+	ServeWithTLSServer
+
+	// ServeWithHTTP2Server causes a handler context to serve requests over the
+	// network using an httptest.Server with TLS and HTTP/2 enabled.
+	ServeWithHTTP2Server
 )
 
 // An HTTPTestContext is used to create HTTP test cases that target either
@@ -15,6 +40,58 @@ type HTTPTestContext struct {
 	BaseURL string
 	Client  *http.Client
 	Handler http.Handler
+
+	// HandlerServeMode determines how requests are served when the context
+	// targets a handler.
+	//
+	// Default is ServeWithRecorder.
+	HandlerServeMode int
+
+	// IgnoredHeaders is the list of response headers that are not reported as
+	// unexpected by test cases that expect an exact set of headers.
+	//
+	// If nil, DefaultIgnoredHeaders is used.
+	IgnoredHeaders []string
+
+	// MaxRedirects is the maximum number of redirects to follow for each
+	// test case. Use NoRedirects to return redirect responses without
+	// following them.
+	//
+	// If 0, URL contexts use the HTTP client's redirect policy, and handler
+	// contexts don't follow redirects, however they're served.
+	MaxRedirects int
+
+	// TLSConfig is the TLS configuration used by the client when making
+	// requests. If nil, the client's own TLS configuration is used.
+	TLSConfig *tls.Config
+
+	// ServerTLSConfig is the TLS configuration used by the test server when
+	// serving a handler with ServeWithTLSServer or ServeWithHTTP2Server.
+	// If nil, the server uses a self-signed certificate.
+	ServerTLSConfig *tls.Config
+
+	faults        *FaultTransport
+	trackedFaults []*FaultTransport
+	recorder      *cassetteRecorder
+	openAPI       *openAPISpec
+
+	server       *httptest.Server
+	serverClient *http.Client
+	serverMu     sync.Mutex
+	tlsClient    *http.Client
+}
+
+// DefaultIgnoredHeaders is the list of response headers that are not reported
+// as unexpected by test cases that expect an exact set of headers, unless a
+// context specifies its own list. It includes the headers that servers and
+// transports add on their own. Content-Type is always compared, including the
+// one sniffed for a handler that doesn't set its own.
+var DefaultIgnoredHeaders = []string{
+	"Connection",
+	"Content-Length",
+	"Date",
+	"Keep-Alive",
+	"Transfer-Encoding",
 }
 
 // DefaultContext returns an HTTPTestContext using the default HTTP client.
@@ -42,9 +119,204 @@ func NewHandlerContext(handler http.Handler) *HTTPTestContext {
 // context.
 func (c *HTTPTestContext) WithHTTPClient(client *http.Client) *HTTPTestContext {
 	c.Client = client
+	c.tlsClient = nil
+	return c
+}
+
+// WithCassette causes the context to record or replay its HTTP interactions
+// using a cassette file, and returns the context. Relative paths are resolved
+// against the working directory.
+//
+// The mode is one of CassetteOff, CassetteRecord, CassetteReplay, or
+// CassetteAuto, and can be overridden for all contexts by setting the
+// MELATONIN_CASSETTE_MODE environment variable to "off", "record", "replay",
+// or "auto".
+//
+// Cassettes are only used by URL contexts.
+func (c *HTTPTestContext) WithCassette(path string, mode int) *HTTPTestContext {
+	r := c.cassetteRecorder()
+	r.path = cassettePath(path)
+	r.mode = mode
+	return c
+}
+
+// WithCassetteMatching sets the request attributes used to match requests
+// against recorded interactions when replaying, and returns the context.
+//
+// Default is cassette.DefaultMatch.
+func (c *HTTPTestContext) WithCassetteMatching(on cassette.MatchOn) *HTTPTestContext {
+	c.cassetteRecorder().match = on
+	return c
+}
+
+// WithRedaction adds redactors that remove sensitive content from recorded
+// interactions before they are written to the context's cassette, and returns
+// the context. Common credential headers are always redacted.
+func (c *HTTPTestContext) WithRedaction(redactors ...cassette.Redactor) *HTTPTestContext {
+	r := c.cassetteRecorder()
+	r.redactors = append(r.redactors, redactors...)
+	return c
+}
+
+// WithClientCertificate adds a certificate to present to the server when
+// making TLS requests and returns the context.
+func (c *HTTPTestContext) WithClientCertificate(cert tls.Certificate) *HTTPTestContext {
+	cfg := c.clientTLSConfig()
+	cfg.Certificates = append(cfg.Certificates, cert)
+	return c
+}
+
+// WithIgnoredHeaders sets the response headers that are not reported as
+// unexpected by test cases that expect an exact set of headers, and returns
+// the context. Any headers explicitly expected by a test case are still
+// compared. Calling WithIgnoredHeaders with no keys causes every unexpected
+// header to be reported.
+func (c *HTTPTestContext) WithIgnoredHeaders(keys ...string) *HTTPTestContext {
+	c.IgnoredHeaders = append([]string{}, keys...)
+	return c
+}
+
+// ignoredHeaders returns the set of canonical header keys ignored by exact
+// header matching.
+func (c *HTTPTestContext) ignoredHeaders() map[string]bool {
+	keys := c.IgnoredHeaders
+	if keys == nil {
+		keys = DefaultIgnoredHeaders
+	}
+
+	ignored := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		ignored[http.CanonicalHeaderKey(key)] = true
+	}
+
+	return ignored
+}
+
+// WithMaxRedirects sets the maximum number of redirects to follow for each
+// test case and returns the context. Use NoRedirects to return redirect
+// responses without following them, or FollowRedirects to follow redirects
+// up to the default limit.
+func (c *HTTPTestContext) WithMaxRedirects(maxRedirects int) *HTTPTestContext {
+	c.MaxRedirects = maxRedirects
+	return c
+}
+
+// WithOpenAPI causes the requests and responses of every test case created by
+// the context to be validated against an OpenAPI 3 spec file, in JSON or YAML,
+// and returns the context. Relative paths are resolved against the working
+// directory.
+//
+// Each request is matched to an operation by its method and path template,
+// relative to the paths of the spec's server URLs. The response status must be
+// declared by the operation, the response must include the headers the spec
+// marks as required, and the response body must conform to the schema of its
+// media type. Requests matching no operation are reported as undocumented.
+// Validation failures are reported along with the test case's own failures.
+func (c *HTTPTestContext) WithOpenAPI(path string) *HTTPTestContext {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cfg.WorkingDir, path)
+	}
+
+	validateRequests := c.openAPI != nil && c.openAPI.validateRequests
+	c.openAPI = &openAPISpec{path: path, validateRequests: validateRequests}
+	return c
+}
+
+// WithOpenAPIRequestValidation sets whether request bodies are also validated
+// against the schemas of the operations in the context's OpenAPI spec, and
+// returns the context. Streamed request bodies are never validated.
+func (c *HTTPTestContext) WithOpenAPIRequestValidation(enabled bool) *HTTPTestContext {
+	if c.openAPI == nil {
+		c.openAPI = &openAPISpec{}
+	}
+
+	c.openAPI.validateRequests = enabled
+	return c
+}
+
+// WithRootCAs sets the root certificate authorities used to verify server
+// certificates and returns the context.
+func (c *HTTPTestContext) WithRootCAs(pool *x509.CertPool) *HTTPTestContext {
+	c.clientTLSConfig().RootCAs = pool
+	return c
+}
+
+// WithServerName sets the server name used to verify server certificates
+// and returns the context.
+func (c *HTTPTestContext) WithServerName(name string) *HTTPTestContext {
+	c.clientTLSConfig().ServerName = name
+	return c
+}
+
+// WithServerTLSConfig sets the TLS configuration used by the test server
+// when serving a handler over TLS and returns the context.
+func (c *HTTPTestContext) WithServerTLSConfig(cfg *tls.Config) *HTTPTestContext {
+	c.ServerTLSConfig = cfg
+	return c
+}
+
+// WithTLSConfig sets the TLS configuration used by the client and returns
+// the context.
+func (c *HTTPTestContext) WithTLSConfig(cfg *tls.Config) *HTTPTestContext {
+	c.TLSConfig = cfg
+	c.tlsClient = nil
+	return c
+}
+
+// WithTLSVersions sets the minimum and maximum TLS versions the client will
+// negotiate and returns the context. A value of 0 leaves the respective bound
+// unset.
+func (c *HTTPTestContext) WithTLSVersions(minVersion, maxVersion uint16) *HTTPTestContext {
+	cfg := c.clientTLSConfig()
+	cfg.MinVersion = minVersion
+	cfg.MaxVersion = maxVersion
 	return c
 }
 
+// WithFaults installs a fault-injecting transport on the context's client and
+// returns the context. Faults injected by the transport are reported in the
+// results of the test cases that triggered them.
+//
+// The transport wraps the client's own transport, so its Transport field is
+// ignored. Handlers served with ServeWithRecorder don't use a client, so no
+// faults are injected into requests made to them.
+func (c *HTTPTestContext) WithFaults(faults *FaultTransport) *HTTPTestContext {
+	c.faults = faults
+	return c.TrackFaults(faults)
+}
+
+// TrackFaults causes faults injected by a transport installed elsewhere, such
+// as on the outbound client of a handler under test, to be reported in the
+// results of test cases created by the context, and returns the context.
+func (c *HTTPTestContext) TrackFaults(faults *FaultTransport) *HTTPTestContext {
+	c.trackedFaults = append(c.trackedFaults, faults)
+	return c
+}
+
+// WithHandlerServeMode sets the mode used to serve requests to the context's
+// handler and returns the context.
+//
+// Any mode other than ServeWithRecorder starts an httptest.Server the first time
+// a test case is executed. The server is shut down when Close is called, or
+// automatically once a test runner has run a group using the context. Within a
+// Go test, that happens when the test and its subtests complete.
+func (c *HTTPTestContext) WithHandlerServeMode(mode int) *HTTPTestContext {
+	c.HandlerServeMode = mode
+	return c
+}
+
+// Close shuts down any test server started by the context.
+func (c *HTTPTestContext) Close() {
+	c.serverMu.Lock()
+	defer c.serverMu.Unlock()
+
+	if c.server != nil {
+		c.server.Close()
+		c.server = nil
+		c.serverClient = nil
+	}
+}
+
 // DELETE is a shortcut for NewTestCase(http.MethodDelete, path).
 func (c *HTTPTestContext) DELETE(path string, description ...string) *HTTPTestCase {
 	return c.newHTTPTestCase(http.MethodDelete, path, description...)
@@ -115,6 +387,123 @@ func (c *HTTPTestContext) createURL(path string) (*url.URL, error) {
 	return base.ResolveReference(endpoint), nil
 }
 
+// cassetteRecorder returns the context's cassette recorder, creating it if
+// necessary.
+func (c *HTTPTestContext) cassetteRecorder() *cassetteRecorder {
+	if c.recorder == nil {
+		c.recorder = &cassetteRecorder{match: cassette.DefaultMatch}
+	}
+
+	return c.recorder
+}
+
+// withCassette returns a copy of a client that records or replays requests
+// using the context's cassette, if any.
+func (c *HTTPTestContext) withCassette(client *http.Client) *http.Client {
+	if c.recorder == nil {
+		return client
+	}
+
+	cassetteClient := *client
+	cassetteClient.Transport = &cassetteTransport{recorder: c.recorder, base: client.Transport}
+	return &cassetteClient
+}
+
+// clientTLSConfig returns the context's client TLS configuration, creating it
+// if necessary.
+func (c *HTTPTestContext) clientTLSConfig() *tls.Config {
+	if c.TLSConfig == nil {
+		c.TLSConfig = &tls.Config{}
+	}
+
+	c.tlsClient = nil
+	return c.TLSConfig
+}
+
+// httpClient returns the HTTP client used to make requests for a URL context.
+func (c *HTTPTestContext) httpClient() (*http.Client, error) {
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	if c.TLSConfig == nil {
+		return client, nil
+	}
+
+	if c.tlsClient == nil {
+		transport, err := cloneTransport(client.Transport)
+		if err != nil {
+			return nil, err
+		}
+
+		transport.TLSClientConfig = c.TLSConfig.Clone()
+		tlsClient := *client
+		tlsClient.Transport = transport
+		c.tlsClient = &tlsClient
+	}
+
+	return c.tlsClient, nil
+}
+
+// withFaults returns a copy of a client that sends requests through the
+// context's fault-injecting transport, if any.
+func (c *HTTPTestContext) withFaults(client *http.Client) *http.Client {
+	if c.faults == nil {
+		return client
+	}
+
+	faultClient := *client
+	faultClient.Transport = &faultRoundTripper{faults: c.faults, base: client.Transport}
+	return &faultClient
+}
+
+// testServer returns the context's test server and a client configured to
+// make requests to it, starting the server if necessary.
+func (c *HTTPTestContext) testServer() (*httptest.Server, *http.Client, error) {
+	c.serverMu.Lock()
+	defer c.serverMu.Unlock()
+
+	if c.server != nil {
+		return c.server, c.serverClient, nil
+	}
+
+	server := httptest.NewUnstartedServer(c.Handler)
+	switch c.HandlerServeMode {
+	case ServeWithServer:
+		server.Start()
+	case ServeWithTLSServer, ServeWithHTTP2Server:
+		if c.ServerTLSConfig != nil {
+			server.TLS = c.ServerTLSConfig.Clone()
+		}
+		server.EnableHTTP2 = c.HandlerServeMode == ServeWithHTTP2Server
+		server.StartTLS()
+	default:
+		return nil, nil, fmt.Errorf("unknown handler serve mode %d", c.HandlerServeMode)
+	}
+
+	client := server.Client()
+	if c.TLSConfig != nil && server.TLS != nil {
+		transport, err := cloneTransport(client.Transport)
+		if err != nil {
+			server.Close()
+			return nil, nil, err
+		}
+
+		cfg := c.TLSConfig.Clone()
+		if cfg.RootCAs == nil && transport.TLSClientConfig != nil {
+			cfg.RootCAs = transport.TLSClientConfig.RootCAs
+		}
+
+		transport.TLSClientConfig = cfg
+		client.Transport = transport
+	}
+
+	c.server = server
+	c.serverClient = client
+	return server, client, nil
+}
+
 func (c *HTTPTestContext) newHTTPTestCase(method, path string, description ...string) *HTTPTestCase {
 	u, err := c.createURL(path)
 	if err != nil {
@@ -0,0 +1,94 @@
+package mt_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/jefflinse/melatonin/mt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandlerServeModes(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %t", r.Proto, r.TLS != nil)
+	})
+
+	for _, test := range []struct {
+		name         string
+		mode         int
+		wantBody     string
+		wantFailures []string
+	}{
+		{
+			name:     "recorder",
+			mode:     mt.ServeWithRecorder,
+			wantBody: "HTTP/1.1 false",
+		},
+		{
+			name:     "server",
+			mode:     mt.ServeWithServer,
+			wantBody: "HTTP/1.1 false",
+		},
+		{
+			name:     "TLS server",
+			mode:     mt.ServeWithTLSServer,
+			wantBody: "HTTP/1.1 true",
+		},
+		{
+			name:     "HTTP/2 server",
+			mode:     mt.ServeWithHTTP2Server,
+			wantBody: "HTTP/2.0 true",
+		},
+		{
+			name:         "unknown mode",
+			mode:         42,
+			wantFailures: []string{"failed to handle HTTP request: unknown handler serve mode 42"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := mt.NewHandlerContext(handler).WithHandlerServeMode(test.mode)
+			t.Cleanup(ctx.Close)
+
+			result := execute(t, ctx.GET("/"))
+			assert.Equal(t, test.wantFailures, failures(result))
+			if test.wantFailures == nil {
+				assert.Equal(t, test.wantBody, string(result.Body))
+			}
+		})
+	}
+}
+
+func TestHandlerServeModeFailures(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	ctx := mt.NewHandlerContext(handler).WithHandlerServeMode(mt.ServeWithServer)
+	t.Cleanup(ctx.Close)
+
+	result := execute(t, ctx.GET("/").ExpectStatus(http.StatusOK))
+	assert.Equal(t, []string{"expected status 200 OK, got 418 I'm a teapot"}, failures(result))
+}
+
+func TestRunnerClosesTestServers(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	used := mt.NewHandlerContext(handler).WithHandlerServeMode(mt.ServeWithServer)
+	other := mt.NewHandlerContext(handler).WithHandlerServeMode(mt.ServeWithServer)
+	t.Cleanup(other.Close)
+
+	otherResult := execute(t, other.GET("/"))
+	groupResult := mt.NewTestRunner().RunTests(used.GET("/"))
+	usedResult := groupResult.TestResults[0].TestResult.(*mt.HTTPTestCaseResult)
+
+	// the server of the context run by the group is shut down
+	_, err := http.Get(usedResult.URL)
+	assert.Error(t, err)
+
+	// the server of a context not used by the group keeps running
+	resp, err := http.Get(otherResult.URL)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+	}
+}
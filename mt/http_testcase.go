@@ -3,14 +3,18 @@ package mt
 import (
 	"bytes"
 	"context"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/jefflinse/melatonin/expect"
 	"github.com/jefflinse/melatonin/golden"
 	mtjson "github.com/jefflinse/melatonin/json"
 )
@@ -44,10 +48,38 @@ type HTTPTestCase struct {
 
 	// GoldenFilePath is a path to a golden file defining expectations for the test case.
 	//
-	// If set, any WantStatus, WantHeaders, or WantBody values are overridden with
-	// values from the golden file.
+	// If set, the golden file's status replaces the expected status, its
+	// headers are added to the expected headers, and its body, if any, replaces
+	// the expected body. ExpectGolden loads the file immediately, so
+	// expectations set after calling it override the golden file's values. A
+	// path assigned directly is loaded when the test case is executed.
 	GoldenFilePath string
 
+	// Path of the golden file loaded into the expectations, and the error
+	// loading it, if any.
+	goldenPath string
+	goldenErr  error
+
+	// Whether to force chunked transfer encoding for the request body.
+	chunked bool
+
+	// Content encoding used to compress the request body, if any.
+	compression string
+
+	// Explicit Content-Length for the request body, if set.
+	contentLength *int64
+
+	// Encoder used to encode the request body, overriding the encoder
+	// selected by the request's Content-Type header.
+	encoder Encoder
+
+	// Whether to send a deliberately malformed compressed request body.
+	malformedCompression bool
+
+	// Maximum number of redirects to follow, overriding the context's
+	// setting if non-zero.
+	maxRedirects int
+
 	// Path parameters to be mapped into the request path.
 	pathParams parameters
 
@@ -63,6 +95,9 @@ type HTTPTestCase struct {
 	// Underlying HTTP request for the test case.
 	request *http.Request
 
+	// The request URL before path and query parameters were applied.
+	urlTemplate *url.URL
+
 	// Cancel function for the underlying HTTP request.
 	cancel context.CancelFunc
 }
@@ -72,20 +107,64 @@ type expectatons struct {
 	// Body is the expected HTTP response body content.
 	Body any
 
-	// ExactHeaders indicates whether or not any unexpected response headers
-	// should be treated as a test failure.
+	// BodyAs is an optional function, set by ExpectBodyAs, that decodes the
+	// HTTP response body into a typed value and runs an assertion against it.
+	BodyAs func(contentType string, body []byte) (any, error)
+
+	// BodySchema is an optional predicate, such as one created by
+	// expect.Schema, run against the decoded HTTP response body.
+	BodySchema expect.Predicate
+
+	// ExactHeaders indicates whether or not any unexpected response headers,
+	// or unexpected values of expected headers, should be treated as a test
+	// failure. Headers ignored by the test context are never unexpected.
 	WantExactHeaders bool
 
 	// ExactJSONBody indicates whether or not the expected JSON should be matched
 	// exactly (true) or treated as a subset of the response JSON (false).
 	WantExactJSONBody bool
 
+	// WantFormBody indicates whether or not the response body should be decoded
+	// as application/x-www-form-urlencoded content before being compared.
+	WantFormBody bool
+
 	// Headers is a map of HTTP headers that are expected to be present in
 	// the HTTP response.
 	Headers http.Header
 
+	// HeaderPredicates is a map of predicates run against the values of HTTP
+	// response headers. Each predicate is passed the header's values as a
+	// single comma-separated string.
+	HeaderPredicates map[string][]expect.Predicate
+
+	// NoHeaders is a list of HTTP headers that are expected to be absent from
+	// the HTTP response.
+	NoHeaders []string
+
+	// Paths is a list of expectations for values selected from the response
+	// body by JSONPath expressions.
+	Paths []PathExpectation
+
+	// PeerCertificate is an optional predicate run against the certificate
+	// presented by the server.
+	PeerCertificate func(*x509.Certificate) error
+
+	// RedirectCount is the expected number of redirects followed.
+	RedirectCount *int
+
+	// RedirectTo is the expected URL of a redirect, either followed or
+	// returned as the response.
+	RedirectTo string
+
 	// Status is the expected HTTP status code of the response. Default is 200.
 	Status int
+
+	// StatusPredicate is an optional predicate run against the HTTP status
+	// code of the response, such as one created by StatusClass or StatusIn.
+	StatusPredicate expect.Predicate
+
+	// TLSVersion is the expected TLS version negotiated for the connection.
+	TLSVersion uint16
 }
 
 var _ TestCase = &HTTPTestCase{}
@@ -137,54 +216,116 @@ func (tc *HTTPTestCase) Execute() TestResult {
 		testCase: tc,
 	}
 
+	faultCounts := make([]int, len(tc.tctx.trackedFaults))
+	for i, faults := range tc.tctx.trackedFaults {
+		faultCounts[i] = faults.injectedCount()
+	}
+
+	defer func() {
+		for i, faults := range tc.tctx.trackedFaults {
+			result.Faults = append(result.Faults, faults.injectedSince(faultCounts[i])...)
+		}
+	}()
+
+	if err := tc.prepare(); err != nil {
+		return result.addFailures(err)
+	}
+
 	if tc.BeforeFunc != nil {
 		if err := tc.BeforeFunc(); err != nil {
 			return result.addFailures(err)
 		}
 	}
 
-	// apply path parameters
-	expandedPath, err := tc.pathParams.applyTo(tc.request.URL.Path)
+	// apply path and query parameters, keeping the original URL for
+	// subsequent executions
+	if tc.urlTemplate == nil {
+		u := *tc.request.URL
+		tc.urlTemplate = &u
+	}
+
+	// expand from the path as written, so that escaped literals such as %2F
+	// aren't decoded; without a raw path, the decoded path is escaped as usual
+	template := tc.urlTemplate.RawPath
+	if template == "" {
+		template = strings.ReplaceAll(tc.urlTemplate.Path, "%", "%25")
+	}
+
+	escapedPath, err := tc.pathParams.applyTo(template)
 	if err != nil {
 		return result.addFailures(err)
 	}
-	tc.request.URL.Path = expandedPath
 
-	rawQuery, err := tc.queryParams.asRawQuery()
-	if err != nil {
+	if tc.request.URL.Path, err = url.PathUnescape(escapedPath); err != nil {
 		return result.addFailures(err)
 	}
-	tc.request.URL.RawQuery = rawQuery
+	tc.request.URL.RawPath = escapedPath
 
-	// resolve deferred values
-	resolvedBody, err := mtjson.ResolveDeferred(tc.requestBody)
+	// query parameters set on the path are kept ahead of those added
+	rawQuery, err := tc.queryParams.asRawQuery()
 	if err != nil {
 		return result.addFailures(err)
 	}
 
-	b, err := toBytes(resolvedBody)
-	if err != nil {
+	tc.request.URL.RawQuery = tc.urlTemplate.RawQuery
+	if rawQuery != "" {
+		if tc.request.URL.RawQuery != "" {
+			tc.request.URL.RawQuery += "&"
+		}
+		tc.request.URL.RawQuery += rawQuery
+	}
+
+	if err := tc.prepareBody(); err != nil {
 		return result.addFailures(err)
 	}
 
-	tc.request.Body = io.NopCloser(bytes.NewReader(b))
+	maxRedirects := tc.maxRedirects
+	if maxRedirects == 0 {
+		maxRedirects = tc.tctx.MaxRedirects
+	}
 
+	var resp *http.Response
 	if tc.tctx.Handler != nil {
-		result.Status, result.Headers, result.Body, err = handleRequest(tc.tctx.Handler, tc.request)
+		// handlers don't follow redirects unless asked to, however they're served
+		if maxRedirects == 0 {
+			maxRedirects = NoRedirects
+		}
+
+		if tc.tctx.HandlerServeMode == ServeWithRecorder {
+			policy := newRedirectPolicy(maxRedirects, nil, result)
+			resp, result.Body, err = handleRequest(tc.tctx.Handler, tc.request, policy)
+		} else {
+			var client *http.Client
+			client, err = prepareServerRequest(tc.tctx, tc.request)
+			if err == nil {
+				client = withRedirectPolicy(tc.tctx.withFaults(client), maxRedirects, result)
+				resp, result.Body, err = doRequest(client, tc.request)
+			}
+		}
 		if err != nil {
 			return result.addFailures(fmt.Errorf("failed to handle HTTP request: %w", err))
 		}
 	} else {
-		if tc.tctx.Client == nil {
-			tc.tctx.Client = http.DefaultClient
+		client, err := tc.tctx.httpClient()
+		if err != nil {
+			return result.addFailures(err)
 		}
 
-		result.Status, result.Headers, result.Body, err = doRequest(tc.tctx.Client, tc.request)
+		client = tc.tctx.withFaults(tc.tctx.withCassette(client))
+		client = withRedirectPolicy(client, maxRedirects, result)
+		resp, result.Body, err = doRequest(client, tc.request)
 		if err != nil {
 			return result.addFailures(fmt.Errorf("failed to execute HTTP request: %w", err))
 		}
 	}
 
+	result.Status = resp.StatusCode
+	result.Headers = resp.Header
+	result.TLS = resp.TLS
+	if resp.Request != nil {
+		result.URL = resp.Request.URL.String()
+	}
+
 	result.validateExpectations()
 
 	if tc.AfterFunc != nil {
@@ -196,6 +337,83 @@ func (tc *HTTPTestCase) Execute() TestResult {
 	return result
 }
 
+// prepareBody resolves the test case's request body and attaches it to the
+// underlying HTTP request.
+func (tc *HTTPTestCase) prepareBody() error {
+	// resolve deferred values
+	resolvedBody, err := mtjson.ResolveDeferred(tc.requestBody)
+	if err != nil {
+		return err
+	}
+
+	if stream, reproducible := toStreamFactory(resolvedBody); stream != nil {
+		if tc.compression != "" {
+			return errors.New("request body: compression is not supported for streamed bodies")
+		}
+
+		body, err := stream()
+		if err != nil {
+			return fmt.Errorf("request body: %w", err)
+		}
+
+		tc.request.Body = body
+		tc.request.ContentLength = -1
+		tc.request.GetBody = nil
+		if reproducible {
+			tc.request.GetBody = stream
+		}
+	} else {
+		encoder := tc.encoder
+		if encoder == nil {
+			encoder = lookupEncoder(tc.request.Header.Get("Content-Type"))
+		}
+
+		b, err := encodeBody(resolvedBody, encoder)
+		if err != nil {
+			return err
+		}
+
+		if tc.request.Header.Get("Content-Type") == "" {
+			if body, ok := resolvedBody.(interface{ ContentType() string }); ok {
+				if contentType := body.ContentType(); contentType != "" {
+					tc.request.Header.Set("Content-Type", contentType)
+				}
+			} else if tc.encoder != nil && len(b) > 0 {
+				tc.request.Header.Set("Content-Type", tc.encoder.ContentType())
+			}
+		}
+
+		if tc.compression != "" {
+			if b, err = compressBody(b, tc.compression, tc.malformedCompression); err != nil {
+				return fmt.Errorf("request body: %w", err)
+			}
+
+			if tc.request.Header.Get("Content-Encoding") == "" {
+				tc.request.Header.Set("Content-Encoding", tc.compression)
+			}
+		}
+
+		tc.request.Body = http.NoBody
+		tc.request.ContentLength = int64(len(b))
+		if len(b) > 0 {
+			tc.request.Body = io.NopCloser(bytes.NewReader(b))
+		}
+
+		tc.request.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(b)), nil
+		}
+	}
+
+	if tc.chunked {
+		tc.request.ContentLength = -1
+		tc.request.TransferEncoding = []string{"chunked"}
+	} else if tc.contentLength != nil {
+		tc.request.ContentLength = *tc.contentLength
+	}
+
+	return nil
+}
+
 // Target returns a string representing the target of the action performed by the
 // test case.
 func (tc *HTTPTestCase) Target() string {
@@ -207,11 +425,71 @@ func (tc *HTTPTestCase) Target() string {
 //
 
 // WithBody sets the request body for the test case.
+//
+// The body may be a []byte, a string, a function returning either, a
+// FormBody, a *MultipartBody, or any other value, which is encoded using the
+// encoder registered for the request's Content-Type header, or as JSON if
+// there is none. Deferred values are resolved when the test case is executed.
+//
+// To stream a body without buffering it in memory, pass an io.Reader, or a
+// func() io.Reader or func() (io.Reader, error) factory. A reader can only be
+// read once, so use a factory if the test case is executed more than once or
+// may follow a redirect that resends the body. Streamed bodies are sent with
+// chunked transfer encoding unless WithContentLength is used.
 func (tc *HTTPTestCase) WithBody(body any) *HTTPTestCase {
 	tc.requestBody = body
 	return tc
 }
 
+// WithBodyFile sets the request body for the test case to the contents of a
+// file, rendered as a text/template using the given data. Relative paths are
+// resolved against the working directory.
+//
+// The data may contain deferred values, such as values captured using the
+// bind package, which are resolved when the test case is executed. A json
+// template function encodes a value as JSON. Errors reading or rendering the
+// template, including references to missing keys, fail the test case.
+//
+// The Content-Type header is set from the file's extension unless the test
+// case sets it explicitly.
+func (tc *HTTPTestCase) WithBodyFile(path string, data map[string]any) *HTTPTestCase {
+	tc.requestBody = bodyTemplate{path: path, data: data}
+	return tc
+}
+
+// WithChunkedEncoding forces the request body to be sent using chunked
+// transfer encoding.
+func (tc *HTTPTestCase) WithChunkedEncoding() *HTTPTestCase {
+	tc.chunked = true
+	return tc
+}
+
+// WithCompression compresses the request body using the given content
+// encoding, either "gzip" or "deflate", and sets the Content-Encoding header.
+//
+// If the test case sets the Content-Encoding header explicitly, it is left
+// as-is, which allows a body to be sent with a mismatched header.
+func (tc *HTTPTestCase) WithCompression(encoding string) *HTTPTestCase {
+	tc.compression = encoding
+	tc.malformedCompression = false
+	return tc
+}
+
+// WithContentLength sets an explicit Content-Length for the request body,
+// overriding the length of the body itself.
+func (tc *HTTPTestCase) WithContentLength(length int64) *HTTPTestCase {
+	tc.contentLength = &length
+	return tc
+}
+
+// WithEncoder sets the encoder used to encode the request body, overriding
+// the encoder selected by the request's Content-Type header. The Content-Type
+// header is set from the encoder unless the test case sets it explicitly.
+func (tc *HTTPTestCase) WithEncoder(encoder Encoder) *HTTPTestCase {
+	tc.encoder = encoder
+	return tc
+}
+
 // WithHeader adds a request header to the test case.
 func (tc *HTTPTestCase) WithHeader(key, value string) *HTTPTestCase {
 	tc.request.Header.Set(key, value)
@@ -224,7 +502,31 @@ func (tc *HTTPTestCase) WithHeaders(headers http.Header) *HTTPTestCase {
 	return tc
 }
 
+// WithMalformedCompression behaves like WithCompression, but truncates the
+// compressed request body so that it can't be decompressed. This is useful for
+// verifying that a server rejects invalid input.
+func (tc *HTTPTestCase) WithMalformedCompression(encoding string) *HTTPTestCase {
+	tc.compression = encoding
+	tc.malformedCompression = true
+	return tc
+}
+
+// WithMaxRedirects sets the maximum number of redirects to follow for the
+// test case, overriding the context's setting. Use NoRedirects to return
+// redirect responses without following them.
+func (tc *HTTPTestCase) WithMaxRedirects(maxRedirects int) *HTTPTestCase {
+	tc.maxRedirects = maxRedirects
+	return tc
+}
+
 // WithPathParam adds a request path parameter to the test case.
+//
+// Parameters are referenced in the request path either as :name at the start
+// of a path segment, or using RFC 6570 expressions, such as {name}, {+name}
+// for reserved expansion that leaves characters like / unescaped, or
+// {/name*} to expand a slice into multiple path segments. Values are escaped.
+// Parameters missing from the path, or referenced but not provided, fail the
+// test case.
 func (tc *HTTPTestCase) WithPathParam(key string, value any) *HTTPTestCase {
 	tc.pathParams[key] = value
 	return tc
@@ -266,6 +568,35 @@ func (tc *HTTPTestCase) ExpectBody(body any) *HTTPTestCase {
 	return tc
 }
 
+// ExpectBodySchema sets a JSON Schema (draft 2020-12) that the decoded HTTP
+// response body must conform to. The schema may be the path of a schema file,
+// a compiled *json.Schema, or a schema document, such as a map or JSON text
+// as a []byte. Schema files are loaded from the working directory, as
+// described by expect.Schema.
+//
+// Each violation is reported as a separate failure at the location of the
+// violating value, such as .items[2].id.
+func (tc *HTTPTestCase) ExpectBodySchema(schema any) *HTTPTestCase {
+	var compiled *mtjson.Schema
+	var err error
+	switch v := schema.(type) {
+	case *mtjson.Schema:
+		compiled = v
+	case string:
+		compiled, err = mtjson.LoadSchema(v)
+	default:
+		compiled, err = mtjson.CompileSchema(v, "")
+	}
+
+	if err != nil {
+		tc.Expectations.BodySchema = func(any) error { return err }
+		return tc
+	}
+
+	tc.Expectations.BodySchema = expect.Schema(compiled)
+	return tc
+}
+
 // ExpectExactBody sets the expected HTTP response body for the test case.
 //
 // Unlike ExpectBody, ExpectExactBody willl cause the test case to fail
@@ -281,22 +612,65 @@ func (tc *HTTPTestCase) ExpectExactBody(body any) *HTTPTestCase {
 // ExpectExactHeaders sets the expected HTTP response headers for the test case.
 //
 // Unlike ExpectHeaders, ExpectExactHeaders willl cause the test case to fail
-// if any unexpected headers are present in the response.
+// if any unexpected headers are present in the response, or if any expected
+// header has unexpected values. Headers ignored by the test context, such as
+// Date and Content-Length by default, are never unexpected.
 func (tc *HTTPTestCase) ExpectExactHeaders(headers http.Header) *HTTPTestCase {
 	tc.Expectations.WantExactHeaders = true
 	return tc.ExpectHeaders(headers)
 }
 
+// ExpectFormBody sets the expected HTTP response body for the test case,
+// decoding the response body as application/x-www-form-urlencoded content
+// regardless of its Content-Type.
+//
+// The expected body may be a FormBody, url.Values, or any value accepted by
+// ExpectBody. Repeated keys decode to slices, and all other keys decode to
+// strings.
+func (tc *HTTPTestCase) ExpectFormBody(body any) *HTTPTestCase {
+	tc.Expectations.WantFormBody = true
+	return tc.ExpectBody(toFormExpectation(body))
+}
+
 // ExpectHeader adds an expected HTTP response header for the test case.
-func (tc *HTTPTestCase) ExpectHeader(key, value string) *HTTPTestCase {
+//
+// The value may be a string or slice of strings, which replace any values
+// previously expected for the header, or an expect.Predicate, which is run
+// against the header's values joined into a single comma-separated string.
+// Any other value is formatted as a string.
+func (tc *HTTPTestCase) ExpectHeader(key string, value any) *HTTPTestCase {
+	switch v := value.(type) {
+	case expect.Predicate:
+		return tc.expectHeaderPredicate(key, v)
+	case func(any) error:
+		return tc.expectHeaderPredicate(key, v)
+	}
+
 	if tc.Expectations.Headers == nil {
 		tc.Expectations.Headers = http.Header{}
 	}
 
-	tc.Expectations.Headers.Set(key, value)
+	switch v := value.(type) {
+	case string:
+		tc.Expectations.Headers.Set(key, v)
+	case []string:
+		tc.Expectations.Headers[http.CanonicalHeaderKey(key)] = v
+	default:
+		tc.Expectations.Headers.Set(key, fmt.Sprint(v))
+	}
+
 	return tc
 }
 
+// ExpectHeaderContains adds an expected HTTP response header for the test case
+// whose value is a comma-separated list, such as Vary or Cache-Control, that
+// contains each of the given elements. Elements are compared without regard to
+// case or surrounding whitespace, and may appear in any order and across any
+// number of header lines.
+func (tc *HTTPTestCase) ExpectHeaderContains(key string, elements ...string) *HTTPTestCase {
+	return tc.expectHeaderPredicate(key, headerListContains(elements))
+}
+
 // ExpectHeaders sets the expected HTTP response headers for the test case.
 //
 // Unlike ExpectExactHeaders, ExpectHeaders only verifies that the expected
@@ -306,44 +680,178 @@ func (tc *HTTPTestCase) ExpectHeaders(headers http.Header) *HTTPTestCase {
 	return tc
 }
 
-// ExpectGolden causes the test case to load its HTTP response expectations
-// from a golden file.
+// ExpectGolden loads HTTP response expectations from a golden file and returns
+// the test case. The golden file's status replaces the expected status, its
+// headers are added to the expected headers, and its body, if it has one,
+// replaces the expected body; other expectations set before ExpectGolden are
+// kept. Expectations set afterwards override the golden file's values.
 func (tc *HTTPTestCase) ExpectGolden(path string) *HTTPTestCase {
 	tc.GoldenFilePath = path
+	tc.loadGolden()
+	return tc
+}
+
+// ExpectNoHeader adds an HTTP response header that is expected to be absent
+// from the response.
+func (tc *HTTPTestCase) ExpectNoHeader(key string) *HTTPTestCase {
+	tc.Expectations.NoHeaders = append(tc.Expectations.NoHeaders, key)
+	return tc
+}
+
+// ExpectPath adds an expectation that every value selected from the response
+// body by a JSONPath expression, such as $.items[*].id, matches the expected
+// value. At least one value must be selected. Values are compared in the same
+// way as ExpectBody, and failures are reported using the concrete path of
+// each failing value, such as $.items[2].id. An invalid expression fails the
+// test case before its request is sent.
+func (tc *HTTPTestCase) ExpectPath(path string, expected any) *HTTPTestCase {
+	tc.Expectations.Paths = append(tc.Expectations.Paths, newPathExpectation(path, expected, false))
+	return tc
+}
+
+// ExpectPathAny adds an expectation that at least one value selected from the
+// response body by a JSONPath expression matches the expected value.
+func (tc *HTTPTestCase) ExpectPathAny(path string, expected any) *HTTPTestCase {
+	tc.Expectations.Paths = append(tc.Expectations.Paths, newPathExpectation(path, expected, true))
+	return tc
+}
+
+// ExpectRedirectCount sets the expected number of redirects followed for the
+// test case.
+func (tc *HTTPTestCase) ExpectRedirectCount(count int) *HTTPTestCase {
+	tc.Expectations.RedirectCount = &count
+	return tc
+}
+
+// ExpectRedirectTo sets the expected redirect URL for the test case. The
+// expectation is met if any followed redirect, or a redirect response that
+// was not followed, points to the URL. URLs without a host are matched
+// against the path and, if present, the query of the redirect location.
+func (tc *HTTPTestCase) ExpectRedirectTo(url string) *HTTPTestCase {
+	tc.Expectations.RedirectTo = url
 	return tc
 }
 
 // ExpectStatus sets the expected HTTP status code for the test case.
 func (tc *HTTPTestCase) ExpectStatus(status int) *HTTPTestCase {
 	tc.Expectations.Status = status
+	tc.Expectations.StatusPredicate = nil
+	return tc
+}
+
+// ExpectStatusIn sets an expectation that the HTTP status code of the response
+// is one of a set of status codes, and returns the test case.
+func (tc *HTTPTestCase) ExpectStatusIn(statuses ...int) *HTTPTestCase {
+	return tc.ExpectStatusPredicate(StatusIn(statuses...))
+}
+
+// ExpectStatusPattern sets an expectation that the HTTP status code of the
+// response matches a status pattern, such as "2xx", "200|204", or
+// "200-299|404", and returns the test case. See StatusPattern.
+func (tc *HTTPTestCase) ExpectStatusPattern(pattern string) *HTTPTestCase {
+	return tc.ExpectStatusPredicate(StatusPattern(pattern))
+}
+
+// ExpectStatusPredicate sets an expectation that the HTTP status code of the
+// response satisfies a predicate, such as one created by StatusClass or
+// StatusRange, and returns the test case. The predicate is passed the status
+// code as an int.
+func (tc *HTTPTestCase) ExpectStatusPredicate(predicate expect.Predicate) *HTTPTestCase {
+	tc.Expectations.Status = 0
+	tc.Expectations.StatusPredicate = predicate
+	return tc
+}
+
+// ExpectPeerCertificate sets a predicate that the certificate presented by the
+// server must satisfy for the test case.
+func (tc *HTTPTestCase) ExpectPeerCertificate(predicate func(*x509.Certificate) error) *HTTPTestCase {
+	tc.Expectations.PeerCertificate = predicate
+	return tc
+}
+
+// ExpectTLSVersion sets the expected TLS version negotiated for the test case,
+// such as tls.VersionTLS13.
+func (tc *HTTPTestCase) ExpectTLSVersion(version uint16) *HTTPTestCase {
+	tc.Expectations.TLSVersion = version
+	return tc
+}
+
+// expectHeaderPredicate adds a predicate run against the values of an HTTP
+// response header.
+func (tc *HTTPTestCase) expectHeaderPredicate(key string, predicate expect.Predicate) *HTTPTestCase {
+	if tc.Expectations.HeaderPredicates == nil {
+		tc.Expectations.HeaderPredicates = map[string][]expect.Predicate{}
+	}
+
+	key = http.CanonicalHeaderKey(key)
+	tc.Expectations.HeaderPredicates[key] = append(tc.Expectations.HeaderPredicates[key], predicate)
 	return tc
 }
 
-// Validate ensures that the test case is valid can can be run.
+// Validate ensures that the test case is valid can can be run, loading its
+// golden file if it hasn't been loaded yet.
 func (tc *HTTPTestCase) Validate() error {
 	if tc.tctx.BaseURL != "" && tc.tctx.Handler != nil {
 		return fmt.Errorf("HTTP test context %q cannot specify both a base URL and handler", tc.tctx.BaseURL)
 	}
 
-	if tc.GoldenFilePath != "" {
-		path := tc.GoldenFilePath
-		if !filepath.IsAbs(path) {
-			path = filepath.Join(cfg.WorkingDir, path)
-		}
+	return tc.prepare()
+}
 
-		golden, err := golden.LoadFile(path)
-		if err != nil {
+// prepare compiles the test case's path expectations and loads its golden
+// file if it hasn't been loaded yet. It's called by Execute, which fails the
+// test case if it returns an error.
+func (tc *HTTPTestCase) prepare() error {
+	for _, path := range tc.Expectations.Paths {
+		if _, err := path.compile(); err != nil {
 			return err
 		}
+	}
+
+	if tc.GoldenFilePath != tc.goldenPath {
+		tc.loadGolden()
+	}
+
+	return tc.goldenErr
+}
+
+// loadGolden loads the expectations of the test case's golden file into those
+// already set.
+func (tc *HTTPTestCase) loadGolden() {
+	tc.goldenPath = tc.GoldenFilePath
+	tc.goldenErr = nil
+
+	path := tc.GoldenFilePath
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cfg.WorkingDir, path)
+	}
 
-		tc.Expectations.Status = golden.WantStatus
-		tc.Expectations.Headers = golden.WantHeaders
+	golden, err := golden.LoadFile(path)
+	if err != nil {
+		tc.goldenErr = err
+		return
+	}
+
+	tc.Expectations.Status = golden.WantStatus
+	tc.Expectations.StatusPredicate = nil
+	if golden.WantStatusPattern != "" {
+		tc.Expectations.StatusPredicate = StatusPattern(golden.WantStatusPattern)
+	}
+
+	if golden.WantHeaders != nil {
+		if tc.Expectations.Headers == nil {
+			tc.Expectations.Headers = http.Header{}
+		}
+		for key, values := range golden.WantHeaders {
+			tc.Expectations.Headers[key] = values
+		}
+		tc.Expectations.WantExactHeaders = tc.Expectations.WantExactHeaders || golden.MatchHeadersExactly
+	}
+
+	if golden.WantBody != nil {
 		tc.Expectations.Body = golden.WantBody
-		tc.Expectations.WantExactHeaders = golden.MatchHeadersExactly
 		tc.Expectations.WantExactJSONBody = golden.MatchBodyJSONExactly
 	}
-
-	return nil
 }
 
 type jsonTestCase struct {
@@ -1,9 +1,14 @@
 package mt
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"sort"
+	"strings"
 
 	"github.com/jefflinse/melatonin/expect"
 )
@@ -19,6 +24,29 @@ type HTTPTestCaseResult struct {
 	// Body is the HTTP response body.
 	Body []byte `json:"body"`
 
+	// URL is the URL of the request that produced the response, after
+	// following any redirects.
+	URL string `json:"url"`
+
+	// Redirects is the chain of redirects followed to produce the response.
+	Redirects []Redirect `json:"redirects,omitempty"`
+
+	// RedirectLimitExceeded is true if the response is a redirect that wasn't
+	// followed because the test case's maximum number of redirects had
+	// already been followed.
+	RedirectLimitExceeded bool `json:"redirect_limit_exceeded,omitempty"`
+
+	// DecodedBody is the response body decoded by an ExpectBodyAs
+	// expectation, or nil if there is none or the body couldn't be decoded.
+	DecodedBody any `json:"-"`
+
+	// Faults is the list of faults injected while executing the test case.
+	Faults []InjectedFault `json:"faults,omitempty"`
+
+	// TLS is the state of the TLS connection over which the response was
+	// received, or nil if the connection was not encrypted.
+	TLS *tls.ConnectionState `json:"-"`
+
 	testCase *HTTPTestCase
 	failures []error
 }
@@ -50,45 +78,168 @@ func (r *HTTPTestCaseResult) validateExpectations() {
 		}
 	}
 
+	if tc.Expectations.StatusPredicate != nil {
+		if err := checkStatusPredicate(tc.Expectations.StatusPredicate, r.Status); err != nil {
+			r.addFailures(err)
+		}
+	}
+
 	if tc.Expectations.Headers != nil {
-		if errs := compareHeaders(tc.Expectations.Headers, r.Headers); len(errs) > 0 {
+		if errs := compareHeaders(tc.Expectations.Headers, r.Headers, tc.Expectations.WantExactHeaders); len(errs) > 0 {
 			r.addFailures(errs...)
 		}
 	}
 
+	if len(tc.Expectations.HeaderPredicates) > 0 {
+		if errs := checkHeaderPredicates(tc.Expectations.HeaderPredicates, r.Headers); len(errs) > 0 {
+			r.addFailures(errs...)
+		}
+	}
+
+	if len(tc.Expectations.NoHeaders) > 0 {
+		if errs := compareNoHeaders(tc.Expectations.NoHeaders, r.Headers); len(errs) > 0 {
+			r.addFailures(errs...)
+		}
+	}
+
+	if tc.Expectations.WantExactHeaders {
+		if errs := r.findUnexpectedHeaders(tc.tctx.ignoredHeaders()); len(errs) > 0 {
+			r.addFailures(errs...)
+		}
+	}
+
+	if tc.Expectations.RedirectTo != "" {
+		if err := r.compareRedirectTo(tc.Expectations.RedirectTo); err != nil {
+			r.addFailures(err)
+		}
+	}
+
+	if tc.Expectations.RedirectCount != nil {
+		if err := compareRedirectCount(*tc.Expectations.RedirectCount, r.Redirects); err != nil {
+			r.addFailures(err)
+		}
+	}
+
+	if tc.Expectations.TLSVersion != 0 {
+		if err := compareTLSVersion(tc.Expectations.TLSVersion, r.TLS); err != nil {
+			r.addFailures(err)
+		}
+	}
+
+	if tc.Expectations.PeerCertificate != nil {
+		if err := checkPeerCertificate(tc.Expectations.PeerCertificate, r.TLS); err != nil {
+			r.addFailures(err)
+		}
+	}
+
+	if tc.tctx.openAPI != nil {
+		if errs := tc.tctx.openAPI.validate(tc.request, r); len(errs) > 0 {
+			r.addFailures(errs...)
+		}
+	}
+
+	contentType := r.Headers.Get("Content-Type")
+	if tc.Expectations.WantFormBody {
+		contentType = formContentType
+	}
+
+	if tc.Expectations.BodyAs != nil {
+		r.checkBodyAs(contentType, tc.Expectations.BodyAs)
+	}
+
+	if tc.Expectations.Body == nil && tc.Expectations.BodySchema == nil && len(tc.Expectations.Paths) == 0 {
+		return
+	}
+
+	// bodies that fail to decode are compared as they would be without a
+	// registered decoder, so that the expectations report the mismatch
+	body, err := decodeBody(contentType, r.Body)
+	if err != nil {
+		body = toInterface(r.Body)
+	}
+
 	if tc.Expectations.Body != nil {
-		body := toInterface(r.Body)
 		for _, err := range expect.CompareValues(tc.Expectations.Body, body, tc.Expectations.WantExactJSONBody) {
 			err.PushField("") // enables a leading dot in the error message field stack string
 			r.addFailures(err)
 		}
 	}
+
+	if tc.Expectations.BodySchema != nil {
+		for _, err := range expect.CompareValues(tc.Expectations.BodySchema, body, false) {
+			err.PushField("")
+			r.addFailures(err)
+		}
+	}
+
+	for _, path := range tc.Expectations.Paths {
+		for _, err := range path.compare(body) {
+			r.addFailures(err)
+		}
+	}
 }
 
-// Compares a set of expected headers against a set of actual headers,
-func compareHeaders(expected http.Header, actual http.Header) []error {
+// Compares expected headers to actual headers. If exact is true, any values
+// of an expected header that aren't expected are also reported.
+func compareHeaders(expected http.Header, actual http.Header, exact bool) []error {
+	canonical := http.Header{}
+	for key, values := range expected {
+		key = http.CanonicalHeaderKey(key)
+		canonical[key] = append(canonical[key], values...)
+	}
+
 	var errs []error
-	for key, expectedValues := range expected {
+	for _, key := range sortedHeaderKeys(canonical) {
+		expectedValues := canonical[key]
 		actualValues, ok := actual[key]
 		if !ok {
 			errs = append(errs, fmt.Errorf("expected header %q, got nothing", key))
 			continue
 		}
 
+		actualValues = append([]string{}, actualValues...)
 		sort.Strings(expectedValues)
 		sort.Strings(actualValues)
 
 		for _, expectedValue := range expectedValues {
-			found := false
+			if !containsString(actualValues, expectedValue) {
+				errs = append(errs, fmt.Errorf("expected header %q to contain %q, got %q", key, expectedValue, actualValues))
+			}
+		}
+
+		if exact {
 			for _, actualValue := range actualValues {
-				if actualValue == expectedValue {
-					found = true
-					break
+				if !containsString(expectedValues, actualValue) {
+					errs = append(errs, fmt.Errorf("unexpected value %q for header %q", actualValue, key))
 				}
 			}
+		}
+	}
 
-			if !found {
-				errs = append(errs, fmt.Errorf("expected header %q to contain %q, got %q", key, expectedValue, actualValues))
+	return errs
+}
+
+// Runs header predicates against the values of actual headers, joined into a
+// single comma-separated string.
+func checkHeaderPredicates(predicates map[string][]expect.Predicate, actual http.Header) []error {
+	keys := make([]string, 0, len(predicates))
+	for key := range predicates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		values := actual.Values(key)
+		if len(values) == 0 {
+			errs = append(errs, fmt.Errorf("expected header %q, got nothing", key))
+			continue
+		}
+
+		value := strings.Join(values, ", ")
+		for _, predicate := range predicates[key] {
+			if err := predicate(value); err != nil {
+				errs = append(errs, fmt.Errorf("header %q: %w", key, err))
 			}
 		}
 	}
@@ -96,10 +247,159 @@ func compareHeaders(expected http.Header, actual http.Header) []error {
 	return errs
 }
 
+// Verifies that headers expected to be absent aren't present.
+func compareNoHeaders(keys []string, actual http.Header) []error {
+	var errs []error
+	for _, key := range keys {
+		if values := actual.Values(key); len(values) > 0 {
+			errs = append(errs, fmt.Errorf("expected no header %q, got %q", http.CanonicalHeaderKey(key), values))
+		}
+	}
+
+	return errs
+}
+
+// Reports any headers in the result that are neither expected by the test case
+// nor ignored.
+func (r *HTTPTestCaseResult) findUnexpectedHeaders(ignored map[string]bool) []error {
+	expectations := r.testCase.Expectations
+	expected := map[string]bool{}
+	for key := range expectations.Headers {
+		expected[http.CanonicalHeaderKey(key)] = true
+	}
+	for key := range expectations.HeaderPredicates {
+		expected[key] = true
+	}
+
+	var errs []error
+	for _, key := range sortedHeaderKeys(r.Headers) {
+		if !expected[key] && !ignored[key] {
+			errs = append(errs, fmt.Errorf("unexpected header %q: %q", key, r.Headers[key]))
+		}
+	}
+
+	return errs
+}
+
+// headerListContains creates a predicate requiring a comma-separated list to
+// contain each of the given elements, ignoring case and whitespace.
+func headerListContains(elements []string) expect.Predicate {
+	return expect.String().Then(func(actual any) error {
+		members := map[string]bool{}
+		for _, member := range strings.Split(actual.(string), ",") {
+			members[strings.ToLower(strings.TrimSpace(member))] = true
+		}
+
+		var missing []string
+		for _, element := range elements {
+			if !members[strings.ToLower(strings.TrimSpace(element))] {
+				missing = append(missing, element)
+			}
+		}
+
+		if len(missing) > 0 {
+			return fmt.Errorf("expected list to contain %q, got %q", missing, actual)
+		}
+
+		return nil
+	})
+}
+
+func sortedHeaderKeys(h http.Header) []string {
+	keys := make([]string, 0, len(h))
+	for key := range h {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 // Compares an expected status code to an actual status code.
 func compareStatus(expected, actual int) error {
 	if expected != actual {
-		return fmt.Errorf(`expected status %d, got %d`, expected, actual)
+		return fmt.Errorf(`expected status %s, got %s`, statusString(expected), statusString(actual))
+	}
+	return nil
+}
+
+// Runs a status predicate against an actual status code.
+func checkStatusPredicate(predicate expect.Predicate, actual int) error {
+	if err := predicate(actual); err != nil {
+		return fmt.Errorf("unexpected status %s: %w", statusString(actual), err)
+	}
+	return nil
+}
+
+// Compares an expected redirect URL against the redirects followed to produce
+// the result, as well as the result itself.
+func (r *HTTPTestCaseResult) compareRedirectTo(expected string) error {
+	var locations []string
+	for _, redirect := range r.Redirects {
+		if u, err := url.Parse(redirect.Location); err == nil && matchesLocation(expected, u) {
+			return nil
+		}
+		locations = append(locations, redirect.Location)
+	}
+
+	if u := redirectLocation(r.Status, r.Headers, r.URL); u != nil {
+		if matchesLocation(expected, u) {
+			return nil
+		}
+		locations = append(locations, u.String())
+	}
+
+	if len(locations) == 0 {
+		return fmt.Errorf("expected redirect to %q, got no redirects", expected)
+	}
+
+	return fmt.Errorf("expected redirect to %q, got redirects to %q", expected, locations)
+}
+
+// Compares an expected number of redirects to the redirects actually followed.
+func compareRedirectCount(expected int, redirects []Redirect) error {
+	if len(redirects) != expected {
+		return fmt.Errorf("expected %d redirects, got %d", expected, len(redirects))
+	}
+
+	return nil
+}
+
+// Compares an expected TLS version to the version negotiated for a connection.
+func compareTLSVersion(expected uint16, state *tls.ConnectionState) error {
+	if state == nil {
+		return fmt.Errorf("expected %s connection, got plaintext", tlsVersionName(expected))
+	}
+
+	if state.Version != expected {
+		return fmt.Errorf("expected %s connection, got %s", tlsVersionName(expected), tlsVersionName(state.Version))
+	}
+
+	return nil
+}
+
+// Runs a certificate predicate against the leaf certificate presented by the
+// server for a connection.
+func checkPeerCertificate(predicate func(*x509.Certificate) error, state *tls.ConnectionState) error {
+	if state == nil {
+		return errors.New("expected peer certificate, got plaintext connection")
 	}
+
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("expected peer certificate, got nothing")
+	}
+
+	if err := predicate(state.PeerCertificates[0]); err != nil {
+		return fmt.Errorf("peer certificate: %w", err)
+	}
+
 	return nil
 }
@@ -0,0 +1,81 @@
+package mt
+
+import (
+	"fmt"
+
+	"github.com/jefflinse/melatonin/expect"
+	mtjson "github.com/jefflinse/melatonin/json"
+)
+
+// A PathExpectation is an expectation for the values selected from a response
+// body by a JSONPath expression.
+type PathExpectation struct {
+	// Path is the JSONPath expression, such as $.items[?(@.id == 3)].name.
+	Path string
+
+	// Expected is the value compared against each selected value.
+	Expected any
+
+	// MatchAny indicates whether at least one selected value (true) or every
+	// selected value (false) must match the expected value.
+	MatchAny bool
+
+	path *mtjson.Path
+	err  error
+}
+
+// newPathExpectation creates a path expectation, compiling its JSONPath
+// expression.
+func newPathExpectation(path string, expected any, matchAny bool) PathExpectation {
+	e := PathExpectation{Path: path, Expected: expected, MatchAny: matchAny}
+	e.path, e.err = mtjson.CompilePath(path)
+	return e
+}
+
+// compile returns the expectation's compiled JSONPath expression, compiling
+// it if the expectation was created without newPathExpectation.
+func (e PathExpectation) compile() (*mtjson.Path, error) {
+	if e.path != nil || e.err != nil {
+		return e.path, e.err
+	}
+
+	return mtjson.CompilePath(e.Path)
+}
+
+// compare compares the values selected from a decoded response body against
+// the expected value. Failures are reported with the concrete path of each
+// failing value.
+func (e PathExpectation) compare(body any) []error {
+	path, err := e.compile()
+	if err != nil {
+		return []error{err}
+	}
+
+	matches := path.Select(body)
+	if len(matches) == 0 {
+		return []error{fmt.Errorf("expected at least one match for %s, got none", e.Path)}
+	}
+
+	var errs []error
+	for _, match := range matches {
+		matchErrs := expect.CompareValues(e.Expected, match.Value, false)
+		if e.MatchAny && len(matchErrs) == 0 {
+			return nil
+		}
+
+		for _, err := range matchErrs {
+			for i := len(match.Fields) - 1; i >= 0; i-- {
+				err.PushField(match.Fields[i])
+			}
+			err.PushField("$")
+			errs = append(errs, err)
+		}
+	}
+
+	if e.MatchAny {
+		summary := fmt.Errorf("expected at least one of %d matches for %s to satisfy expectation, got none", len(matches), e.Path)
+		errs = append([]error{summary}, errs...)
+	}
+
+	return errs
+}
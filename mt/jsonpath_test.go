@@ -0,0 +1,146 @@
+package mt_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/jefflinse/melatonin/expect"
+	"github.com/jefflinse/melatonin/mt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathExpectations(t *testing.T) {
+	below := func(max float64) expect.Predicate {
+		return expect.Float().Then(func(actual any) error {
+			if actual.(float64) >= max {
+				return fmt.Errorf("expected less than %g, got %g", max, actual)
+			}
+			return nil
+		})
+	}
+
+	for _, test := range []struct {
+		name         string
+		tc           func(*mt.HTTPTestCase) *mt.HTTPTestCase
+		wantRequests int
+		wantFailures []string
+	}{
+		{
+			name: "single value",
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				return tc.ExpectPath("$.total", 3)
+			},
+			wantRequests: 1,
+		},
+		{
+			name: "every value",
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				return tc.ExpectPath("$.items[*].price", below(30))
+			},
+			wantRequests: 1,
+		},
+		{
+			name: "failing values",
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				return tc.ExpectPath("$.items[*].price", below(10))
+			},
+			wantRequests: 1,
+			wantFailures: []string{
+				"$.items[1].price: expected less than 10, got 15",
+				"$.items[2].price: expected less than 10, got 25",
+			},
+		},
+		{
+			name: "filter",
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				return tc.ExpectPath("$.items[?(@.id == 2)].name", "b")
+			},
+			wantRequests: 1,
+		},
+		{
+			name: "nested failure",
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				return tc.ExpectPath("$.items[0]", map[string]any{"name": "z"})
+			},
+			wantRequests: 1,
+			wantFailures: []string{"$.items[0].name: expected z, got a"},
+		},
+		{
+			name: "no matches",
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				return tc.ExpectPath("$.missing", 1)
+			},
+			wantRequests: 1,
+			wantFailures: []string{"expected at least one match for $.missing, got none"},
+		},
+		{
+			name: "any value",
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				return tc.ExpectPathAny("$.items[*].name", "c")
+			},
+			wantRequests: 1,
+		},
+		{
+			name: "no value",
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				return tc.ExpectPathAny("$.items[*].name", "z")
+			},
+			wantRequests: 1,
+			wantFailures: []string{
+				"expected at least one of 3 matches for $.items[*].name to satisfy expectation, got none",
+				"$.items[0].name: expected z, got a",
+				"$.items[1].name: expected z, got b",
+				"$.items[2].name: expected z, got c",
+			},
+		},
+		{
+			name: "any value with no matches",
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				return tc.ExpectPathAny("$.items[?(@.id > 3)]", map[string]any{})
+			},
+			wantRequests: 1,
+			wantFailures: []string{"expected at least one match for $.items[?(@.id > 3)], got none"},
+		},
+		{
+			name: "invalid path",
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				return tc.ExpectPath("$.items[0", 1)
+			},
+			wantFailures: []string{`jsonpath "$.items[0": expected , or ] at position 9`},
+		},
+		{
+			name: "expectation literal",
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				tc.Expectations.Paths = append(tc.Expectations.Paths, mt.PathExpectation{Path: "$.total", Expected: 3})
+				return tc
+			},
+			wantRequests: 1,
+		},
+		{
+			name: "invalid expectation literal",
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				tc.Expectations.Paths = append(tc.Expectations.Paths, mt.PathExpectation{Path: "total", Expected: 3})
+				return tc
+			},
+			wantFailures: []string{`jsonpath "total": expected $ at position 0`},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			requests := 0
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, `{"items": [
+					{"id": 1, "name": "a", "price": 5},
+					{"id": 2, "name": "b", "price": 15},
+					{"id": 3, "name": "c", "price": 25}
+				], "total": 3}`)
+			})
+
+			result := execute(t, test.tc(mt.NewHandlerContext(handler).GET("/")))
+			assert.Equal(t, test.wantFailures, failures(result))
+			assert.Equal(t, test.wantRequests, requests)
+		})
+	}
+}
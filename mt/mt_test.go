@@ -0,0 +1,30 @@
+package mt_test
+
+import (
+	"testing"
+
+	"github.com/jefflinse/melatonin/mt"
+)
+
+// execute executes an HTTP test case and returns its result.
+func execute(t *testing.T, tc *mt.HTTPTestCase) *mt.HTTPTestCaseResult {
+	t.Helper()
+	r := tc.Execute()
+	result, ok := r.(*mt.HTTPTestCaseResult)
+	if !ok {
+		t.Fatalf("expected *mt.HTTPTestCaseResult, got %T", r)
+	}
+
+	return result
+}
+
+// failures returns the messages of a test result's failures, or nil if there
+// are none.
+func failures(r mt.TestResult) []string {
+	var msgs []string
+	for _, err := range r.Failures() {
+		msgs = append(msgs, err.Error())
+	}
+
+	return msgs
+}
@@ -0,0 +1,154 @@
+package mt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+
+	mtjson "github.com/jefflinse/melatonin/json"
+)
+
+// A MultipartBody is a multipart/form-data request body.
+//
+// Pass a MultipartBody to WithBody to send it. The request's Content-Type
+// header is set automatically, including the boundary, unless the test case
+// sets it explicitly.
+type MultipartBody struct {
+	boundary string
+	parts    []multipartPart
+}
+
+type multipartPart struct {
+	header   textproto.MIMEHeader
+	content  any
+	filePath string
+}
+
+// NewMultipartBody creates a new, empty multipart body with a random boundary.
+func NewMultipartBody() *MultipartBody {
+	return &MultipartBody{
+		boundary: multipart.NewWriter(io.Discard).Boundary(),
+	}
+}
+
+// ContentType returns the Content-Type header value for the body.
+func (b *MultipartBody) ContentType() string {
+	return "multipart/form-data; boundary=" + b.boundary
+}
+
+// WithField adds a text field to the body and returns the body. The value may
+// be a deferred value, which is resolved when the test case is executed.
+func (b *MultipartBody) WithField(name string, value any) *MultipartBody {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, escapeQuotes(name)))
+	b.parts = append(b.parts, multipartPart{header: header, content: value})
+	return b
+}
+
+// WithFile adds a file part to the body containing the contents of the file at
+// the given path, and returns the body. Relative paths are resolved against
+// the working directory. The file is read when the test case is executed.
+func (b *MultipartBody) WithFile(name, path string) *MultipartBody {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cfg.WorkingDir, path)
+	}
+
+	header := filePartHeader(name, filepath.Base(path))
+	b.parts = append(b.parts, multipartPart{header: header, filePath: path})
+	return b
+}
+
+// WithFileContent adds a file part to the body with the given file name and
+// in-memory content, and returns the body. The content may be any value
+// accepted by WithBody, including deferred values.
+func (b *MultipartBody) WithFileContent(name, fileName string, content any) *MultipartBody {
+	header := filePartHeader(name, fileName)
+	b.parts = append(b.parts, multipartPart{header: header, content: content})
+	return b
+}
+
+// WithPart adds a part with custom headers to the body and returns the body.
+// The content may be any value accepted by WithBody, including deferred values.
+func (b *MultipartBody) WithPart(header textproto.MIMEHeader, content any) *MultipartBody {
+	b.parts = append(b.parts, multipartPart{header: header, content: content})
+	return b
+}
+
+// encode resolves any deferred values in the body and encodes it.
+func (b *MultipartBody) encode() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+	if err := w.SetBoundary(b.boundary); err != nil {
+		return nil, err
+	}
+
+	for _, part := range b.parts {
+		content, err := part.bytes()
+		if err != nil {
+			return nil, fmt.Errorf("multipart %s: %w", part.header.Get("Content-Disposition"), err)
+		}
+
+		pw, err := w.CreatePart(part.header)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := pw.Write(content); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (p multipartPart) bytes() ([]byte, error) {
+	if p.filePath != "" {
+		return os.ReadFile(p.filePath)
+	}
+
+	resolved, err := mtjson.ResolveDeferred(p.content)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resolved.(type) {
+	case []byte, string, func() []byte, func() ([]byte, error):
+		return toBytes(resolved)
+	}
+
+	// scalar field values are sent as plain text rather than JSON
+	if str, err := paramString(resolved); err == nil {
+		return []byte(str), nil
+	}
+
+	return encodeBody(resolved, lookupEncoder(p.header.Get("Content-Type")))
+}
+
+func filePartHeader(name, fileName string) textproto.MIMEHeader {
+	contentType := mime.TypeByExtension(filepath.Ext(fileName))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		escapeQuotes(name), escapeQuotes(fileName)))
+	header.Set("Content-Type", contentType)
+	return header
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
@@ -0,0 +1,166 @@
+package mt_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	mtjson "github.com/jefflinse/melatonin/json"
+	"github.com/jefflinse/melatonin/mt"
+	"github.com/stretchr/testify/assert"
+)
+
+// multipartEchoHandler responds with the fields and files of a multipart
+// request as JSON.
+func multipartEchoHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fields := map[string]any{}
+	for name, values := range r.MultipartForm.Value {
+		fields[name] = values[0]
+	}
+
+	files := map[string]any{}
+	for name, headers := range r.MultipartForm.File {
+		f, err := headers[0].Open()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		content, _ := io.ReadAll(f)
+		f.Close()
+		files[name] = map[string]any{
+			"filename":     headers[0].Filename,
+			"content_type": headers[0].Header.Get("Content-Type"),
+			"content":      string(content),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"content_type": strings.SplitN(r.Header.Get("Content-Type"), ";", 2)[0],
+		"fields":       fields,
+		"files":        files,
+	})
+}
+
+func TestMultipartBody(t *testing.T) {
+	dir := t.TempDir()
+	notes := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(notes, []byte("some notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	customHeader := textproto.MIMEHeader{}
+	customHeader.Set("Content-Disposition", `form-data; name="custom"; filename="data.bin"`)
+	customHeader.Set("Content-Type", "application/x-custom")
+
+	for _, test := range []struct {
+		name         string
+		body         *mt.MultipartBody
+		wantBody     any
+		wantFailures []string
+	}{
+		{
+			name: "text fields",
+			body: mt.NewMultipartBody().
+				WithField("name", "Ada").
+				WithField("age", 36).
+				WithField("token", mtjson.Defer(func() string { return "deferred" })),
+			wantBody: map[string]any{
+				"content_type": "multipart/form-data",
+				"fields":       map[string]any{"name": "Ada", "age": "36", "token": "deferred"},
+			},
+		},
+		{
+			name: "file from disk",
+			body: mt.NewMultipartBody().WithFile("attachment", notes),
+			wantBody: map[string]any{
+				"files": map[string]any{
+					"attachment": map[string]any{
+						"filename":     "notes.txt",
+						"content_type": "text/plain; charset=utf-8",
+						"content":      "some notes",
+					},
+				},
+			},
+		},
+		{
+			name: "file content encoded by its media type",
+			body: mt.NewMultipartBody().WithFileContent("profile", "profile.json", map[string]any{"name": "Ada"}),
+			wantBody: map[string]any{
+				"files": map[string]any{
+					"profile": map[string]any{
+						"filename":     "profile.json",
+						"content_type": "application/json",
+						"content":      `{"name":"Ada"}`,
+					},
+				},
+			},
+		},
+		{
+			name: "custom part",
+			body: mt.NewMultipartBody().WithPart(customHeader, []byte{'o', 'k'}),
+			wantBody: map[string]any{
+				"files": map[string]any{
+					"custom": map[string]any{
+						"filename":     "data.bin",
+						"content_type": "application/x-custom",
+						"content":      "ok",
+					},
+				},
+			},
+		},
+		{
+			name: "missing file",
+			body: mt.NewMultipartBody().WithFile("attachment", filepath.Join(dir, "missing.txt")),
+			wantFailures: []string{fmt.Sprintf(
+				`request body: multipart form-data; name="attachment"; filename="missing.txt": open %s: no such file or directory`,
+				filepath.Join(dir, "missing.txt"))},
+		},
+		{
+			name:         "deferred value error",
+			body:         mt.NewMultipartBody().WithField("token", func() (any, error) { return nil, errors.New("no token") }),
+			wantFailures: []string{`request body: multipart form-data; name="token": no token`},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := mt.NewHandlerContext(http.HandlerFunc(multipartEchoHandler))
+			tc := ctx.POST("/upload").WithBody(test.body)
+			if test.wantBody != nil {
+				tc.ExpectStatus(http.StatusOK).ExpectBody(test.wantBody)
+			}
+
+			result := execute(t, tc)
+			assert.Equal(t, test.wantFailures, failures(result))
+		})
+	}
+}
+
+func TestMultipartBodyContentType(t *testing.T) {
+	body := mt.NewMultipartBody().WithField("name", "Ada")
+	assert.True(t, strings.HasPrefix(body.ContentType(), "multipart/form-data; boundary="))
+
+	var contentType string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+	})
+
+	execute(t, mt.NewHandlerContext(handler).POST("/").WithBody(body))
+	assert.Equal(t, body.ContentType(), contentType)
+
+	// an explicit Content-Type header is kept
+	execute(t, mt.NewHandlerContext(handler).POST("/").WithHeader("Content-Type", "multipart/mixed").WithBody(body))
+	assert.Equal(t, "multipart/mixed", contentType)
+}
@@ -0,0 +1,549 @@
+package mt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jefflinse/melatonin/expect"
+	mtjson "github.com/jefflinse/melatonin/json"
+	"gopkg.in/yaml.v3"
+)
+
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// openAPISpec validates the requests and responses of a context's test cases
+// against an OpenAPI 3 document.
+type openAPISpec struct {
+	path             string
+	validateRequests bool
+
+	once       sync.Once
+	err        error
+	basePaths  []string
+	operations []*openAPIOperation
+}
+
+// An openAPIOperation is an operation declared by a spec, along with the
+// compiled schemas of its request and responses.
+type openAPIOperation struct {
+	method      string
+	template    string
+	pattern     *regexp.Regexp
+	literals    int
+	requestBody *openAPIBody
+	responses   map[string]*openAPIResponse
+}
+
+type openAPIResponse struct {
+	headers []string
+	body    *openAPIBody
+}
+
+// An openAPIBody maps the media types of a request or response body to their
+// schemas. A media type declared without a schema has a nil schema.
+type openAPIBody struct {
+	required bool
+	content  map[string]*mtjson.Schema
+}
+
+// load parses and compiles the spec on first use.
+func (s *openAPISpec) load() error {
+	s.once.Do(func() {
+		s.err = s.parse()
+	})
+
+	return s.err
+}
+
+func (s *openAPISpec) parse() error {
+	if s.path == "" {
+		return errors.New("openapi: no spec path specified")
+	}
+
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("openapi %q: %w", s.path, err)
+	}
+
+	var doc any
+	switch strings.ToLower(filepath.Ext(s.path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &doc)
+		doc = normalizeYAML(doc)
+	default:
+		err = json.Unmarshal(b, &doc)
+	}
+	if err != nil {
+		return fmt.Errorf("openapi %q: %w", s.path, err)
+	}
+
+	root, ok := doc.(map[string]any)
+	if !ok {
+		return fmt.Errorf("openapi %q: expected object, got %T", s.path, doc)
+	}
+
+	version, _ := root["openapi"].(string)
+	if !strings.HasPrefix(version, "3.") {
+		return fmt.Errorf("openapi %q: unsupported version %q", s.path, version)
+	}
+
+	if strings.HasPrefix(version, "3.0.") {
+		convertOpenAPI30Schemas(root)
+	}
+
+	schema, err := mtjson.CompileSchema(root, filepath.Dir(s.path))
+	if err != nil {
+		return fmt.Errorf("openapi %q: %w", s.path, err)
+	}
+
+	// index the shared schemas, which aren't reachable as subschemas of the
+	// document itself
+	if components, ok := root["components"].(map[string]any); ok {
+		if schemas, ok := components["schemas"].(map[string]any); ok {
+			for name := range schemas {
+				if _, err := schema.Subschema("/components/schemas/" + escapePointerToken(name)); err != nil {
+					return fmt.Errorf("openapi %q: %w", s.path, err)
+				}
+			}
+		}
+	}
+
+	s.basePaths = openAPIBasePaths(root)
+
+	paths, _ := root["paths"].(map[string]any)
+	for _, template := range sortedMapKeys(paths) {
+		item, itemPointer := resolveOpenAPIRef(root, paths[template], "/paths/"+escapePointerToken(template))
+		for _, method := range openAPIMethods {
+			op, ok := item[method].(map[string]any)
+			if !ok {
+				continue
+			}
+
+			operation, err := newOpenAPIOperation(root, schema, strings.ToUpper(method), template, op, itemPointer+"/"+method)
+			if err != nil {
+				return fmt.Errorf("openapi %q: %s %s: %w", s.path, strings.ToUpper(method), template, err)
+			}
+			s.operations = append(s.operations, operation)
+		}
+	}
+
+	return nil
+}
+
+func newOpenAPIOperation(root map[string]any, schema *mtjson.Schema, method, template string, op map[string]any, pointer string) (*openAPIOperation, error) {
+	pattern, literals := compilePathTemplate(template)
+	operation := &openAPIOperation{
+		method:    method,
+		template:  template,
+		pattern:   pattern,
+		literals:  literals,
+		responses: map[string]*openAPIResponse{},
+	}
+
+	if _, ok := op["requestBody"]; ok {
+		body, bodyPointer := resolveOpenAPIRef(root, op["requestBody"], pointer+"/requestBody")
+		content, err := compileOpenAPIContent(schema, body, bodyPointer)
+		if err != nil {
+			return nil, err
+		}
+
+		required, _ := body["required"].(bool)
+		operation.requestBody = &openAPIBody{required: required, content: content}
+	}
+
+	responses, _ := op["responses"].(map[string]any)
+	for status := range responses {
+		resp, respPointer := resolveOpenAPIRef(root, responses[status], pointer+"/responses/"+escapePointerToken(status))
+		response := &openAPIResponse{}
+
+		headers, _ := resp["headers"].(map[string]any)
+		for _, name := range sortedMapKeys(headers) {
+			header, _ := resolveOpenAPIRef(root, headers[name], "")
+			if required, _ := header["required"].(bool); required && !strings.EqualFold(name, "Content-Type") {
+				response.headers = append(response.headers, http.CanonicalHeaderKey(name))
+			}
+		}
+
+		if _, ok := resp["content"]; ok {
+			content, err := compileOpenAPIContent(schema, resp, respPointer)
+			if err != nil {
+				return nil, err
+			}
+			response.body = &openAPIBody{content: content}
+		}
+
+		operation.responses[strings.ToUpper(status)] = response
+	}
+
+	return operation, nil
+}
+
+// compileOpenAPIContent compiles the schemas of the media types declared by
+// a request body or response object.
+func compileOpenAPIContent(schema *mtjson.Schema, object map[string]any, pointer string) (map[string]*mtjson.Schema, error) {
+	content := map[string]*mtjson.Schema{}
+	media, _ := object["content"].(map[string]any)
+	for mediaType, v := range media {
+		key := strings.ToLower(mediaType)
+		if parsed, _, err := mime.ParseMediaType(mediaType); err == nil {
+			key = parsed
+		}
+
+		content[key] = nil
+		if m, ok := v.(map[string]any); ok {
+			if _, ok := m["schema"]; ok {
+				sub, err := schema.Subschema(pointer + "/content/" + escapePointerToken(mediaType) + "/schema")
+				if err != nil {
+					return nil, err
+				}
+				content[key] = sub
+			}
+		}
+	}
+
+	return content, nil
+}
+
+// find returns the operation matching a request method and path. Paths are
+// matched relative to each server URL, and templates with more literal
+// segments take precedence.
+func (s *openAPISpec) find(method, path string) *openAPIOperation {
+	for _, base := range s.basePaths {
+		if !strings.HasPrefix(path, base) {
+			continue
+		}
+
+		rel := path[len(base):]
+		if rel == "" {
+			rel = "/"
+		} else if rel[0] != '/' {
+			continue
+		}
+
+		var found *openAPIOperation
+		for _, op := range s.operations {
+			if op.method == method && op.pattern.MatchString(rel) && (found == nil || op.literals > found.literals) {
+				found = op
+			}
+		}
+
+		if found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+// response returns the declared response for a status code, falling back to
+// its status class, such as 4XX, and then the default response.
+func (op *openAPIOperation) response(status int) *openAPIResponse {
+	for _, key := range []string{strconv.Itoa(status), fmt.Sprintf("%dXX", status/100), "DEFAULT"} {
+		if resp, ok := op.responses[key]; ok {
+			return resp
+		}
+	}
+
+	return nil
+}
+
+// validate validates a test case's request and response against the spec.
+func (s *openAPISpec) validate(req *http.Request, r *HTTPTestCaseResult) []error {
+	if err := s.load(); err != nil {
+		return []error{err}
+	}
+
+	op := s.find(req.Method, req.URL.Path)
+	if op == nil {
+		return []error{fmt.Errorf("openapi: undocumented operation %s %s", req.Method, req.URL.Path)}
+	}
+
+	var errs []error
+	if s.validateRequests {
+		errs = append(errs, op.validateRequest(req)...)
+	}
+
+	resp := op.response(r.Status)
+	if resp == nil {
+		return append(errs, fmt.Errorf("openapi %s %s: undocumented response status %s", op.method, op.template, statusString(r.Status)))
+	}
+
+	prefix := fmt.Sprintf("openapi %s %s %d response", op.method, op.template, r.Status)
+	for _, key := range resp.headers {
+		if len(r.Headers.Values(key)) == 0 {
+			errs = append(errs, fmt.Errorf("%s: missing required header %q", prefix, key))
+		}
+	}
+
+	if resp.body != nil && req.Method != http.MethodHead {
+		errs = append(errs, resp.body.validate(prefix, r.Headers.Get("Content-Type"), r.Body)...)
+	}
+
+	return errs
+}
+
+func (op *openAPIOperation) validateRequest(req *http.Request) []error {
+	if op.requestBody == nil {
+		return nil
+	}
+
+	// streamed bodies can't be read again after being sent
+	if req.GetBody == nil && req.Body != nil && req.Body != http.NoBody {
+		return nil
+	}
+
+	prefix := fmt.Sprintf("openapi %s %s request", op.method, op.template)
+	body, err := readRequestBody(req)
+	if err != nil {
+		return []error{fmt.Errorf("%s: %w", prefix, err)}
+	}
+
+	if len(body) == 0 {
+		if op.requestBody.required {
+			return []error{fmt.Errorf("%s: expected body, got none", prefix)}
+		}
+		return nil
+	}
+
+	return op.requestBody.validate(prefix, req.Header.Get("Content-Type"), body)
+}
+
+// validate validates a body against the schema of its media type.
+func (b *openAPIBody) validate(prefix, contentType string, body []byte) []error {
+	if len(b.content) == 0 {
+		return nil
+	}
+
+	schema, ok := b.match(contentType)
+	if !ok {
+		if len(body) == 0 {
+			return []error{fmt.Errorf("%s: expected body, got none", prefix)}
+		}
+		return []error{fmt.Errorf("%s: undocumented content type %q", prefix, contentType)}
+	}
+
+	if schema == nil {
+		return nil
+	}
+
+	var v any
+	if len(body) > 0 {
+		if decoder := lookupDecoder(contentType); decoder != nil {
+			decoded, err := decoder.Decode(body)
+			if err != nil {
+				return []error{fmt.Errorf("%s: failed to decode %s body: %w", prefix, contentType, err)}
+			}
+			v = normalizeDecoded(decoded)
+		} else {
+			v = toInterface(body)
+		}
+	}
+
+	var errs []error
+	for _, err := range expect.CompareValues(expect.Schema(schema), v, false) {
+		err.PushField("")
+		errs = append(errs, fmt.Errorf("%s: %w", prefix, err))
+	}
+
+	return errs
+}
+
+// match returns the schema for a content type, preferring an exact media
+// type over a type/* range, and a type/* range over */*. Bodies sent without
+// a content type match the only declared media type, if there is just one.
+func (b *openAPIBody) match(contentType string) (*mtjson.Schema, bool) {
+	if contentType == "" && len(b.content) == 1 {
+		for _, schema := range b.content {
+			return schema, true
+		}
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(contentType)
+	}
+
+	candidates := []string{mediaType}
+	if i := strings.Index(mediaType, "/"); i >= 0 {
+		candidates = append(candidates, mediaType[:i]+"/*")
+	}
+	candidates = append(candidates, "*/*")
+
+	for _, key := range candidates {
+		if schema, ok := b.content[key]; ok {
+			return schema, true
+		}
+	}
+
+	return nil, false
+}
+
+// compilePathTemplate compiles a path template, such as /users/{id}, to a
+// regular expression matching request paths, and counts its literal segments.
+func compilePathTemplate(template string) (*regexp.Regexp, int) {
+	template = "/" + strings.Trim(template, "/")
+	var sb strings.Builder
+	sb.WriteString("^")
+	literals := 0
+	for _, segment := range strings.Split(template[1:], "/") {
+		sb.WriteString("/")
+		if !strings.Contains(segment, "{") {
+			literals++
+		}
+
+		for segment != "" {
+			start := strings.Index(segment, "{")
+			end := strings.Index(segment, "}")
+			if start < 0 || end < start {
+				sb.WriteString(regexp.QuoteMeta(segment))
+				break
+			}
+
+			sb.WriteString(regexp.QuoteMeta(segment[:start]))
+			sb.WriteString("[^/]+")
+			segment = segment[end+1:]
+		}
+	}
+	sb.WriteString("/?$")
+
+	return regexp.MustCompile(sb.String()), literals
+}
+
+// openAPIBasePaths returns the paths of a spec's server URLs, longest first,
+// followed by the root path.
+func openAPIBasePaths(root map[string]any) []string {
+	var paths []string
+	servers, _ := root["servers"].([]any)
+	for _, server := range servers {
+		m, _ := server.(map[string]any)
+		rawURL, _ := m["url"].(string)
+		variables, _ := m["variables"].(map[string]any)
+		for name, v := range variables {
+			variable, _ := v.(map[string]any)
+			def, _ := variable["default"].(string)
+			rawURL = strings.ReplaceAll(rawURL, "{"+name+"}", def)
+		}
+
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			continue
+		}
+
+		if path := strings.TrimSuffix(u.Path, "/"); path != "" {
+			paths = append(paths, path)
+		}
+	}
+
+	sort.SliceStable(paths, func(i, j int) bool {
+		return len(paths[i]) > len(paths[j])
+	})
+
+	return append(paths, "")
+}
+
+// resolveOpenAPIRef follows local references to reusable objects, such as
+// #/components/responses/notFound, returning the referenced object and its
+// JSON pointer.
+func resolveOpenAPIRef(root map[string]any, v any, pointer string) (map[string]any, string) {
+	for i := 0; i < 32; i++ {
+		m, _ := v.(map[string]any)
+		ref, ok := m["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#/") {
+			return m, pointer
+		}
+
+		pointer = ref[1:]
+		v = root
+		for _, token := range strings.Split(ref[2:], "/") {
+			token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+			parent, _ := v.(map[string]any)
+			v = parent[token]
+		}
+	}
+
+	return nil, pointer
+}
+
+// convertOpenAPI30Schemas rewrites the OpenAPI 3.0 schema keywords that differ
+// from JSON Schema 2020-12, namely nullable and the boolean forms of
+// exclusiveMinimum and exclusiveMaximum.
+func convertOpenAPI30Schemas(v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		if nullable, ok := v["nullable"].(bool); ok {
+			if t, ok := v["type"].(string); ok && nullable {
+				v["type"] = []any{t, "null"}
+			}
+			delete(v, "nullable")
+		}
+
+		for exclusive, limit := range map[string]string{"exclusiveMinimum": "minimum", "exclusiveMaximum": "maximum"} {
+			if b, ok := v[exclusive].(bool); ok {
+				delete(v, exclusive)
+				if b {
+					if n, ok := v[limit]; ok {
+						v[exclusive] = n
+						delete(v, limit)
+					}
+				}
+			}
+		}
+
+		for _, child := range v {
+			convertOpenAPI30Schemas(child)
+		}
+	case []any:
+		for _, child := range v {
+			convertOpenAPI30Schemas(child)
+		}
+	}
+}
+
+// normalizeYAML converts the maps decoded from YAML with non-string keys, such
+// as response status codes, to maps with string keys.
+func normalizeYAML(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			v[k] = normalizeYAML(child)
+		}
+		return v
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, child := range v {
+			m[fmt.Sprint(k)] = normalizeYAML(child)
+		}
+		return m
+	case []any:
+		for i, child := range v {
+			v[i] = normalizeYAML(child)
+		}
+		return v
+	}
+
+	return v
+}
+
+func escapePointerToken(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+func sortedMapKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
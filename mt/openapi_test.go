@@ -0,0 +1,261 @@
+package mt_test
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jefflinse/melatonin/mt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPI(t *testing.T) {
+	for _, test := range []struct {
+		name             string
+		validateRequests bool
+		tc               func(*mt.HTTPTestContext) *mt.HTTPTestCase
+		status           int
+		header           http.Header
+		body             string
+		wantFailures     []string
+	}{
+		{
+			name: "documented response",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/v1/users/7")
+			},
+			status: http.StatusOK,
+			header: http.Header{"Content-Type": {"application/json"}},
+			body:   `{"id": 7, "name": "Ada", "email": null}`,
+		},
+		{
+			name: "response body violations",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/v1/users/7")
+			},
+			status: http.StatusOK,
+			header: http.Header{"Content-Type": {"application/json"}},
+			body:   `{"id": 0, "email": 1}`,
+			wantFailures: []string{
+				"openapi GET /users/{id} 200 response: .email: expected type string or null, got integer",
+				"openapi GET /users/{id} 200 response: .id: expected more than 0, got 0",
+				"openapi GET /users/{id} 200 response: .name: expected required field, got nothing",
+			},
+		},
+		{
+			name: "literal path segments take precedence",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/v1/users/me")
+			},
+			status: http.StatusOK,
+			header: http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+			body:   "Ada",
+		},
+		{
+			name: "default response",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/v1/users/7")
+			},
+			status: http.StatusNotFound,
+			header: http.Header{"Content-Type": {"application/json"}},
+			body:   `{"message": "not found"}`,
+		},
+		{
+			name: "status class response",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/v1/users")
+			},
+			status:       http.StatusBadRequest,
+			header:       http.Header{"Content-Type": {"application/json"}},
+			body:         `{"error": "bad request"}`,
+			wantFailures: []string{"openapi POST /users 400 response: .message: expected required field, got nothing"},
+		},
+		{
+			name: "undocumented response status",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/v1/users")
+			},
+			status:       http.StatusInternalServerError,
+			wantFailures: []string{"openapi POST /users: undocumented response status 500 Internal Server Error"},
+		},
+		{
+			name: "missing required header",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/v1/users")
+			},
+			status:       http.StatusCreated,
+			header:       http.Header{"Content-Type": {"application/json"}},
+			body:         `{"id": 1, "name": "Ada"}`,
+			wantFailures: []string{`openapi POST /users 201 response: missing required header "Location"`},
+		},
+		{
+			name: "undocumented content type",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/v1/users/7")
+			},
+			status:       http.StatusOK,
+			header:       http.Header{"Content-Type": {"text/html"}},
+			body:         "<p>Ada</p>",
+			wantFailures: []string{`openapi GET /users/{id} 200 response: undocumented content type "text/html"`},
+		},
+		{
+			name: "undecodable body",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/v1/users/7")
+			},
+			status:       http.StatusOK,
+			header:       http.Header{"Content-Type": {"application/json"}},
+			body:         `{"id": 7`,
+			wantFailures: []string{"openapi GET /users/{id} 200 response: failed to decode application/json body: unexpected end of JSON input"},
+		},
+		{
+			name: "undocumented operation",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.DELETE("/v1/users/7")
+			},
+			status:       http.StatusNoContent,
+			wantFailures: []string{"openapi: undocumented operation DELETE /v1/users/7"},
+		},
+		{
+			name: "outside of server path",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/v2/users/7")
+			},
+			status:       http.StatusOK,
+			wantFailures: []string{"openapi: undocumented operation GET /v2/users/7"},
+		},
+		{
+			name:             "valid request body",
+			validateRequests: true,
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/v1/users").WithBody(map[string]any{"name": "Ada"})
+			},
+			status: http.StatusCreated,
+			header: http.Header{"Content-Type": {"application/json"}, "Location": {"/v1/users/1"}},
+			body:   `{"id": 1, "name": "Ada"}`,
+		},
+		{
+			name:             "request body violation",
+			validateRequests: true,
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/v1/users").WithBody(map[string]any{"name": 1})
+			},
+			status:       http.StatusBadRequest,
+			header:       http.Header{"Content-Type": {"application/json"}},
+			body:         `{"message": "invalid name"}`,
+			wantFailures: []string{"openapi POST /users request: .name: expected type string, got integer"},
+		},
+		{
+			name:             "missing request body",
+			validateRequests: true,
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/v1/users")
+			},
+			status:       http.StatusBadRequest,
+			header:       http.Header{"Content-Type": {"application/json"}},
+			body:         `{"message": "missing body"}`,
+			wantFailures: []string{"openapi POST /users request: expected body, got none"},
+		},
+		{
+			name:             "streamed request body",
+			validateRequests: true,
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/v1/users").WithBody(strings.NewReader(`{"name": 1}`))
+			},
+			status: http.StatusBadRequest,
+			header: http.Header{"Content-Type": {"application/json"}},
+			body:   `{"message": "invalid name"}`,
+		},
+		{
+			name: "request body not validated",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/v1/users").WithBody(map[string]any{"name": 1})
+			},
+			status: http.StatusBadRequest,
+			header: http.Header{"Content-Type": {"application/json"}},
+			body:   `{"message": "invalid name"}`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for key, values := range test.header {
+					w.Header()[key] = values
+				}
+				w.WriteHeader(test.status)
+				fmt.Fprint(w, test.body)
+			})
+
+			ctx := mt.NewHandlerContext(handler).
+				WithOpenAPI("testdata/openapi.yaml").
+				WithOpenAPIRequestValidation(test.validateRequests)
+
+			result := execute(t, test.tc(ctx))
+			assert.Equal(t, test.wantFailures, failures(result))
+		})
+	}
+}
+
+func TestOpenAPISpecErrors(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"swagger.json": `{"swagger": "2.0"}`,
+		"array.json":   `[]`,
+		"invalid.yaml": "openapi: [3.1.0",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		name         string
+		ctx          func(*mt.HTTPTestContext) *mt.HTTPTestContext
+		wantFailures []string
+	}{
+		{
+			name: "missing spec",
+			ctx: func(ctx *mt.HTTPTestContext) *mt.HTTPTestContext {
+				return ctx.WithOpenAPI(filepath.Join(dir, "missing.json"))
+			},
+			wantFailures: []string{fmt.Sprintf("openapi %[1]q: open %[1]s: no such file or directory", filepath.Join(dir, "missing.json"))},
+		},
+		{
+			name: "unsupported version",
+			ctx: func(ctx *mt.HTTPTestContext) *mt.HTTPTestContext {
+				return ctx.WithOpenAPI(filepath.Join(dir, "swagger.json"))
+			},
+			wantFailures: []string{fmt.Sprintf(`openapi %q: unsupported version ""`, filepath.Join(dir, "swagger.json"))},
+		},
+		{
+			name: "not an object",
+			ctx: func(ctx *mt.HTTPTestContext) *mt.HTTPTestContext {
+				return ctx.WithOpenAPI(filepath.Join(dir, "array.json"))
+			},
+			wantFailures: []string{fmt.Sprintf("openapi %q: expected object, got []interface {}", filepath.Join(dir, "array.json"))},
+		},
+		{
+			name: "invalid YAML",
+			ctx: func(ctx *mt.HTTPTestContext) *mt.HTTPTestContext {
+				return ctx.WithOpenAPI(filepath.Join(dir, "invalid.yaml"))
+			},
+			wantFailures: []string{fmt.Sprintf("openapi %q: yaml: line 1: did not find expected ',' or ']'", filepath.Join(dir, "invalid.yaml"))},
+		},
+		{
+			name: "request validation without a spec",
+			ctx: func(ctx *mt.HTTPTestContext) *mt.HTTPTestContext {
+				return ctx.WithOpenAPIRequestValidation(true)
+			},
+			wantFailures: []string{"openapi: no spec path specified"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := test.ctx(mt.NewHandlerContext(http.NotFoundHandler()))
+			result := execute(t, ctx.GET("/"))
+			assert.Equal(t, test.wantFailures, failures(result))
+		})
+	}
+}
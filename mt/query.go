@@ -0,0 +1,276 @@
+package mt
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+
+	mtjson "github.com/jefflinse/melatonin/json"
+)
+
+// A QueryStyle determines how a query parameter value is serialized. The
+// styles correspond to those defined by OpenAPI 3.
+type QueryStyle int
+
+const (
+	// QueryStyleForm serializes slices as comma-separated values, such as
+	// tag=a,b, and maps as comma-separated keys and values, such as
+	// filter=color,red,size,10. This is the default for values other than maps.
+	QueryStyleForm QueryStyle = iota
+
+	// QueryStyleFormExploded repeats the parameter for each element of a
+	// slice, such as tag=a&tag=b, and serializes maps as separate
+	// parameters, such as color=red&size=10.
+	QueryStyleFormExploded
+
+	// QueryStyleSpaceDelimited serializes slices as space-separated values,
+	// such as tag=a%20b.
+	QueryStyleSpaceDelimited
+
+	// QueryStylePipeDelimited serializes slices as pipe-separated values,
+	// such as tag=a|b.
+	QueryStylePipeDelimited
+
+	// QueryStyleDeepObject serializes nested maps and slices using brackets
+	// around keys and indices, such as filter[color]=red&filter[tags][0]=a.
+	// This is the default for maps.
+	QueryStyleDeepObject
+
+	// QueryStyleBrackets serializes slices using empty brackets, such as
+	// tag[]=a&tag[]=b, and nested maps like QueryStyleDeepObject.
+	QueryStyleBrackets
+)
+
+// A StyledValue is a query parameter value with an explicit serialization
+// style.
+type StyledValue struct {
+	Value any
+	Style QueryStyle
+}
+
+// Styled returns a query parameter value that is serialized using the given
+// style. The value may be a deferred value.
+func Styled(value any, style QueryStyle) StyledValue {
+	return StyledValue{Value: value, Style: style}
+}
+
+// asRawQuery resolves the values and encodes them as a query string.
+// Parameters are encoded in sorted order.
+func (p parameters) asRawQuery() (string, error) {
+	resolved, err := mtjson.ResolveDeferred(map[string]any(p))
+	if err != nil {
+		return "", err
+	}
+
+	values := resolved.(map[string]any)
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, k := range keys {
+		encoded, err := encodeQueryParam(k, values[k])
+		if err != nil {
+			return "", fmt.Errorf("query parameter %q: %w", k, err)
+		}
+		pairs = append(pairs, encoded...)
+	}
+
+	return strings.Join(pairs, "&"), nil
+}
+
+// encodeQueryParam encodes a query parameter as one or more key=value pairs.
+func encodeQueryParam(key string, value any) ([]string, error) {
+	style := QueryStyleForm
+	if styled, ok := value.(StyledValue); ok {
+		value, style = styled.Value, styled.Style
+	} else if reflect.ValueOf(value).Kind() == reflect.Map {
+		style = QueryStyleDeepObject
+	}
+
+	escapedKey := url.QueryEscape(key)
+	switch style {
+	case QueryStyleForm, QueryStyleSpaceDelimited, QueryStylePipeDelimited:
+		elements, err := flattenQueryValue(value)
+		if err != nil {
+			return nil, err
+		}
+
+		delimiter := map[QueryStyle]string{
+			QueryStyleForm:           ",",
+			QueryStyleSpaceDelimited: "%20",
+			QueryStylePipeDelimited:  "|",
+		}[style]
+
+		for i := range elements {
+			elements[i] = url.QueryEscape(elements[i])
+		}
+		return []string{escapedKey + "=" + strings.Join(elements, delimiter)}, nil
+
+	case QueryStyleFormExploded:
+		rv := reflect.ValueOf(value)
+		if rv.Kind() == reflect.Map {
+			keys, values, err := sortedMapEntries(rv)
+			if err != nil {
+				return nil, err
+			}
+
+			pairs := make([]string, len(keys))
+			for i := range keys {
+				str, err := paramString(values[i])
+				if err != nil {
+					return nil, err
+				}
+				pairs[i] = url.QueryEscape(keys[i]) + "=" + url.QueryEscape(str)
+			}
+			return pairs, nil
+		}
+
+		elements, err := flattenQueryValue(value)
+		if err != nil {
+			return nil, err
+		}
+
+		pairs := make([]string, len(elements))
+		for i := range elements {
+			pairs[i] = escapedKey + "=" + url.QueryEscape(elements[i])
+		}
+		return pairs, nil
+
+	case QueryStyleDeepObject, QueryStyleBrackets:
+		return encodeNestedQueryValue(escapedKey, value, style)
+
+	default:
+		return nil, fmt.Errorf("unknown query style %d", style)
+	}
+}
+
+// flattenQueryValue converts a scalar to a single string, a slice to one
+// string per element, and a map to alternating keys and values.
+func flattenQueryValue(value any) ([]string, error) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Map:
+		keys, values, err := sortedMapEntries(rv)
+		if err != nil {
+			return nil, err
+		}
+
+		elements := make([]string, 0, 2*len(keys))
+		for i := range keys {
+			str, err := paramString(values[i])
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, keys[i], str)
+		}
+		return elements, nil
+
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+
+		elements := make([]string, rv.Len())
+		for i := range elements {
+			str, err := paramString(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = str
+		}
+		return elements, nil
+	}
+
+	str, err := paramString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{str}, nil
+}
+
+// encodeNestedQueryValue encodes maps and slices using bracketed keys,
+// recursively.
+func encodeNestedQueryValue(prefix string, value any, style QueryStyle) ([]string, error) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Map:
+		keys, values, err := sortedMapEntries(rv)
+		if err != nil {
+			return nil, err
+		}
+
+		var pairs []string
+		for i := range keys {
+			encoded, err := encodeNestedQueryValue(prefix+"["+url.QueryEscape(keys[i])+"]", values[i], style)
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, encoded...)
+		}
+		return pairs, nil
+
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+
+		var pairs []string
+		for i := 0; i < rv.Len(); i++ {
+			index := "[]"
+			if style == QueryStyleDeepObject {
+				index = fmt.Sprintf("[%d]", i)
+			}
+
+			encoded, err := encodeNestedQueryValue(prefix+index, rv.Index(i).Interface(), style)
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, encoded...)
+		}
+		return pairs, nil
+	}
+
+	str, err := paramString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{prefix + "=" + url.QueryEscape(str)}, nil
+}
+
+// sortedMapEntries returns the keys of a map as strings, and the corresponding
+// values, in sorted key order.
+func sortedMapEntries(rv reflect.Value) ([]string, []any, error) {
+	type entry struct {
+		key   string
+		value any
+	}
+
+	entries := make([]entry, 0, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		key, err := paramString(iter.Key().Interface())
+		if err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, entry{key, iter.Value().Interface()})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+
+	keys := make([]string, len(entries))
+	values := make([]any, len(entries))
+	for i, e := range entries {
+		keys[i], values[i] = e.key, e.value
+	}
+
+	return keys, values, nil
+}
@@ -0,0 +1,129 @@
+package mt_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	mtjson "github.com/jefflinse/melatonin/json"
+	"github.com/jefflinse/melatonin/mt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryParams(t *testing.T) {
+	tags := []string{"a", "b"}
+	filter := map[string]any{"color": "red", "size": 10}
+
+	for _, test := range []struct {
+		name         string
+		path         string
+		params       map[string]any
+		wantQuery    string
+		wantFailures []string
+	}{
+		{
+			name:      "scalars in sorted order",
+			path:      "/",
+			params:    map[string]any{"q": "a b&c", "limit": 10, "exact": true},
+			wantQuery: "exact=true&limit=10&q=a+b%26c",
+		},
+		{
+			name:      "kept after query on the path",
+			path:      "/?limit=10",
+			params:    map[string]any{"offset": 20},
+			wantQuery: "limit=10&offset=20",
+		},
+		{
+			name:      "slice defaults to form",
+			path:      "/",
+			params:    map[string]any{"tag": tags},
+			wantQuery: "tag=a,b",
+		},
+		{
+			name:      "map defaults to deep object",
+			path:      "/",
+			params:    map[string]any{"filter": map[string]any{"color": "red", "tags": []string{"a"}}},
+			wantQuery: "filter[color]=red&filter[tags][0]=a",
+		},
+		{
+			name:      "form map",
+			path:      "/",
+			params:    map[string]any{"filter": mt.Styled(filter, mt.QueryStyleForm)},
+			wantQuery: "filter=color,red,size,10",
+		},
+		{
+			name:      "exploded form slice",
+			path:      "/",
+			params:    map[string]any{"tag": mt.Styled(tags, mt.QueryStyleFormExploded)},
+			wantQuery: "tag=a&tag=b",
+		},
+		{
+			name:      "exploded form map",
+			path:      "/",
+			params:    map[string]any{"filter": mt.Styled(filter, mt.QueryStyleFormExploded)},
+			wantQuery: "color=red&size=10",
+		},
+		{
+			name:      "space delimited",
+			path:      "/",
+			params:    map[string]any{"tag": mt.Styled(tags, mt.QueryStyleSpaceDelimited)},
+			wantQuery: "tag=a%20b",
+		},
+		{
+			name:      "pipe delimited",
+			path:      "/",
+			params:    map[string]any{"tag": mt.Styled(tags, mt.QueryStylePipeDelimited)},
+			wantQuery: "tag=a|b",
+		},
+		{
+			name:      "brackets",
+			path:      "/",
+			params:    map[string]any{"tag": mt.Styled(tags, mt.QueryStyleBrackets)},
+			wantQuery: "tag[]=a&tag[]=b",
+		},
+		{
+			name:      "nested brackets",
+			path:      "/",
+			params:    map[string]any{"f": mt.Styled(map[string]any{"tags": tags}, mt.QueryStyleBrackets)},
+			wantQuery: "f[tags][]=a&f[tags][]=b",
+		},
+		{
+			name:      "deferred styled value",
+			path:      "/",
+			params:    map[string]any{"tag": mt.Styled(mtjson.Defer(func() []string { return tags }), mt.QueryStylePipeDelimited)},
+			wantQuery: "tag=a|b",
+		},
+		{
+			name:         "unknown style",
+			path:         "/",
+			params:       map[string]any{"tag": mt.Styled(tags, mt.QueryStyle(99))},
+			wantFailures: []string{`query parameter "tag": unknown query style 99`},
+		},
+		{
+			name:         "unsupported value",
+			path:         "/",
+			params:       map[string]any{"tag": []any{struct{}{}}},
+			wantFailures: []string{`query parameter "tag": unsupported parameter type: struct {}`},
+		},
+		{
+			name:         "deferred value error",
+			path:         "/",
+			params:       map[string]any{"tag": func() (any, error) { return nil, errors.New("no tag") }},
+			wantFailures: []string{"tag: no tag"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, r.URL.RawQuery)
+			})
+
+			tc := mt.NewHandlerContext(handler).GET(test.path).WithQueryParams(test.params)
+			result := execute(t, tc)
+			assert.Equal(t, test.wantFailures, failures(result))
+			if test.wantFailures == nil {
+				assert.Equal(t, test.wantQuery, string(result.Body))
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package mt
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const (
+	// NoRedirects causes redirect responses to be returned as-is without
+	// being followed.
+	NoRedirects = -1
+
+	// FollowRedirects causes up to 10 redirects to be followed, the same limit
+	// used by the default http.Client.
+	FollowRedirects = 10
+)
+
+// A Redirect describes a single redirect followed while executing a test case.
+type Redirect struct {
+	// Status is the status code of the redirect response.
+	Status int `json:"status"`
+
+	// URL is the URL of the request that was redirected.
+	URL string `json:"url"`
+
+	// Location is the URL the request was redirected to.
+	Location string `json:"location"`
+}
+
+// newRedirectPolicy creates a redirect policy suitable for use as an
+// http.Client's CheckRedirect function that follows at most maxRedirects
+// redirects and records each redirect it follows in a test case result.
+// Once maxRedirects redirects have been followed, the last redirect response
+// is returned and the result's RedirectLimitExceeded field is set.
+//
+// If maxRedirects is 0, the fallback policy is used instead, or the default
+// http.Client policy if fallback is nil.
+func newRedirectPolicy(maxRedirects int, fallback func(*http.Request, []*http.Request) error, result *HTTPTestCaseResult) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		var err error
+		switch {
+		case maxRedirects < 0:
+			err = http.ErrUseLastResponse
+		case maxRedirects > 0:
+			if len(via) > maxRedirects {
+				result.RedirectLimitExceeded = true
+				err = http.ErrUseLastResponse
+			}
+		case fallback != nil:
+			err = fallback(req, via)
+		case len(via) >= 10:
+			err = errors.New("stopped after 10 redirects")
+		}
+
+		if err != nil {
+			return err
+		}
+
+		redirect := Redirect{Location: req.URL.String()}
+		if req.Response != nil {
+			redirect.Status = req.Response.StatusCode
+		}
+		if len(via) > 0 {
+			redirect.URL = via[len(via)-1].URL.String()
+		}
+
+		result.Redirects = append(result.Redirects, redirect)
+		return nil
+	}
+}
+
+// redirectRequest creates the request that follows a redirect response, in the
+// same manner as http.Client. It returns nil if the response is not a redirect.
+func redirectRequest(req *http.Request, resp *http.Response) (*http.Request, error) {
+	method := req.Method
+	keepBody := false
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther:
+		if method != http.MethodGet && method != http.MethodHead {
+			method = http.MethodGet
+		}
+	case http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		keepBody = true
+	default:
+		return nil, nil
+	}
+
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return nil, nil
+	}
+
+	u, err := req.URL.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Location header %q: %w", location, err)
+	}
+
+	next := req.Clone(req.Context())
+	next.Method = method
+	next.URL = u
+	next.Host = ""
+	next.Response = resp
+	next.Body = http.NoBody
+	next.ContentLength = 0
+	if keepBody && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+
+		next.Body = body
+		next.ContentLength = req.ContentLength
+	} else {
+		next.GetBody = nil
+		next.Header.Del("Content-Type")
+		next.Header.Del("Content-Length")
+	}
+
+	return next, nil
+}
+
+// redirectLocation returns the URL a redirect response points to, or nil if
+// the response is not a redirect.
+func redirectLocation(status int, headers http.Header, requestURL string) *url.URL {
+	if status < 300 || status > 399 {
+		return nil
+	}
+
+	location := headers.Get("Location")
+	if location == "" {
+		return nil
+	}
+
+	base, err := url.Parse(requestURL)
+	if err != nil {
+		return nil
+	}
+
+	u, err := base.Parse(location)
+	if err != nil {
+		return nil
+	}
+
+	return u
+}
+
+// matchesLocation determines whether a redirect location matches an expected
+// URL. Expected URLs without a host are compared against the path and, if
+// present, the query of the location only.
+func matchesLocation(expected string, location *url.URL) bool {
+	e, err := url.Parse(expected)
+	if err != nil {
+		return false
+	}
+
+	if e.Host != "" {
+		return e.String() == location.String()
+	}
+
+	return e.Path == location.Path && (e.RawQuery == "" || e.RawQuery == location.RawQuery)
+}
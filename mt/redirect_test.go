@@ -0,0 +1,165 @@
+package mt_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jefflinse/melatonin/mt"
+	"github.com/stretchr/testify/assert"
+)
+
+func redirectHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hops/", func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/hops/"))
+		if n == 0 {
+			fmt.Fprint(w, "arrived")
+			return
+		}
+
+		http.Redirect(w, r, "/hops/"+strconv.Itoa(n-1), http.StatusFound)
+	})
+	mux.HandleFunc("/see-other", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/echo", http.StatusSeeOther)
+	})
+	mux.HandleFunc("/temporary", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/echo", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s", r.Method, body)
+	})
+
+	return mux
+}
+
+func TestRedirects(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		tc            func(*mt.HTTPTestContext) *mt.HTTPTestCase
+		wantStatus    int
+		wantBody      string
+		wantRedirects int
+		wantExceeded  bool
+		wantFailures  []string
+	}{
+		{
+			name: "not followed by default",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/hops/2").ExpectRedirectTo("/hops/1").ExpectRedirectCount(0)
+			},
+			wantStatus: http.StatusFound,
+		},
+		{
+			name: "not followed",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/hops/2").WithMaxRedirects(mt.NoRedirects)
+			},
+			wantStatus: http.StatusFound,
+		},
+		{
+			name: "followed",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/hops/2").
+					WithMaxRedirects(mt.FollowRedirects).
+					ExpectRedirectTo("/hops/0").
+					ExpectRedirectCount(2)
+			},
+			wantStatus:    http.StatusOK,
+			wantBody:      "arrived",
+			wantRedirects: 2,
+		},
+		{
+			name: "limit reached exactly",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/hops/2").WithMaxRedirects(2)
+			},
+			wantStatus:    http.StatusOK,
+			wantBody:      "arrived",
+			wantRedirects: 2,
+		},
+		{
+			name: "limit exceeded returns the last redirect response",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/hops/3").WithMaxRedirects(1).ExpectRedirectTo("/hops/1")
+			},
+			wantStatus:    http.StatusFound,
+			wantRedirects: 1,
+			wantExceeded:  true,
+		},
+		{
+			name: "see other changes the method to GET",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/see-other").WithBody("payload").WithMaxRedirects(mt.FollowRedirects)
+			},
+			wantStatus:    http.StatusOK,
+			wantBody:      "GET ",
+			wantRedirects: 1,
+		},
+		{
+			name: "temporary redirect keeps the method and body",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/temporary").WithBody("payload").WithMaxRedirects(mt.FollowRedirects)
+			},
+			wantStatus:    http.StatusOK,
+			wantBody:      "POST payload",
+			wantRedirects: 1,
+		},
+		{
+			name: "unexpected redirect count",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/hops/2").WithMaxRedirects(mt.FollowRedirects).ExpectRedirectCount(1)
+			},
+			wantStatus:    http.StatusOK,
+			wantBody:      "arrived",
+			wantRedirects: 2,
+			wantFailures:  []string{"expected 1 redirects, got 2"},
+		},
+		{
+			name: "no redirects",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/hops/0").ExpectRedirectTo("/hops/1")
+			},
+			wantStatus:   http.StatusOK,
+			wantBody:     "arrived",
+			wantFailures: []string{`expected redirect to "/hops/1", got no redirects`},
+		},
+	} {
+		for _, mode := range []struct {
+			name string
+			mode int
+		}{
+			{"recorder", mt.ServeWithRecorder},
+			{"server", mt.ServeWithServer},
+		} {
+			t.Run(test.name+" with "+mode.name, func(t *testing.T) {
+				ctx := mt.NewHandlerContext(redirectHandler()).WithHandlerServeMode(mode.mode)
+				t.Cleanup(ctx.Close)
+
+				result := execute(t, test.tc(ctx))
+				assert.Equal(t, test.wantFailures, failures(result))
+				assert.Equal(t, test.wantStatus, result.Status)
+				assert.Len(t, result.Redirects, test.wantRedirects)
+				assert.Equal(t, test.wantExceeded, result.RedirectLimitExceeded)
+				if test.wantBody != "" {
+					assert.Equal(t, test.wantBody, string(result.Body))
+				}
+			})
+		}
+	}
+}
+
+func TestRedirectToFailure(t *testing.T) {
+	ctx := mt.NewHandlerContext(redirectHandler()).WithMaxRedirects(mt.FollowRedirects)
+
+	result := execute(t, ctx.GET("/hops/2").ExpectRedirectTo("/elsewhere"))
+	assert.Equal(t, []string{`expected redirect to "/elsewhere", got redirects to ["/hops/1" "/hops/0"]`}, failures(result))
+	assert.Equal(t, []mt.Redirect{
+		{Status: http.StatusFound, URL: "/hops/2", Location: "/hops/1"},
+		{Status: http.StatusFound, URL: "/hops/1", Location: "/hops/0"},
+	}, result.Redirects)
+}
@@ -3,6 +3,8 @@ package mt
 import (
 	"testing"
 	"time"
+
+	mtjson "github.com/jefflinse/melatonin/json"
 )
 
 const (
@@ -116,6 +118,29 @@ func (r *TestRunner) RunTestGroup(group *TestGroup) *GroupRunResult {
 //
 // To run tests as a standalone binary without a testing context, use RunTests().
 func (r *TestRunner) RunTestGroupT(t *testing.T, group *TestGroup) *GroupRunResult {
+	// shut down the test servers of the contexts the group uses once it's done
+	contexts := groupContexts(group)
+	closeServers := func() {
+		for _, c := range contexts {
+			c.Close()
+		}
+	}
+
+	if t != nil {
+		t.Cleanup(closeServers)
+	} else {
+		defer closeServers()
+	}
+
+	result := r.runTestGroup(t, group)
+	if t != nil && result.Failed > 0 {
+		t.Logf("to reproduce, set MELATONIN_SEED=%d", mtjson.Seed())
+	}
+
+	return result
+}
+
+func (r *TestRunner) runTestGroup(t *testing.T, group *TestGroup) *GroupRunResult {
 	groupResult := &GroupRunResult{
 		Group: group,
 	}
@@ -184,7 +209,7 @@ func (r *TestRunner) RunTestGroupT(t *testing.T, group *TestGroup) *GroupRunResu
 
 func (r *TestRunner) runSubgroups(t *testing.T, groupResult *GroupRunResult) {
 	for _, subgroup := range groupResult.Group.Subgroups {
-		result := r.RunTestGroupT(t, subgroup)
+		result := r.runTestGroup(t, subgroup)
 		groupResult.SubgroupResults = append(groupResult.SubgroupResults, result)
 		groupResult.Passed += result.Passed
 		groupResult.Failed += result.Failed
@@ -0,0 +1,108 @@
+package mt_test
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jefflinse/melatonin/expect"
+	mtjson "github.com/jefflinse/melatonin/json"
+	"github.com/jefflinse/melatonin/mt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpectBodySchema(t *testing.T) {
+	outside := filepath.Join(t.TempDir(), "user.schema.json")
+	if err := os.WriteFile(outside, []byte(`{"type": "object"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	items := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"items": map[string]any{
+				"type":  "array",
+				"items": map[string]any{"type": "object", "required": []any{"id"}},
+			},
+		},
+	}
+
+	compiled, err := mtjson.CompileSchema(items, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name         string
+		body         string
+		schema       any
+		wantFailures []string
+	}{
+		{
+			name:   "schema document",
+			body:   `{"items": [{"id": 1}, {"id": 2}]}`,
+			schema: items,
+		},
+		{
+			name:         "violations at their locations",
+			body:         `{"items": [{"id": 1}, {}, {"name": "c"}]}`,
+			schema:       items,
+			wantFailures: []string{".items[1].id: expected required field, got nothing", ".items[2].id: expected required field, got nothing"},
+		},
+		{
+			name:         "compiled schema",
+			body:         `{"items": {}}`,
+			schema:       compiled,
+			wantFailures: []string{".items: expected type array, got object"},
+		},
+		{
+			name:         "JSON text",
+			body:         `"Ada Lovelace"`,
+			schema:       []byte(`{"type": "string", "maxLength": 3}`),
+			wantFailures: []string{": expected at most 3 characters, got 12"},
+		},
+		{
+			name:         "schema file with references",
+			body:         `{"id": 0, "name": "Ada", "tags": ["a", 1]}`,
+			schema:       "testdata/user.schema.json",
+			wantFailures: []string{".id: expected at least 1, got 0", ".tags[1]: expected type string, got integer"},
+		},
+		{
+			name:   "schema file outside the working directory",
+			body:   `{}`,
+			schema: outside,
+			wantFailures: []string{fmt.Sprintf(": schema %q: outside of schema directory %q",
+				outside, mustGetwd(t))},
+		},
+		{
+			name:         "invalid schema",
+			body:         `{}`,
+			schema:       []byte(`{"type": `),
+			wantFailures: []string{": schema: unexpected end of JSON input"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, test.body)
+			})
+
+			result := execute(t, mt.NewHandlerContext(handler).GET("/").ExpectBodySchema(test.schema))
+			assert.Equal(t, test.wantFailures, failures(result))
+
+			// a schema predicate within an expected body loads files the same way
+			result = execute(t, mt.NewHandlerContext(handler).GET("/").ExpectBody(expect.Schema(test.schema)))
+			assert.Equal(t, test.wantFailures, failures(result))
+		})
+	}
+}
+
+func mustGetwd(t *testing.T) string {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
@@ -0,0 +1,79 @@
+package mt
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jefflinse/melatonin/expect"
+	"github.com/jefflinse/melatonin/golden"
+)
+
+// StatusClass creates a predicate requiring a status code to belong to a
+// class, such as 2 for any 2xx status.
+func StatusClass(class int) expect.Predicate {
+	return statusRanges(fmt.Sprintf("%dxx", class), golden.StatusRange{Min: class * 100, Max: class*100 + 99})
+}
+
+// StatusIn creates a predicate requiring a status code to be one of a set of
+// status codes.
+func StatusIn(statuses ...int) expect.Predicate {
+	descriptions := make([]string, len(statuses))
+	ranges := make([]golden.StatusRange, len(statuses))
+	for i, status := range statuses {
+		descriptions[i] = statusString(status)
+		ranges[i] = golden.StatusRange{Min: status, Max: status}
+	}
+
+	return statusRanges(strings.Join(descriptions, " or "), ranges...)
+}
+
+// StatusPattern creates a predicate requiring a status code to match a
+// pattern, such as "2xx", "200|204", or "200-299|404". Patterns use the same
+// syntax as the status line of a golden file.
+func StatusPattern(pattern string) expect.Predicate {
+	ranges, err := golden.ParseStatusPattern(pattern)
+	if err != nil {
+		return func(any) error {
+			return fmt.Errorf("invalid status pattern %q", pattern)
+		}
+	}
+
+	return statusRanges(pattern, ranges...)
+}
+
+// StatusRange creates a predicate requiring a status code to be within an
+// inclusive range.
+func StatusRange(min, max int) expect.Predicate {
+	return statusRanges(fmt.Sprintf("%d-%d", min, max), golden.StatusRange{Min: min, Max: max})
+}
+
+// statusRanges creates a predicate requiring a status code to be within any of
+// a set of ranges.
+func statusRanges(description string, ranges ...golden.StatusRange) expect.Predicate {
+	return func(actual any) error {
+		status, ok := actual.(int)
+		if !ok {
+			return fmt.Errorf("expected status code, got %T: %+v", actual, actual)
+		}
+
+		for _, r := range ranges {
+			if status >= r.Min && status <= r.Max {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("expected %s", description)
+	}
+}
+
+// statusString formats a status code along with its status text, if known,
+// such as "404 Not Found".
+func statusString(status int) string {
+	if text := http.StatusText(status); text != "" {
+		return strconv.Itoa(status) + " " + text
+	}
+
+	return strconv.Itoa(status)
+}
@@ -0,0 +1,347 @@
+package mt_test
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jefflinse/melatonin/expect"
+	"github.com/jefflinse/melatonin/mt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusPredicates(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		predicate expect.Predicate
+		status    any
+		wantError string
+	}{
+		{
+			name:      "in class",
+			predicate: mt.StatusClass(2),
+			status:    204,
+		},
+		{
+			name:      "not in class",
+			predicate: mt.StatusClass(2),
+			status:    404,
+			wantError: "expected 2xx",
+		},
+		{
+			name:      "in set",
+			predicate: mt.StatusIn(200, 204),
+			status:    204,
+		},
+		{
+			name:      "not in set",
+			predicate: mt.StatusIn(200, 204, 599),
+			status:    201,
+			wantError: "expected 200 OK or 204 No Content or 599",
+		},
+		{
+			name:      "matches pattern",
+			predicate: mt.StatusPattern("200-299|404"),
+			status:    404,
+		},
+		{
+			name:      "doesn't match pattern",
+			predicate: mt.StatusPattern("200-299|404"),
+			status:    500,
+			wantError: "expected 200-299|404",
+		},
+		{
+			name:      "invalid pattern",
+			predicate: mt.StatusPattern("2x"),
+			status:    200,
+			wantError: `invalid status pattern "2x"`,
+		},
+		{
+			name:      "in range",
+			predicate: mt.StatusRange(400, 499),
+			status:    418,
+		},
+		{
+			name:      "not in range",
+			predicate: mt.StatusRange(400, 499),
+			status:    500,
+			wantError: "expected 400-499",
+		},
+		{
+			name:      "not a status code",
+			predicate: mt.StatusClass(2),
+			status:    "200",
+			wantError: "expected status code, got string: 200",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.predicate(test.status)
+			if test.wantError != "" {
+				if assert.Error(t, err) {
+					assert.Equal(t, test.wantError, err.Error())
+				}
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestStatusExpectations(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		status       int
+		tc           func(*mt.HTTPTestCase) *mt.HTTPTestCase
+		wantFailures []string
+	}{
+		{
+			name:   "status",
+			status: http.StatusOK,
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				return tc.ExpectStatus(http.StatusOK)
+			},
+		},
+		{
+			name:   "unexpected status",
+			status: http.StatusTeapot,
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				return tc.ExpectStatus(http.StatusOK)
+			},
+			wantFailures: []string{"expected status 200 OK, got 418 I'm a teapot"},
+		},
+		{
+			name:   "status in set",
+			status: http.StatusCreated,
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				return tc.ExpectStatusIn(http.StatusOK, http.StatusCreated)
+			},
+		},
+		{
+			name:   "status not in set",
+			status: http.StatusNotFound,
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				return tc.ExpectStatusIn(http.StatusOK, http.StatusCreated)
+			},
+			wantFailures: []string{"unexpected status 404 Not Found: expected 200 OK or 201 Created"},
+		},
+		{
+			name:   "status matches pattern",
+			status: http.StatusTeapot,
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				return tc.ExpectStatusPattern("4xx")
+			},
+		},
+		{
+			name:   "status doesn't match pattern",
+			status: http.StatusInternalServerError,
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				return tc.ExpectStatusPattern("2xx|404")
+			},
+			wantFailures: []string{"unexpected status 500 Internal Server Error: expected 2xx|404"},
+		},
+		{
+			name:   "status satisfies predicate",
+			status: http.StatusServiceUnavailable,
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				return tc.ExpectStatusPredicate(mt.StatusRange(500, 599))
+			},
+		},
+		{
+			name:   "status replaces predicate",
+			status: http.StatusNoContent,
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				return tc.ExpectStatusPattern("4xx").ExpectStatus(http.StatusNoContent)
+			},
+		},
+		{
+			name:   "predicate replaces status",
+			status: http.StatusNoContent,
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				return tc.ExpectStatus(http.StatusOK).ExpectStatusPredicate(mt.StatusClass(2))
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+			})
+
+			result := execute(t, test.tc(mt.NewHandlerContext(handler).GET("/")))
+			assert.Equal(t, test.wantFailures, failures(result))
+		})
+	}
+}
+
+func TestGoldenStatusPattern(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "status.golden")
+	if err := os.WriteFile(path, []byte("2xx\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name         string
+		status       int
+		tc           func(*mt.HTTPTestCase) *mt.HTTPTestCase
+		wantFailures []string
+	}{
+		{
+			name:   "matches pattern",
+			status: http.StatusNoContent,
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				return tc.ExpectGolden(path)
+			},
+		},
+		{
+			name:   "doesn't match pattern",
+			status: http.StatusTeapot,
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				return tc.ExpectGolden(path)
+			},
+			wantFailures: []string{"unexpected status 418 I'm a teapot: expected 2xx"},
+		},
+		{
+			name:   "overridden",
+			status: http.StatusTeapot,
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				return tc.ExpectGolden(path).ExpectStatus(http.StatusTeapot)
+			},
+		},
+		{
+			name:   "missing golden file",
+			status: http.StatusOK,
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				return tc.ExpectGolden(filepath.Join(dir, "missing.golden"))
+			},
+			wantFailures: []string{fmt.Sprintf("golden file %q: not found", filepath.Join(dir, "missing.golden"))},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+			})
+
+			result := execute(t, test.tc(mt.NewHandlerContext(handler).GET("/")))
+			assert.Equal(t, test.wantFailures, failures(result))
+		})
+	}
+}
+
+func TestGoldenFileLoadedOnce(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "status.golden")
+	if err := os.WriteFile(path, []byte("204\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tc := mt.NewHandlerContext(handler).GET("/").ExpectGolden(path)
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Nil(t, failures(execute(t, tc)))
+	assert.Nil(t, failures(execute(t, tc)))
+
+	tc.GoldenFilePath = filepath.Join(dir, "other.golden")
+	assert.Equal(t,
+		[]string{fmt.Sprintf("golden file %q: not found", tc.GoldenFilePath)},
+		failures(execute(t, tc)))
+}
+
+func TestValidate(t *testing.T) {
+	ctx := mt.NewHandlerContext(http.NotFoundHandler())
+	ctx.BaseURL = "http://example.com"
+
+	tc := ctx.GET("/")
+	err := tc.Validate()
+	if assert.Error(t, err) {
+		assert.Equal(t, `HTTP test context "http://example.com" cannot specify both a base URL and handler`, err.Error())
+	}
+
+	// executing the test case uses the handler, as it always has
+	result := execute(t, tc.ExpectStatus(http.StatusNotFound))
+	assert.Nil(t, failures(result))
+}
+
+func TestGoldenExpectationOrder(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"full.golden":   "200\n--- headers\nX-Golden: g\n--- body json\n{\"name\": \"golden\"}\n",
+		"status.golden": "200\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Golden", "g")
+		w.Header().Set("X-Other", "o")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"name": "golden"}`)
+	})
+
+	for _, test := range []struct {
+		name         string
+		tc           func(*mt.HTTPTestCase) *mt.HTTPTestCase
+		wantFailures []string
+	}{
+		{
+			name: "golden values replace those set before",
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				return tc.ExpectStatus(http.StatusCreated).
+					ExpectHeader("X-Golden", "before").
+					ExpectBody(map[string]any{"name": "before"}).
+					ExpectGolden(filepath.Join(dir, "full.golden"))
+			},
+		},
+		{
+			name: "other expectations set before are kept",
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				return tc.ExpectHeader("X-Other", "before").
+					ExpectBody(map[string]any{"name": "before"}).
+					ExpectGolden(filepath.Join(dir, "status.golden"))
+			},
+			wantFailures: []string{
+				`expected header "X-Other" to contain "before", got ["o"]`,
+				".name: expected before, got golden",
+			},
+		},
+		{
+			name: "expectations set after override golden values",
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				return tc.ExpectGolden(filepath.Join(dir, "full.golden")).
+					ExpectStatus(http.StatusCreated).
+					ExpectHeader("X-Golden", "after").
+					ExpectBody(map[string]any{"name": "after"})
+			},
+			wantFailures: []string{
+				"expected status 201 Created, got 200 OK",
+				`expected header "X-Golden" to contain "after", got ["g"]`,
+				".name: expected after, got golden",
+			},
+		},
+		{
+			name: "golden file path set directly",
+			tc: func(tc *mt.HTTPTestCase) *mt.HTTPTestCase {
+				tc = tc.ExpectBody(map[string]any{"name": "before"})
+				tc.GoldenFilePath = filepath.Join(dir, "full.golden")
+				return tc
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			result := execute(t, test.tc(mt.NewHandlerContext(handler).GET("/")))
+			assert.Equal(t, test.wantFailures, failures(result))
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package mt
+
+import (
+	"io"
+	"math/rand"
+	"time"
+)
+
+// RandomBody creates a reader factory that produces size bytes of
+// deterministic pseudo-random data generated from a seed. Pass it to WithBody
+// to stream a large request body without holding it in memory. Each call to
+// the factory produces an identical stream.
+func RandomBody(size int64, seed int64) func() io.Reader {
+	return func() io.Reader {
+		return io.LimitReader(rand.New(rand.NewSource(seed)), size)
+	}
+}
+
+// Throttle wraps a reader factory so that the readers it produces are limited
+// to the given number of bytes per second, simulating a slow-sending client.
+func Throttle(body func() io.Reader, bytesPerSecond int64) func() io.Reader {
+	return func() io.Reader {
+		return &throttledReader{
+			r:              body(),
+			bytesPerSecond: bytesPerSecond,
+		}
+	}
+}
+
+// throttledReader is a reader limited to a maximum throughput.
+type throttledReader struct {
+	r              io.Reader
+	bytesPerSecond int64
+	start          time.Time
+	total          int64
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if t.start.IsZero() {
+		t.start = time.Now()
+	}
+
+	// read in chunks of at most a tenth of a second's worth of data
+	chunk := t.bytesPerSecond / 10
+	if chunk < 1 {
+		chunk = 1
+	}
+	if int64(len(p)) > chunk {
+		p = p[:chunk]
+	}
+
+	n, err := t.r.Read(p)
+	t.total += int64(n)
+
+	expected := time.Duration(float64(t.total) / float64(t.bytesPerSecond) * float64(time.Second))
+	if wait := expected - time.Since(t.start); wait > 0 {
+		time.Sleep(wait)
+	}
+
+	return n, err
+}
+
+// toStreamFactory determines whether a request body should be streamed rather
+// than buffered. If so, it returns a function producing the stream, and whether
+// the function produces a fresh stream each time it's called. Otherwise, it
+// returns nil.
+func toStreamFactory(body any) (func() (io.ReadCloser, error), bool) {
+	switch v := body.(type) {
+	case func() io.Reader:
+		return func() (io.ReadCloser, error) {
+			return io.NopCloser(v()), nil
+		}, true
+	case func() (io.Reader, error):
+		return func() (io.ReadCloser, error) {
+			r, err := v()
+			if err != nil {
+				return nil, err
+			}
+			return io.NopCloser(r), nil
+		}, true
+	case io.Reader:
+		return func() (io.ReadCloser, error) {
+			return io.NopCloser(v), nil
+		}, false
+	default:
+		return nil, false
+	}
+}
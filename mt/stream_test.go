@@ -0,0 +1,133 @@
+package mt_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jefflinse/melatonin/mt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRandomBody(t *testing.T) {
+	read := func(body func() io.Reader) []byte {
+		b, err := io.ReadAll(body())
+		assert.NoError(t, err)
+		return b
+	}
+
+	body := mt.RandomBody(1000, 7)
+	first := read(body)
+	assert.Len(t, first, 1000)
+	assert.Equal(t, first, read(body))
+	assert.Equal(t, first, read(mt.RandomBody(1000, 7)))
+	assert.NotEqual(t, first, read(mt.RandomBody(1000, 8)))
+}
+
+func TestThrottle(t *testing.T) {
+	start := time.Now()
+	b, err := io.ReadAll(mt.Throttle(mt.RandomBody(500, 7), 5000)())
+	assert.NoError(t, err)
+	assert.Len(t, b, 500)
+	assert.GreaterOrEqual(t, time.Since(start), 80*time.Millisecond)
+}
+
+func TestStreamedBodies(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%d %v %s", r.ContentLength, r.TransferEncoding, body)
+	})
+
+	for _, test := range []struct {
+		name         string
+		tc           func(*mt.HTTPTestContext) *mt.HTTPTestCase
+		wantBody     string
+		wantFailures []string
+	}{
+		{
+			name: "reader factory",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/").WithBody(func() io.Reader { return strings.NewReader("streamed") })
+			},
+			wantBody: "-1 [chunked] streamed",
+		},
+		{
+			name: "reader",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/").WithBody(bytes.NewBufferString("streamed"))
+			},
+			wantBody: "-1 [chunked] streamed",
+		},
+		{
+			name: "reader factory replayed after a redirect",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/redirect").
+					WithBody(func() io.Reader { return strings.NewReader("streamed") }).
+					WithMaxRedirects(mt.FollowRedirects)
+			},
+			wantBody: "-1 [chunked] streamed",
+		},
+		{
+			name: "reader factory error",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/").WithBody(func() (io.Reader, error) { return nil, errors.New("no stream") })
+			},
+			wantFailures: []string{"request body: no stream"},
+		},
+		{
+			name: "chunked encoding",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/").WithBody("buffered").WithChunkedEncoding()
+			},
+			wantBody: "-1 [chunked] buffered",
+		},
+		{
+			name: "buffered",
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.POST("/").WithBody("buffered")
+			},
+			wantBody: "8 [] buffered",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := mt.NewHandlerContext(handler).WithHandlerServeMode(mt.ServeWithServer)
+			t.Cleanup(ctx.Close)
+
+			result := execute(t, test.tc(ctx))
+			assert.Equal(t, test.wantFailures, failures(result))
+			if test.wantBody != "" {
+				assert.Equal(t, test.wantBody, string(result.Body))
+			}
+		})
+	}
+}
+
+func TestStreamedRandomBody(t *testing.T) {
+	want, err := io.ReadAll(mt.RandomBody(1<<20, 7)())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !bytes.Equal(want, body) {
+			http.Error(w, "unexpected body", http.StatusBadRequest)
+		}
+	})
+
+	ctx := mt.NewHandlerContext(handler).WithHandlerServeMode(mt.ServeWithServer)
+	t.Cleanup(ctx.Close)
+
+	result := execute(t, ctx.POST("/").WithBody(mt.RandomBody(1<<20, 7)).ExpectStatus(http.StatusOK))
+	assert.Nil(t, failures(result))
+}
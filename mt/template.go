@@ -0,0 +1,64 @@
+package mt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	mtjson "github.com/jefflinse/melatonin/json"
+)
+
+// A bodyTemplate is a request body rendered from a text/template file when the
+// test case is executed.
+type bodyTemplate struct {
+	path string
+	data map[string]any
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// ContentType returns the Content-Type header value for the body, determined
+// from the file's extension.
+func (t bodyTemplate) ContentType() string {
+	return mime.TypeByExtension(filepath.Ext(t.path))
+}
+
+// render resolves any deferred values in the template data and renders the
+// template. Errors identify the file, and for template errors, the line.
+func (t bodyTemplate) render() ([]byte, error) {
+	path := t.path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cfg.WorkingDir, path)
+	}
+
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("body file %q: %w", t.path, err)
+	}
+
+	data, err := mtjson.ResolveDeferred(map[string]any(t.data))
+	if err != nil {
+		return nil, fmt.Errorf("body file %q: %w", t.path, err)
+	}
+
+	tmpl, err := template.New(t.path).Funcs(templateFuncs).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
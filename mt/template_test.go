@@ -0,0 +1,108 @@
+package mt_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	mtjson "github.com/jefflinse/melatonin/json"
+	"github.com/jefflinse/melatonin/mt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithBodyFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"user.json":    `{"name": {{ json .name }}, "tags": {{ json .tags }}}`,
+		"greeting.txt": `Hello, {{ .name }}!`,
+		"broken.json":  `{"name": {{ .name }`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+
+	for _, test := range []struct {
+		name         string
+		path         string
+		data         map[string]any
+		header       string
+		wantBody     string
+		wantFailures []string
+	}{
+		{
+			name:     "JSON template",
+			path:     path("user.json"),
+			data:     map[string]any{"name": "Ada", "tags": []string{"a", "b"}},
+			wantBody: `application/json|{"name": "Ada", "tags": ["a","b"]}`,
+		},
+		{
+			name:     "deferred values",
+			path:     path("greeting.txt"),
+			data:     map[string]any{"name": mtjson.Defer(func() string { return "Grace" })},
+			wantBody: "text/plain; charset=utf-8|Hello, Grace!",
+		},
+		{
+			name:     "explicit Content-Type",
+			path:     path("greeting.txt"),
+			data:     map[string]any{"name": "Ada"},
+			header:   "text/x-greeting",
+			wantBody: "text/x-greeting|Hello, Ada!",
+		},
+		{
+			name: "missing key",
+			path: path("greeting.txt"),
+			data: map[string]any{},
+			wantFailures: []string{fmt.Sprintf(
+				`request body: template: %[1]s:1:10: executing %[1]q at <.name>: map has no entry for key "name"`,
+				path("greeting.txt"))},
+		},
+		{
+			name: "deferred value error",
+			path: path("greeting.txt"),
+			data: map[string]any{"name": func() (any, error) { return nil, errors.New("no name") }},
+			wantFailures: []string{fmt.Sprintf(
+				`request body: body file %q: name: no name`, path("greeting.txt"))},
+		},
+		{
+			name: "invalid template",
+			path: path("broken.json"),
+			data: map[string]any{"name": "Ada"},
+			wantFailures: []string{fmt.Sprintf(
+				`request body: template: %s:1: unexpected "}" in operand`, path("broken.json"))},
+		},
+		{
+			name: "missing file",
+			path: path("missing.json"),
+			wantFailures: []string{fmt.Sprintf(
+				`request body: body file %[1]q: open %[1]s: no such file or directory`, path("missing.json"))},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				fmt.Fprintf(w, "%s|%s", r.Header.Get("Content-Type"), body)
+			})
+
+			tc := mt.NewHandlerContext(handler).POST("/")
+			if test.header != "" {
+				tc.WithHeader("Content-Type", test.header)
+			}
+
+			result := execute(t, tc.WithBodyFile(test.path, test.data))
+			assert.Equal(t, test.wantFailures, failures(result))
+			if test.wantFailures == nil {
+				assert.Equal(t, test.wantBody, string(result.Body))
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package mt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"time"
+)
+
+// TestCertificates is a set of ephemeral certificates for use in local TLS
+// and mutual TLS tests. The server and client certificates are both signed
+// by the same certificate authority.
+type TestCertificates struct {
+	// CA is the certificate authority that signed the server and client
+	// certificates.
+	CA *x509.Certificate
+
+	// CAPEM is the PEM encoding of the certificate authority, suitable for
+	// writing to a file for use by an external service.
+	CAPEM []byte
+
+	// CAPool is a certificate pool containing only the certificate authority.
+	CAPool *x509.CertPool
+
+	// Server is a certificate valid for the hosts passed to
+	// GenerateTestCertificates.
+	Server tls.Certificate
+
+	// Client is a certificate valid for client authentication.
+	Client tls.Certificate
+}
+
+// GenerateTestCertificates generates an ephemeral certificate authority, along
+// with a server certificate and client certificate signed by it.
+//
+// The server certificate is valid for the given hosts, which may be DNS names
+// or IP addresses. If no hosts are given, the certificate is valid for
+// localhost, 127.0.0.1, and ::1.
+func GenerateTestCertificates(hosts ...string) (*TestCertificates, error) {
+	if len(hosts) == 0 {
+		hosts = []string{"localhost", "127.0.0.1", "::1"}
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate CA key: %w", err)
+	}
+
+	caTemplate, err := newCertificateTemplate("melatonin test CA")
+	if err != nil {
+		return nil, err
+	}
+
+	caTemplate.IsCA = true
+	caTemplate.BasicConstraintsValid = true
+	caTemplate.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, fmt.Errorf("create CA certificate: %w", err)
+	}
+
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, fmt.Errorf("parse CA certificate: %w", err)
+	}
+
+	serverTemplate, err := newCertificateTemplate("melatonin test server")
+	if err != nil {
+		return nil, err
+	}
+
+	serverTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			serverTemplate.IPAddresses = append(serverTemplate.IPAddresses, ip)
+		} else {
+			serverTemplate.DNSNames = append(serverTemplate.DNSNames, host)
+		}
+	}
+
+	server, err := newSignedCertificate(serverTemplate, ca, caKey)
+	if err != nil {
+		return nil, fmt.Errorf("create server certificate: %w", err)
+	}
+
+	clientTemplate, err := newCertificateTemplate("melatonin test client")
+	if err != nil {
+		return nil, err
+	}
+
+	clientTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	client, err := newSignedCertificate(clientTemplate, ca, caKey)
+	if err != nil {
+		return nil, fmt.Errorf("create client certificate: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	return &TestCertificates{
+		CA:     ca,
+		CAPEM:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		CAPool: pool,
+		Server: server,
+		Client: client,
+	}, nil
+}
+
+// ClientTLSConfig returns a client TLS configuration that trusts the
+// certificate authority and presents the client certificate.
+func (c *TestCertificates) ClientTLSConfig() *tls.Config {
+	return &tls.Config{
+		RootCAs:      c.CAPool,
+		Certificates: []tls.Certificate{c.Client},
+	}
+}
+
+// ServerTLSConfig returns a server TLS configuration that presents the server
+// certificate and requires clients to present a certificate signed by the
+// certificate authority.
+func (c *TestCertificates) ServerTLSConfig() *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{c.Server},
+		ClientCAs:    c.CAPool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+}
+
+func newCertificateTemplate(commonName string) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("generate serial number: %w", err)
+	}
+
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"melatonin"}},
+		NotBefore:    now.Add(-1 * time.Hour),
+		NotAfter:     now.Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}, nil
+}
+
+func newSignedCertificate(template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
+
+// cloneTransport returns a copy of a round tripper that can be reconfigured
+// without affecting the original.
+func cloneTransport(rt http.RoundTripper) (*http.Transport, error) {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	transport, ok := rt.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("unable to apply TLS configuration to HTTP transport of type %T", rt)
+	}
+
+	return transport.Clone(), nil
+}
+
+// tlsVersionName returns a human-readable name for a TLS version.
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	default:
+		return fmt.Sprintf("0x%04X", version)
+	}
+}
@@ -0,0 +1,217 @@
+package mt_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jefflinse/melatonin/mt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateTestCertificates(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		hosts     []string
+		verify    string
+		wantError bool
+	}{
+		{
+			name:   "default hosts include localhost",
+			verify: "localhost",
+		},
+		{
+			name:   "default hosts include the loopback address",
+			verify: "127.0.0.1",
+		},
+		{
+			name:   "custom host",
+			hosts:  []string{"api.example.test"},
+			verify: "api.example.test",
+		},
+		{
+			name:      "host not included",
+			hosts:     []string{"api.example.test"},
+			verify:    "localhost",
+			wantError: true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			certs, err := mt.GenerateTestCertificates(test.hosts...)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			_, err = certs.Server.Leaf.Verify(x509.VerifyOptions{
+				DNSName: test.verify,
+				Roots:   certs.CAPool,
+			})
+			if test.wantError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			_, err = certs.Client.Leaf.Verify(x509.VerifyOptions{
+				Roots:     certs.CAPool,
+				KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			})
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestTLSExpectations(t *testing.T) {
+	certs, err := mt.GenerateTestCertificates()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+			fmt.Fprint(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+		}
+	})
+
+	commonName := func(name string) func(*x509.Certificate) error {
+		return func(cert *x509.Certificate) error {
+			if cert.Subject.CommonName != name {
+				return fmt.Errorf("expected common name %q, got %q", name, cert.Subject.CommonName)
+			}
+			return nil
+		}
+	}
+
+	for _, test := range []struct {
+		name         string
+		mode         int
+		configure    func(*mt.HTTPTestContext)
+		tc           func(*mt.HTTPTestContext) *mt.HTTPTestCase
+		wantFailures []string
+	}{
+		{
+			name: "mutual TLS",
+			mode: mt.ServeWithTLSServer,
+			configure: func(ctx *mt.HTTPTestContext) {
+				ctx.WithServerTLSConfig(certs.ServerTLSConfig()).WithTLSConfig(certs.ClientTLSConfig())
+			},
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/").
+					ExpectBody("melatonin test client").
+					ExpectTLSVersion(tls.VersionTLS13).
+					ExpectPeerCertificate(commonName("melatonin test server"))
+			},
+		},
+		{
+			name: "client certificate added to the context",
+			mode: mt.ServeWithTLSServer,
+			configure: func(ctx *mt.HTTPTestContext) {
+				ctx.WithServerTLSConfig(certs.ServerTLSConfig()).
+					WithRootCAs(certs.CAPool).
+					WithServerName("localhost").
+					WithClientCertificate(certs.Client)
+			},
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/").ExpectBody("melatonin test client")
+			},
+		},
+		{
+			name: "unexpected TLS version",
+			mode: mt.ServeWithTLSServer,
+			configure: func(ctx *mt.HTTPTestContext) {
+				ctx.WithServerTLSConfig(certs.ServerTLSConfig()).
+					WithTLSConfig(certs.ClientTLSConfig()).
+					WithTLSVersions(tls.VersionTLS12, tls.VersionTLS12)
+			},
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/").ExpectTLSVersion(tls.VersionTLS13)
+			},
+			wantFailures: []string{"expected TLS 1.3 connection, got TLS 1.2"},
+		},
+		{
+			name: "unexpected peer certificate",
+			mode: mt.ServeWithTLSServer,
+			configure: func(ctx *mt.HTTPTestContext) {
+				ctx.WithServerTLSConfig(certs.ServerTLSConfig()).WithTLSConfig(certs.ClientTLSConfig())
+			},
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/").ExpectPeerCertificate(commonName("api.example.test"))
+			},
+			wantFailures: []string{`peer certificate: expected common name "api.example.test", got "melatonin test server"`},
+		},
+		{
+			name: "plaintext connection",
+			mode: mt.ServeWithServer,
+			tc: func(ctx *mt.HTTPTestContext) *mt.HTTPTestCase {
+				return ctx.GET("/").
+					ExpectTLSVersion(tls.VersionTLS13).
+					ExpectPeerCertificate(commonName("melatonin test server"))
+			},
+			wantFailures: []string{
+				"expected TLS 1.3 connection, got plaintext",
+				"expected peer certificate, got plaintext connection",
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := mt.NewHandlerContext(handler).WithHandlerServeMode(test.mode)
+			if test.configure != nil {
+				test.configure(ctx)
+			}
+			t.Cleanup(ctx.Close)
+
+			result := execute(t, test.tc(ctx))
+			assert.Equal(t, test.wantFailures, failures(result))
+		})
+	}
+}
+
+func TestTLSClientCertificateRequired(t *testing.T) {
+	certs, err := mt.GenerateTestCertificates()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ctx := mt.NewHandlerContext(http.NotFoundHandler()).
+		WithHandlerServeMode(mt.ServeWithTLSServer).
+		WithServerTLSConfig(certs.ServerTLSConfig()).
+		WithRootCAs(certs.CAPool)
+	t.Cleanup(ctx.Close)
+
+	result := execute(t, ctx.GET("/"))
+	if assert.Len(t, result.Failures(), 1) {
+		assert.True(t, strings.HasPrefix(result.Failures()[0].Error(), "failed to handle HTTP request: "))
+	}
+}
+
+func TestWithHTTPClientAfterTLSConfig(t *testing.T) {
+	certs, err := mt.GenerateTestCertificates()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	server.TLS = certs.ServerTLSConfig()
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	ctx := mt.NewURLContext(server.URL).WithTLSConfig(certs.ClientTLSConfig())
+	result := execute(t, ctx.GET("/").ExpectStatus(http.StatusNotFound))
+	assert.Nil(t, failures(result))
+
+	// the new client's transport is used, rather than the one built for the
+	// previous client
+	ctx.WithHTTPClient(&http.Client{Transport: roundTripperFunc(http.DefaultTransport.RoundTrip)})
+	result = execute(t, ctx.GET("/").ExpectStatus(http.StatusNotFound))
+	assert.Equal(t, []string{"unable to apply TLS configuration to HTTP transport of type mt_test.roundTripperFunc"}, failures(result))
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
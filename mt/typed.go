@@ -0,0 +1,125 @@
+package mt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"reflect"
+	"strings"
+
+	"github.com/jefflinse/melatonin/expect"
+)
+
+// ExpectBodyAs sets an expectation that the response body decodes into a
+// value of type T, which is passed to an assertion function, and returns the
+// test case. The assertion may be nil to only check that the body decodes.
+//
+// The body must contain a single value. Fields of objects that T doesn't
+// declare are ignored, so T may model only part of the body; use
+// ExpectExactBodyAs to fail on them instead. Bodies of media types other than
+// JSON are decoded using their registered decoder and then converted to T
+// through their JSON representation.
+//
+// Decoding and assertion errors are reported as response body failures. Use
+// BodyAs to retrieve the decoded value from the test case's result.
+func ExpectBodyAs[T any](tc *HTTPTestCase, assertion func(T) error) *HTTPTestCase {
+	return expectBodyAs(tc, assertion, false)
+}
+
+// ExpectExactBodyAs sets an expectation that the response body decodes into a
+// value of type T, like ExpectBodyAs, but also fails if an object in the body
+// contains a field that T doesn't declare.
+func ExpectExactBodyAs[T any](tc *HTTPTestCase, assertion func(T) error) *HTTPTestCase {
+	return expectBodyAs(tc, assertion, true)
+}
+
+func expectBodyAs[T any](tc *HTTPTestCase, assertion func(T) error, exact bool) *HTTPTestCase {
+	tc.Expectations.BodyAs = func(contentType string, body []byte) (any, error) {
+		var v T
+		if err := decodeTyped(contentType, body, &v, exact); err != nil {
+			return nil, fmt.Errorf("failed to decode as %s: %w", reflect.TypeOf(&v).Elem(), err)
+		}
+
+		if assertion == nil {
+			return v, nil
+		}
+
+		return v, assertion(v)
+	}
+
+	return tc
+}
+
+// BodyAs returns the response body decoded by the test case's ExpectBodyAs
+// expectation. The second return value is false if the test case has no such
+// expectation of type T, or the body couldn't be decoded.
+func BodyAs[T any](r *HTTPTestCaseResult) (T, bool) {
+	v, ok := r.DecodedBody.(T)
+	return v, ok
+}
+
+// checkBodyAs decodes the response body for an ExpectBodyAs expectation and
+// runs its assertion.
+func (r *HTTPTestCaseResult) checkBodyAs(contentType string, bodyAs func(string, []byte) (any, error)) {
+	v, err := bodyAs(contentType, r.Body)
+	r.DecodedBody = v
+	if err == nil {
+		return
+	}
+
+	var failures expect.FailedPredicateErrors
+	var failure *expect.FailedPredicateError
+	switch {
+	case errors.As(err, &failures):
+		for _, failure := range failures {
+			failure.PushField("")
+			r.addFailures(failure)
+		}
+	case errors.As(err, &failure):
+		failure.PushField("")
+		r.addFailures(failure)
+	default:
+		r.addFailures(fmt.Errorf("response body: %w", err))
+	}
+}
+
+// decodeTyped decodes a response body into v, failing if the body contains
+// more than one value, or, if exact is true, unknown fields.
+func decodeTyped(contentType string, body []byte, v any, exact bool) error {
+	data := body
+	if !isJSONMediaType(contentType) && lookupDecoder(contentType) != nil {
+		decoded, err := decodeBody(contentType, body)
+		if err != nil {
+			return err
+		}
+
+		if data, err = json.Marshal(decoded); err != nil {
+			return err
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if exact {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		if err == io.EOF {
+			return errors.New("expected a value, got nothing")
+		}
+		return err
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("unexpected data after value")
+	}
+
+	return nil
+}
+
+func isJSONMediaType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
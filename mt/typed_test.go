@@ -0,0 +1,174 @@
+package mt_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/jefflinse/melatonin/expect"
+	"github.com/jefflinse/melatonin/mt"
+	"github.com/stretchr/testify/assert"
+)
+
+type typedUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestExpectBodyAs(t *testing.T) {
+	nameIs := func(name string) func(typedUser) error {
+		return func(u typedUser) error {
+			if u.Name != name {
+				return fmt.Errorf("expected name %s, got %s", name, u.Name)
+			}
+			return nil
+		}
+	}
+
+	for _, test := range []struct {
+		name         string
+		contentType  string
+		body         string
+		exact        bool
+		assertion    func(typedUser) error
+		wantUser     *typedUser
+		wantFailures []string
+	}{
+		{
+			name:        "JSON",
+			contentType: "application/json",
+			body:        `{"id": 1, "name": "Ada"}`,
+			assertion:   nameIs("Ada"),
+			wantUser:    &typedUser{ID: 1, Name: "Ada"},
+		},
+		{
+			name:        "structured syntax suffix",
+			contentType: "application/vnd.api+json",
+			body:        `{"id": 1, "name": "Ada"}`,
+			wantUser:    &typedUser{ID: 1, Name: "Ada"},
+		},
+		{
+			name:        "registered decoder",
+			contentType: "application/yaml",
+			body:        "id: 1\nname: Ada\n",
+			assertion:   nameIs("Ada"),
+			wantUser:    &typedUser{ID: 1, Name: "Ada"},
+		},
+		{
+			name:         "failed assertion",
+			contentType:  "application/json",
+			body:         `{"id": 1, "name": "Ada"}`,
+			assertion:    nameIs("Grace"),
+			wantUser:     &typedUser{ID: 1, Name: "Ada"},
+			wantFailures: []string{"response body: expected name Grace, got Ada"},
+		},
+		{
+			name:        "failed predicates",
+			contentType: "application/json",
+			body:        `{"id": 1, "name": "Ada"}`,
+			assertion: func(u typedUser) error {
+				return expect.FailedPredicateErrors(expect.CompareValues(
+					map[string]any{"id": 2, "name": "Ada"},
+					map[string]any{"id": u.ID, "name": u.Name},
+					false))
+			},
+			wantUser:     &typedUser{ID: 1, Name: "Ada"},
+			wantFailures: []string{".id: expected 2, got 1"},
+		},
+		{
+			name:        "failed predicate",
+			contentType: "application/json",
+			body:        `{"id": 1, "name": "Ada"}`,
+			assertion: func(u typedUser) error {
+				return fmt.Errorf("checking name: %w", expect.CompareValues("Grace", u.Name, false)[0])
+			},
+			wantUser:     &typedUser{ID: 1, Name: "Ada"},
+			wantFailures: []string{": expected Grace, got Ada"},
+		},
+		{
+			name:        "unknown field",
+			contentType: "application/json",
+			body:        `{"id": 1, "name": "Ada", "admin": true}`,
+			assertion:   nameIs("Ada"),
+			wantUser:    &typedUser{ID: 1, Name: "Ada"},
+		},
+		{
+			name:        "exact",
+			contentType: "application/json",
+			body:        `{"id": 1, "name": "Ada"}`,
+			exact:       true,
+			wantUser:    &typedUser{ID: 1, Name: "Ada"},
+		},
+		{
+			name:         "exact with an unknown field",
+			contentType:  "application/json",
+			body:         `{"id": 1, "name": "Ada", "admin": true}`,
+			exact:        true,
+			wantFailures: []string{`response body: failed to decode as mt_test.typedUser: json: unknown field "admin"`},
+		},
+		{
+			name:         "exact with an unknown field from a registered decoder",
+			contentType:  "application/yaml",
+			body:         "id: 1\nname: Ada\nadmin: true\n",
+			exact:        true,
+			wantFailures: []string{`response body: failed to decode as mt_test.typedUser: json: unknown field "admin"`},
+		},
+		{
+			name:         "multiple values",
+			contentType:  "application/json",
+			body:         `{"id": 1} {"id": 2}`,
+			wantFailures: []string{"response body: failed to decode as mt_test.typedUser: unexpected data after value"},
+		},
+		{
+			name:         "empty body",
+			contentType:  "application/json",
+			wantFailures: []string{"response body: failed to decode as mt_test.typedUser: expected a value, got nothing"},
+		},
+		{
+			name:         "wrong type",
+			contentType:  "application/json",
+			body:         `{"id": "1"}`,
+			wantFailures: []string{"response body: failed to decode as mt_test.typedUser: json: cannot unmarshal string into Go struct field typedUser.id of type int"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", test.contentType)
+				fmt.Fprint(w, test.body)
+			})
+
+			expectBodyAs := mt.ExpectBodyAs[typedUser]
+			if test.exact {
+				expectBodyAs = mt.ExpectExactBodyAs[typedUser]
+			}
+
+			result := execute(t, expectBodyAs(mt.NewHandlerContext(handler).GET("/"), test.assertion))
+			assert.Equal(t, test.wantFailures, failures(result))
+
+			u, ok := mt.BodyAs[typedUser](result)
+			if test.wantUser != nil {
+				assert.True(t, ok)
+				assert.Equal(t, *test.wantUser, u)
+			} else {
+				assert.False(t, ok)
+			}
+		})
+	}
+}
+
+func TestBodyAsWithoutExpectation(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id": 1, "name": "Ada"}`)
+	})
+
+	result := execute(t, mt.NewHandlerContext(handler).GET("/"))
+	_, ok := mt.BodyAs[typedUser](result)
+	assert.False(t, ok)
+
+	result = execute(t, mt.ExpectBodyAs(mt.NewHandlerContext(handler).GET("/"), func(map[string]any) error {
+		return nil
+	}))
+	_, ok = mt.BodyAs[typedUser](result)
+	assert.False(t, ok)
+}
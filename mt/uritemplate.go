@@ -0,0 +1,340 @@
+package mt
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A pathOperator describes how an RFC 6570 expression is expanded.
+type pathOperator struct {
+	first    string
+	sep      string
+	named    bool
+	reserved bool
+}
+
+// Supported RFC 6570 expression operators. The query and fragment operators
+// are not supported in paths; query parameters are set using WithQueryParam.
+var pathOperators = map[byte]pathOperator{
+	'+': {first: "", sep: ",", reserved: true},
+	'.': {first: ".", sep: "."},
+	'/': {first: "/", sep: "/"},
+	';': {first: ";", sep: ";", named: true},
+}
+
+// expandPath expands the parameters in an escaped path template, returning the
+// escaped path. Literal text is copied through as it is, so percent-encoded
+// characters such as %2F are preserved. Parameters may be written as :name at the start of a path segment, or
+// as RFC 6570 expressions such as {name}, {+name}, or {/name*}. A :name
+// elsewhere in a segment is only expanded if a parameter with that name is
+// provided.
+//
+// Every parameter in the template must be provided, and every provided
+// parameter must be used by the template.
+func expandPath(template string, params map[string]any) (string, error) {
+	sb := &strings.Builder{}
+	used := map[string]bool{}
+	literal := &strings.Builder{}
+	flush := func() {
+		sb.WriteString(escapePathLiteral(literal.String()))
+		literal.Reset()
+	}
+
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+		switch {
+		case c == '{':
+			end := strings.IndexByte(template[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("path %q: unterminated expression", template)
+			}
+
+			flush()
+			expansion, err := expandExpression(template[i+1:i+end], params, used)
+			if err != nil {
+				return "", fmt.Errorf("path %q: %w", template, err)
+			}
+
+			sb.WriteString(expansion)
+			i += end
+
+		case c == ':':
+			n := paramNameLength(template[i+1:])
+			name := template[i+1 : i+1+n]
+			_, provided := params[name]
+			if n == 0 || (i > 0 && template[i-1] != '/' && !provided) {
+				literal.WriteByte(c)
+				continue
+			}
+
+			flush()
+			expansion, err := expandExpression(name, params, used)
+			if err != nil {
+				return "", fmt.Errorf("path %q: %w", template, err)
+			}
+
+			sb.WriteString(expansion)
+			i += n
+
+		default:
+			literal.WriteByte(c)
+		}
+	}
+
+	flush()
+
+	var unknown []string
+	for name := range params {
+		if !used[name] {
+			unknown = append(unknown, name)
+		}
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return "", fmt.Errorf("path %q: unknown path parameter %q", template, unknown[0])
+	}
+
+	return sb.String(), nil
+}
+
+// paramNameLength returns the length of the parameter name at the start of s.
+func paramNameLength(s string) int {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9') {
+			return i
+		}
+	}
+
+	return len(s)
+}
+
+// expandExpression expands the contents of an RFC 6570 expression, recording
+// the parameters it uses.
+func expandExpression(expr string, params map[string]any, used map[string]bool) (string, error) {
+	op := pathOperator{sep: ","}
+	if expr != "" {
+		if o, ok := pathOperators[expr[0]]; ok {
+			op = o
+			expr = expr[1:]
+		} else if strings.IndexByte("#?&=,!@|", expr[0]) >= 0 {
+			return "", fmt.Errorf("unsupported expression operator %q", expr[0])
+		}
+	}
+
+	var parts []string
+	for _, spec := range strings.Split(expr, ",") {
+		name, explode, prefix := spec, false, -1
+		if strings.HasSuffix(name, "*") {
+			name, explode = name[:len(name)-1], true
+		} else if i := strings.IndexByte(name, ':'); i >= 0 {
+			n, err := strconv.Atoi(name[i+1:])
+			if err != nil || n <= 0 {
+				return "", fmt.Errorf("invalid prefix modifier in %q", spec)
+			}
+			name, prefix = name[:i], n
+		}
+
+		if name == "" || paramNameLength(name) != len(name) {
+			return "", fmt.Errorf("invalid parameter name %q", name)
+		}
+
+		value, ok := params[name]
+		if !ok {
+			return "", fmt.Errorf("missing path parameter %q", name)
+		}
+		used[name] = true
+
+		part, defined, err := expandValue(op, name, value, explode, prefix)
+		if err != nil {
+			return "", fmt.Errorf("path parameter %q: %w", name, err)
+		}
+
+		if defined {
+			parts = append(parts, part)
+		}
+	}
+
+	if len(parts) == 0 {
+		return "", nil
+	}
+
+	return op.first + strings.Join(parts, op.sep), nil
+}
+
+// expandValue expands a single parameter value. Empty lists and maps are
+// undefined, and expand to nothing.
+func expandValue(op pathOperator, name string, value any, explode bool, prefix int) (string, bool, error) {
+	escape := func(s string) string {
+		return escapeTemplateValue(s, op.reserved)
+	}
+
+	named := func(s string) string {
+		if !op.named {
+			return s
+		}
+		if s == "" {
+			return name
+		}
+		return name + "=" + s
+	}
+
+	list, assoc, err := templateCollection(value)
+	if err != nil {
+		return "", false, err
+	}
+
+	switch {
+	case list != nil:
+		if len(list) == 0 {
+			return "", false, nil
+		}
+
+		escaped := make([]string, len(list))
+		for i, v := range list {
+			escaped[i] = escape(v)
+			if explode {
+				escaped[i] = named(escaped[i])
+			}
+		}
+
+		if explode {
+			return strings.Join(escaped, op.sep), true, nil
+		}
+		return named(strings.Join(escaped, ",")), true, nil
+
+	case assoc != nil:
+		if len(assoc) == 0 {
+			return "", false, nil
+		}
+
+		keys := make([]string, 0, len(assoc))
+		for k := range assoc {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		pairs := make([]string, len(keys))
+		for i, k := range keys {
+			if explode {
+				pairs[i] = escape(k) + "=" + escape(assoc[k])
+			} else {
+				pairs[i] = escape(k) + "," + escape(assoc[k])
+			}
+		}
+
+		if explode {
+			return strings.Join(pairs, op.sep), true, nil
+		}
+		return named(strings.Join(pairs, ",")), true, nil
+	}
+
+	str, err := paramString(value)
+	if err != nil {
+		return "", false, err
+	}
+
+	if prefix > 0 && utf8.RuneCountInString(str) > prefix {
+		str = string([]rune(str)[:prefix])
+	}
+
+	return named(escape(str)), true, nil
+}
+
+// templateCollection converts slices to lists and maps to associative arrays
+// of strings. Other values return nil for both.
+func templateCollection(value any) ([]string, map[string]string, error) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return nil, nil, nil
+		}
+
+		list := make([]string, rv.Len())
+		for i := range list {
+			str, err := paramString(rv.Index(i).Interface())
+			if err != nil {
+				return nil, nil, err
+			}
+			list[i] = str
+		}
+		return list, nil, nil
+
+	case reflect.Map:
+		assoc := make(map[string]string, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			k, err := paramString(iter.Key().Interface())
+			if err != nil {
+				return nil, nil, err
+			}
+
+			v, err := paramString(iter.Value().Interface())
+			if err != nil {
+				return nil, nil, err
+			}
+			assoc[k] = v
+		}
+		return nil, assoc, nil
+	}
+
+	return nil, nil, nil
+}
+
+// escapeTemplateValue percent-encodes every character of a value other than
+// unreserved characters, and if reserved is true, reserved characters and
+// existing percent-encoded triplets.
+func escapeTemplateValue(s string, reserved bool) string {
+	const hex = "0123456789ABCDEF"
+	sb := &strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', strings.IndexByte("-._~", c) >= 0:
+			sb.WriteByte(c)
+		case reserved && strings.IndexByte(":/?#[]@!$&'()*+,;=", c) >= 0:
+			sb.WriteByte(c)
+		case reserved && c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte('%')
+			sb.WriteByte(hex[c>>4])
+			sb.WriteByte(hex[c&15])
+		}
+	}
+
+	return sb.String()
+}
+
+// escapePathLiteral percent-encodes the characters of literal path text that
+// aren't allowed in an escaped path, leaving existing percent-encoded triplets
+// alone.
+func escapePathLiteral(s string) string {
+	const hex = "0123456789ABCDEF"
+	sb := &strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', strings.IndexByte("-._~!$&'()*+,;=:@/", c) >= 0:
+			sb.WriteByte(c)
+		case c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte('%')
+			sb.WriteByte(hex[c>>4])
+			sb.WriteByte(hex[c&15])
+		}
+	}
+
+	return sb.String()
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
@@ -0,0 +1,196 @@
+package mt_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	mtjson "github.com/jefflinse/melatonin/json"
+	"github.com/jefflinse/melatonin/mt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathParams(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		path         string
+		params       map[string]any
+		wantPath     string
+		wantFailures []string
+	}{
+		{
+			name:     "segment parameter",
+			path:     "/users/:id",
+			params:   map[string]any{"id": "a/b"},
+			wantPath: "/users/a%2Fb",
+		},
+		{
+			name:     "parameter within a segment",
+			path:     "/files/report.:format",
+			params:   map[string]any{"format": "csv"},
+			wantPath: "/files/report.csv",
+		},
+		{
+			name:     "literal colon",
+			path:     "/times/12:30",
+			params:   map[string]any{},
+			wantPath: "/times/12:30",
+		},
+		{
+			name:     "escaped literal",
+			path:     "/files/a%2Fb/meta",
+			params:   map[string]any{},
+			wantPath: "/files/a%2Fb/meta",
+		},
+		{
+			name:     "escaped literal with a parameter",
+			path:     "/files/a%2Fb/:id",
+			params:   map[string]any{"id": 7},
+			wantPath: "/files/a%2Fb/7",
+		},
+		{
+			name:     "escaped literal with an expression",
+			path:     "/discounts/100%25/{id}",
+			params:   map[string]any{"id": 7},
+			wantPath: "/discounts/100%25/7",
+		},
+		{
+			name:     "simple expression",
+			path:     "/users/{id}",
+			params:   map[string]any{"id": 42},
+			wantPath: "/users/42",
+		},
+		{
+			name:     "multiple variables",
+			path:     "/points/{x,y}",
+			params:   map[string]any{"x": 1.5, "y": -2},
+			wantPath: "/points/1.5,-2",
+		},
+		{
+			name:     "prefix modifier",
+			path:     "/users/{name:3}",
+			params:   map[string]any{"name": "héloïse"},
+			wantPath: "/users/h%C3%A9l",
+		},
+		{
+			name:     "reserved expansion",
+			path:     "/files/{+path}",
+			params:   map[string]any{"path": "docs/read me%20.md"},
+			wantPath: "/files/docs/read%20me%20.md",
+		},
+		{
+			name:     "exploded path segments",
+			path:     "/files{/segs*}",
+			params:   map[string]any{"segs": []string{"docs", "readme.md"}},
+			wantPath: "/files/docs/readme.md",
+		},
+		{
+			name:     "empty list is undefined",
+			path:     "/files{/segs*}",
+			params:   map[string]any{"segs": []string{}},
+			wantPath: "/files",
+		},
+		{
+			name:     "label expansion",
+			path:     "/report{.format}",
+			params:   map[string]any{"format": "csv"},
+			wantPath: "/report.csv",
+		},
+		{
+			name:     "exploded path-style map",
+			path:     "/items{;filter*}",
+			params:   map[string]any{"filter": map[string]any{"b": true, "a": 1}},
+			wantPath: "/items;a=1;b=true",
+		},
+		{
+			name:     "path-style list",
+			path:     "/items{;ids}",
+			params:   map[string]any{"ids": []int{1, 2}},
+			wantPath: "/items;ids=1,2",
+		},
+		{
+			name:     "deferred value",
+			path:     "/users/{id}",
+			params:   map[string]any{"id": mtjson.Defer(func() int { return 7 })},
+			wantPath: "/users/7",
+		},
+		{
+			name:         "missing parameter",
+			path:         "/users/{id}",
+			params:       map[string]any{},
+			wantFailures: []string{`path "/users/{id}": missing path parameter "id"`},
+		},
+		{
+			name:         "unknown parameter",
+			path:         "/users/:id",
+			params:       map[string]any{"id": 1, "name": "Ada"},
+			wantFailures: []string{`path "/users/:id": unknown path parameter "name"`},
+		},
+		{
+			name:         "unterminated expression",
+			path:         "/users/{id",
+			params:       map[string]any{"id": 1},
+			wantFailures: []string{`path "/users/{id": unterminated expression`},
+		},
+		{
+			name:         "query continuation operator",
+			path:         "/users{&id}",
+			params:       map[string]any{"id": 1},
+			wantFailures: []string{`path "/users{&id}": unsupported expression operator '&'`},
+		},
+		{
+			name:         "invalid prefix modifier",
+			path:         "/users/{id:0}",
+			params:       map[string]any{"id": 1},
+			wantFailures: []string{`path "/users/{id:0}": invalid prefix modifier in "id:0"`},
+		},
+		{
+			name:         "invalid parameter name",
+			path:         "/users/{user-id}",
+			params:       map[string]any{"user-id": 1},
+			wantFailures: []string{`path "/users/{user-id}": invalid parameter name "user-id"`},
+		},
+		{
+			name:         "unsupported value",
+			path:         "/users/{id}",
+			params:       map[string]any{"id": struct{}{}},
+			wantFailures: []string{`path "/users/{id}": path parameter "id": unsupported parameter type: struct {}`},
+		},
+		{
+			name:         "deferred value error",
+			path:         "/users/{id}",
+			params:       map[string]any{"id": func() (any, error) { return nil, errors.New("no id") }},
+			wantFailures: []string{"id: no id"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, r.URL.EscapedPath())
+			})
+
+			tc := mt.NewHandlerContext(handler).GET(test.path).WithPathParams(test.params)
+			result := execute(t, tc)
+			assert.Equal(t, test.wantFailures, failures(result))
+			if test.wantFailures == nil {
+				assert.Equal(t, test.wantPath, string(result.Body))
+			}
+		})
+	}
+}
+
+func TestPathParamsReexecuted(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.URL.EscapedPath())
+	})
+
+	id := 0
+	tc := mt.NewHandlerContext(handler).GET("/users/{id}").
+		WithPathParam("id", mtjson.Defer(func() int { id++; return id }))
+
+	for _, want := range []string{"/users/1", "/users/2"} {
+		result := execute(t, tc)
+		assert.Nil(t, failures(result))
+		assert.Equal(t, want, string(result.Body))
+	}
+}